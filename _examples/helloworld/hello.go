@@ -1,8 +1,10 @@
 package helloworld
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 var (
@@ -25,6 +27,99 @@ type World struct {
 	blah      float64
 }
 
+// Greeter exposes its state through getter and setter methods
+type Greeter struct {
+	name     string
+	greeting string
+}
+
+// Named is implemented by values with a name which can be changed
+type Named interface {
+	Name() string
+	SetName(name string)
+}
+
+// Point is a position on a plane
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Version is an ordered release version
+type Version struct {
+	Major int
+	Minor int
+}
+
+// Sentence is a sequence of words
+type Sentence struct {
+	words []string
+}
+
+// Resource must be closed once it is no longer used
+type Resource struct {
+	closed bool
+}
+
+// Lines is a cursor over the lines of a text
+type Lines struct {
+	lines []string
+	line  int
+	err   error
+}
+
+// Node is a tree whose nodes refer back to their parent
+type Node struct {
+	Name     string
+	Parent   *Node
+	Children []*Node
+}
+
+// Walker visits a tree of nodes and returns the walker to use for the node's children
+type Walker interface {
+	Walk(node *Node) Walker
+}
+
+// Scorer keeps score
+type Scorer interface {
+	Score() (int, error)
+}
+
+// Game has an interface typed field and a slice of interfaces, which can hold Go or Python implementations
+type Game struct {
+	Referee Scorer
+	Players []Scorer
+}
+
+type fixedScore int
+
+func (f fixedScore) Score() (int, error) {
+	return int(f), nil
+}
+
+type tally map[string]int
+
+func (t tally) Score() (int, error) {
+	return len(t), nil
+}
+
+type roster []string
+
+func (r roster) Score() (int, error) {
+	return len(r), nil
+}
+
+type scoreFunc func() (int, error)
+
+func (f scoreFunc) Score() (int, error) {
+	return f()
+}
+
+// counter is internal state which is only reachable from Python as an opaque handle
+type counter struct {
+	count int
+}
+
 // notExported is a struct field not to be exported
 type notExported struct {
 	something string
@@ -78,11 +173,112 @@ func (h *Hello) PublicInterface(io io.Reader) (int, error) {
 	return n, nil
 }
 
+// MaybeWorld returns a World when something is provided and nil otherwise
+func MaybeWorld(something string) *World {
+	if something == "" {
+		return nil
+	}
+	return &World{Something: something}
+}
+
+// DescribeWorld describes the world, which may be nil
+func DescribeWorld(w *World) string {
+	if w == nil {
+		return "no world"
+	}
+	return "world " + w.Something
+}
+
+// NewCounter returns an unexported counter starting at start
+func NewCounter(start int) *counter {
+	return &counter{count: start}
+}
+
+// Increment adds one to the counter and returns the new count
+func Increment(c *counter) int {
+	c.count++
+	return c.count
+}
+
+// Greetings returns a map, which Python can only hold as an opaque handle
+func Greetings() map[string]string {
+	return map[string]string{"steve": "Hi Steve!"}
+}
+
+// Greet looks up a greeting by name
+func Greet(greetings map[string]string, name string) string {
+	return greetings[name]
+}
+
+// NewTree returns a root node
+func NewTree(name string) *Node {
+	return &Node{Name: name}
+}
+
+// AddChild adds a named child to the node and returns it
+func (n *Node) AddChild(name string) *Node {
+	child := &Node{Name: name, Parent: n}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// Depth returns the number of ancestors of the node
+func (n *Node) Depth() int {
+	depth := 0
+	for p := n.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
+}
+
+// WalkTree visits every node of the tree depth first
+func WalkTree(root *Node, walker Walker) {
+	if walker = walker.Walk(root); walker == nil {
+		return
+	}
+	for _, child := range root.Children {
+		WalkTree(child, walker)
+	}
+}
+
+// FixedScorer returns a Go implementation of Scorer
+func FixedScorer(score int) Scorer {
+	return fixedScore(score)
+}
+
+// NilScorer returns a Scorer holding a nil value of the kind, which is "pointer", "map", "slice" or "func", or a
+// nil Scorer for any other kind
+func NilScorer(kind string) Scorer {
+	switch kind {
+	case "pointer":
+		var score *fixedScore
+		return score
+	case "map":
+		var t tally
+		return t
+	case "slice":
+		var r roster
+		return r
+	case "func":
+		var f scoreFunc
+		return f
+	}
+	return nil
+}
+
 func ListOfHellos() []Hello {
 	return []Hello{{Bar: "Hi Steve!"}, {Bar: "Hi Jane!"}, {Bar: "Hi All!"}}
 }
 
-// NewHello constructs a new instance of Hello
+// NewWorldFromGreeting constructs a World from a greeting, which must not be empty
+func NewWorldFromGreeting(greeting string) (*World, error) {
+	if greeting == "" {
+		return nil, errors.New("greeting must not be empty")
+	}
+	return &World{Something: greeting}, nil
+}
+
+// NewHelloPtr constructs a new instance of Hello
 func NewHelloPtr(world World, foo int, bar string, buzz []string, baz float32) *Hello {
 	return &Hello{
 		World:  world,
@@ -105,3 +301,261 @@ func NewHello(world World, foo int, bar string, buzz []string, baz float32) Hell
 		secret: notExported{},
 	}
 }
+
+// NewGreeter constructs a Greeter for name
+func NewGreeter(name string) *Greeter {
+	return &Greeter{name: name, greeting: "Hello"}
+}
+
+// Name returns the name of the Greeter
+func (g *Greeter) Name() string {
+	return g.name
+}
+
+// SetName changes the name of the Greeter
+func (g *Greeter) SetName(name string) {
+	g.name = name
+}
+
+// GetGreeting returns the greeting used by the Greeter
+func (g *Greeter) GetGreeting() string {
+	return g.greeting
+}
+
+// SetGreeting changes the greeting used by the Greeter, which must not be empty
+func (g *Greeter) SetGreeting(greeting string) error {
+	if greeting == "" {
+		return errors.New("greeting must not be empty")
+	}
+	g.greeting = greeting
+	return nil
+}
+
+// Message greets the Greeter by name
+func (g *Greeter) Message() string {
+	return g.greeting + " " + g.name
+}
+
+// NamedGreeter returns a Greeter as a Named value
+func NamedGreeter(name string) Named {
+	return NewGreeter(name)
+}
+
+// String formats the Version as major.minor
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Less orders Versions by major then minor
+func (v Version) Less(other Version) bool {
+	return v.Major < other.Major || (v.Major == other.Major && v.Minor < other.Minor)
+}
+
+// NewSentence splits text into the words of a Sentence
+func NewSentence(text string) *Sentence {
+	return &Sentence{words: strings.Fields(text)}
+}
+
+// Len returns the number of words in the Sentence
+func (s *Sentence) Len() int {
+	return len(s.words)
+}
+
+// At returns the word at index i
+func (s *Sentence) At(i int) string {
+	return s.words[i]
+}
+
+// NewResource opens a Resource
+func NewResource() *Resource {
+	return &Resource{}
+}
+
+// IsClosed returns true once the Resource is closed
+func (r *Resource) IsClosed() bool {
+	return r.closed
+}
+
+// Close closes the Resource, which may only be closed once
+func (r *Resource) Close() error {
+	if r.closed {
+		return errors.New("resource already closed")
+	}
+	r.closed = true
+	return nil
+}
+
+// NewLines constructs a cursor over the lines of text, which must end with a newline
+func NewLines(text string) *Lines {
+	lines := &Lines{lines: strings.Split(text, "\n"), line: -1}
+	if last := len(lines.lines) - 1; lines.lines[last] != "" {
+		lines.err = errors.New("missing trailing newline")
+	}
+	lines.lines = lines.lines[:len(lines.lines)-1]
+	return lines
+}
+
+// Next advances to the next line, returning false once there are no more lines
+func (l *Lines) Next() bool {
+	l.line++
+	return l.line < len(l.lines)
+}
+
+// Value returns the current line
+func (l *Lines) Value() string {
+	return l.lines[l.line]
+}
+
+// Err returns the error found while reading the lines
+func (l *Lines) Err() error {
+	return l.err
+}
+
+// Shout copies src to dst in upper case, returning the number of bytes written
+func Shout(dst io.Writer, src io.Reader) (int, error) {
+	text, err := io.ReadAll(src)
+	if err != nil {
+		return 0, err
+	}
+	return dst.Write([]byte(strings.ToUpper(string(text))))
+}
+
+// NewGreetingReader returns a reader of a greeting for name
+func NewGreetingReader(name string) io.Reader {
+	return strings.NewReader("Hello, " + name + "!")
+}
+
+// NewUpperWriter returns a writer upper casing everything written to dst, which is closed with the writer
+func NewUpperWriter(dst io.WriteCloser) io.WriteCloser {
+	return &upperWriter{dst: dst}
+}
+
+type upperWriter struct {
+	dst io.WriteCloser
+}
+
+func (w *upperWriter) Write(p []byte) (int, error) {
+	return w.dst.Write([]byte(strings.ToUpper(string(p))))
+}
+
+func (w *upperWriter) Close() error {
+	return w.dst.Close()
+}
+
+// Proxy is a forward proxy a client connects through
+type Proxy struct {
+	Addr string
+}
+
+// Client is configured with functional options
+type Client struct {
+	Addr     string
+	Timeout  int
+	Retries  int
+	Agent    string
+	Insecure bool
+	Proxy    *Proxy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets the timeout of the client in seconds
+func WithTimeout(seconds int) Option {
+	return func(c *Client) {
+		c.Timeout = seconds
+	}
+}
+
+// WithRetries sets the number of times the client retries a request
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		c.Retries = retries
+	}
+}
+
+// WithAgent sets the user agent of the client from its name and version
+func WithAgent(name, version string) Option {
+	return func(c *Client) {
+		c.Agent = name + "/" + version
+	}
+}
+
+// WithInsecure skips verifying the certificate of the server
+func WithInsecure() Option {
+	return func(c *Client) {
+		c.Insecure = true
+	}
+}
+
+// WithProxy sets the proxy of the client, or connects directly when proxy is nil
+func WithProxy(proxy *Proxy) Option {
+	return func(c *Client) {
+		c.Proxy = proxy
+	}
+}
+
+// NewClient returns a client for addr with a 30 second timeout through proxy:3128 unless configured otherwise
+func NewClient(addr string, opts ...Option) *Client {
+	c := &Client{Addr: addr, Timeout: 30, Proxy: &Proxy{Addr: "proxy:3128"}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Describe describes the client with opts applied, without changing the client
+func (c *Client) Describe(opts ...Option) string {
+	configured := *c
+	for _, opt := range opts {
+		opt(&configured)
+	}
+	return fmt.Sprintf("%s timeout=%d retries=%d agent=%q", configured.Addr, configured.Timeout, configured.Retries,
+		configured.Agent)
+}
+
+// Divide returns the quotient and remainder of a divided by b
+func Divide(a, b int) (quotient, remainder int, err error) {
+	if b == 0 {
+		return 0, 0, errors.New("division by zero")
+	}
+	return a / b, a % b, nil
+}
+
+// Size returns the number of letters and words of the world
+func (w World) Size() (letters, words int) {
+	return len(w.Something), len(strings.Fields(w.Something))
+}
+
+// WorldSize returns the width and height of a map of the world
+func WorldSize() (width, height int) {
+	return 360, 180
+}
+
+// Profile is encoded with json tags
+type Profile struct {
+	DisplayName string   `json:"display_name"`
+	Age         int      `json:"age,omitempty"`
+	Tags        []string `json:"tags"`
+	Secret      string   `json:"-"`
+}
+
+// Account is bound with the names of its tags when generating with --py-struct-tags
+type Account struct {
+	AccountID string `json:"id"`
+	Owner     string `json:"owner" veil:",readonly"`
+	Balance   int    `json:"balance" veil:"funds"`
+	Password  string `json:"-"`
+	Audit     string `veil:"audit,skip"`
+	Tier      string `json:"class"`
+}
+
+// NewAccount opens an account for owner
+func NewAccount(id, owner string) *Account {
+	return &Account{AccountID: id, Owner: owner, Audit: "opened by " + owner}
+}
+
+// Checksum adds up a value of each Go numeric kind
+func Checksum(i int, i8 int8, u8 uint8, u16 uint16, u32 uint32, u uint, f32 float32) float64 {
+	return float64(i) + float64(i8) + float64(u8) + float64(u16) + float64(u32) + float64(u) + float64(f32)
+}
@@ -1,8 +1,16 @@
+import copy
+import gc
 import generated
+import io
+import json
+import pickle
+import shutil
 import unittest
 import sys
 
 _PY3 = sys.version_info[0] == 3
+# generating with --py-struct-tags names properties after the json and veil tags of the fields
+_STRUCT_TAGS = hasattr(generated.Account, "funds")
 
 
 class TestHello(unittest.TestCase):
@@ -30,6 +38,226 @@ class TestHello(unittest.TestCase):
         ret = generated.public_multi_return(42, "Hello world!")
         self.assertTupleEqual(ret, (42, "Hello world!"))
 
+    def test_nil_pointer_is_none(self):
+        self.assertIsNone(generated.maybe_world(""))
+        self.assertEqual(generated.maybe_world("hi").something, "hi")
+
+    def test_none_for_pointer_param(self):
+        self.assertEqual(generated.describe_world(None), "no world")
+        self.assertEqual(generated.describe_world(generated.maybe_world("y")), "world y")
+
+    def test_typed_nil_raises(self):
+        for kind in ("pointer", "map", "slice", "func"):
+            with self.assertRaises(generated.VeilNilError):
+                generated.nil_scorer(kind)
+
+    def test_nil_interface_is_none(self):
+        self.assertIsNone(generated.nil_scorer(""))
+
+    def test_none_for_struct_value_raises(self):
+        with self.assertRaises(TypeError):
+            generated.Hello.new(None, 1, "bar", generated.StringList(), 1.0)
+
+    def test_struct_value_temporary(self):
+        hello = generated.Hello.new(generated.maybe_world("y"), 1, "bar", generated.StringList(), 1.0)
+        self.assertEqual(hello.world.something, "y")
+
+    def test_construct_with_fields(self):
+        hello = generated.Hello(foo=1, bar="x", world=generated.World(something="y"))
+        self.assertEqual(hello.foo, 1)
+        self.assertEqual(hello.bar, "x")
+        self.assertEqual(hello.world.something, "y")
+        self.assertEqual(hello.baz, 0.0)
+
+    def test_construct_with_unknown_field_raises(self):
+        with self.assertRaises(TypeError):
+            generated.Hello(nope=1)
+
+    @unittest.skipUnless(hasattr(sys, "unraisablehook"), "needs sys.unraisablehook")
+    def test_failed_construction_is_collected_cleanly(self):
+        unraisable = []
+        hook, sys.unraisablehook = sys.unraisablehook, unraisable.append
+        try:
+            with self.assertRaises(TypeError):
+                generated.Hello(nope=1)
+            with self.assertRaises(TypeError):
+                generated.Hello(foo="not an int")
+            gc.collect()
+        finally:
+            sys.unraisablehook = hook
+        self.assertEqual(unraisable, [])
+
+    def test_alternative_constructor(self):
+        world = generated.World.new_from_greeting("hi")
+        self.assertIsInstance(world, generated.World)
+        self.assertEqual(world.something, "hi")
+
+    def test_alternative_constructor_keeps_new_prefix(self):
+        hello = generated.Hello.new_ptr(generated.World(something="y"), 1, "bar", generated.StringList(), 1.0)
+        self.assertIsInstance(hello, generated.Hello)
+        self.assertEqual(hello.bar, "bar")
+
+    def test_constructor_error_raises(self):
+        with self.assertRaises(generated.VeilError):
+            generated.World.new_from_greeting("")
+
+    def test_accessors_are_properties(self):
+        greeter = generated.Greeter.new("Ada")
+        self.assertEqual(greeter.name, "Ada")
+        greeter.name = "Grace"
+        self.assertEqual(greeter.message(), "Hello Grace")
+        greeter.set_name("Alan")
+        self.assertEqual(greeter.name, "Alan")
+
+    def test_get_accessors_keep_method_form(self):
+        greeter = generated.Greeter.new("Ada")
+        greeter.greeting = "Hi"
+        self.assertEqual(greeter.get_greeting(), "Hi")
+
+    def test_setter_errors_raise(self):
+        greeter = generated.Greeter.new("Ada")
+        with self.assertRaises(generated.VeilError):
+            greeter.set_greeting("")
+
+    def test_interface_accessors_are_properties(self):
+        named = generated.named_greeter("Ada")
+        self.assertEqual(named.name, "Ada")
+        named.name = "Grace"
+        self.assertEqual(named.name, "Grace")
+
+    def test_stringer_is_str(self):
+        version = generated.Version(major=1, minor=2)
+        self.assertEqual(str(version), "1.2")
+        self.assertIn("Major:1", repr(version))
+
+    def test_comparable_structs_are_hashable(self):
+        a = generated.World(something="a")
+        self.assertEqual(a, generated.World(something="a"))
+        self.assertNotEqual(a, generated.World(something="b"))
+        self.assertEqual(len({a, generated.World(something="a")}), 1)
+        self.assertEqual({a: 1}[generated.World(something="a")], 1)
+
+    def test_equal_floats_hash_alike(self):
+        origin = generated.Point(x=0.0, y=0.0)
+        self.assertEqual(origin, generated.Point(x=-0.0, y=0.0))
+        self.assertEqual(hash(origin), hash(generated.Point(x=-0.0, y=0.0)))
+        self.assertEqual(len({origin, generated.Point(x=-0.0, y=-0.0)}), 1)
+        self.assertNotEqual(generated.Point(x=float("nan")), generated.Point(x=float("nan")))
+
+    def test_less_orders(self):
+        versions = [generated.Version(major=2), generated.Version(major=1, minor=5),
+                    generated.Version(major=1)]
+        self.assertEqual([str(v) for v in sorted(versions)], ["1.0", "1.5", "2.0"])
+        self.assertTrue(generated.Version(major=1) <= generated.Version(major=1))
+
+    def test_len_and_at_are_a_sequence(self):
+        sentence = generated.Sentence.new("hello big world")
+        self.assertEqual(len(sentence), 3)
+        self.assertEqual(sentence[-1], "world")
+        self.assertEqual(list(sentence), ["hello", "big", "world"])
+        with self.assertRaises(IndexError):
+            sentence[3]
+        self.assertEqual(sentence[1:3], ["big", "world"])
+        self.assertEqual(sentence[::-1], ["world", "big", "hello"])
+        with self.assertRaises(TypeError):
+            sentence["big"]
+
+    def test_close_is_a_context_manager(self):
+        with generated.Resource.new() as resource:
+            self.assertFalse(resource.is_closed())
+        self.assertTrue(resource.is_closed())
+
+    def test_cursor_is_iterable(self):
+        self.assertEqual(list(generated.Lines.new("a\nb\n")), ["a", "b"])
+
+    def test_cursor_raises_err_at_end(self):
+        lines = []
+        with self.assertRaises(generated.VeilError):
+            for line in generated.Lines.new("a\nb"):
+                lines.append(line)
+        self.assertEqual(lines, ["a"])
+
+    def test_seq_is_a_generator(self):
+        countdown = generated.countdown(3)
+        self.assertEqual(next(countdown), 3)
+        self.assertEqual(list(countdown), [2, 1])
+
+    def test_seq2_yields_tuples(self):
+        sentence = generated.Sentence.new("hello big world")
+        self.assertEqual(dict(sentence.words()), {0: "hello", 1: "big", 2: "world"})
+
+    def test_seq2_with_error_raises(self):
+        numbers = generated.parse_numbers("1 2 three")
+        self.assertEqual(next(numbers), 1)
+        self.assertEqual(next(numbers), 2)
+        with self.assertRaises(generated.VeilError):
+            next(numbers)
+
+    def test_list_items_are_views(self):
+        hellos = generated.list_of_hellos()
+        hellos[0].bar = "Bye Steve!"
+        self.assertEqual(hellos[0].bar, "Bye Steve!")
+
+    def test_struct_fields_are_views(self):
+        hello = generated.list_of_hellos()[0]
+        hello.world.something = "everyone"
+        self.assertEqual(hello.world.something, "everyone")
+
+    def test_copies_are_independent(self):
+        hellos = generated.list_of_hellos()
+        hello = copy.copy(hellos[0])
+        hello.bar = "Bye Steve!"
+        self.assertEqual(hellos[0].bar, "Hi Steve!")
+        hellos_copy = copy.deepcopy(hellos)
+        hellos_copy[1].bar = "Bye Jane!"
+        self.assertEqual(hellos[1].bar, "Hi Jane!")
+
+    def test_copy_semantics(self):
+        generated.set_semantics(generated.Hello, generated.VEIL_COPY)
+        try:
+            hellos = generated.list_of_hellos()
+            hellos[0].bar = "Bye Steve!"
+            self.assertEqual(hellos[0].bar, "Hi Steve!")
+        finally:
+            generated.set_semantics(generated.Hello, generated.VEIL_VIEW)
+
+    def test_opaque_handles(self):
+        counter = generated.new_counter(41)
+        self.assertIsInstance(counter, generated.GoOpaque)
+        self.assertEqual(generated.increment(counter), 42)
+        self.assertEqual(generated.increment(counter), 43)
+        greetings = generated.greetings()
+        self.assertEqual(generated.greet(greetings, "steve"), "Hi Steve!")
+        self.assertEqual(generated.greet(None, "steve"), "")
+
+    def test_opaque_temporaries(self):
+        self.assertEqual(generated.increment(generated.new_counter(1)), 2)
+        self.assertEqual(generated.greet(generated.greetings(), "steve"), "Hi Steve!")
+
+    def test_opaque_type_checked(self):
+        with self.assertRaises(TypeError):
+            generated.increment(generated.Hello())
+        with self.assertRaisesRegex(TypeError, "counter"):
+            generated.increment(generated.greetings())
+        with self.assertRaises(TypeError):
+            generated.greet(generated.new_counter(1), "steve")
+
+    def test_recursive_tree(self):
+        root = generated.new_tree("root")
+        child = root.add_child("child")
+        grandchild = child.add_child("grandchild")
+        self.assertEqual(grandchild.depth(), 2)
+        self.assertEqual(grandchild.parent.parent.name, "root")
+        self.assertEqual(root.children[0].children[0].name, "grandchild")
+
+    def test_recursive_tree_deepcopy(self):
+        root = generated.new_tree("root")
+        root.add_child("child")
+        tree = copy.deepcopy(root)
+        tree.children[0].name = "copy"
+        self.assertEqual(root.children[0].name, "child")
+        self.assertEqual(tree.children[0].parent.children[0].name, "copy")
+
     def test_struct_construction(self):
         some_string = "some cool string"
         hello_obj = generated.Hello()
@@ -60,8 +288,285 @@ class StringReader(generated.Reader):
         return len(utf8_bytes), None
 
 
+class DoubleScorer(generated.Scorer):
+    def __init__(self, value):
+        super(DoubleScorer, self).__init__()
+        self.value = value
+
+    def score(self):
+        return self.value * 2, None
+
+
 class TestInterface(unittest.TestCase):
+    def test_go_implementation_proxy(self):
+        scorer = generated.fixed_scorer(3)
+        self.assertIsInstance(scorer, generated.Scorer)
+        self.assertEqual(scorer.score(), 3)
+
+    def test_interface_field(self):
+        game = generated.Game()
+        self.assertIsNone(game.referee)
+        referee = DoubleScorer(5)
+        game.referee = referee
+        self.assertIs(game.referee, referee)
+        game.referee = generated.fixed_scorer(1)
+        self.assertEqual(game.referee.score(), 1)
+
+    def test_interface_slice_keeps_python_alive(self):
+        game = generated.Game()
+        players = game.players
+        players.append(DoubleScorer(2))
+        players.append(generated.fixed_scorer(3))
+        gc.collect()
+        self.assertEqual(players[0].value, 2)
+        self.assertEqual(players[1].score(), 3)
+
+    def test_interface_type_checked(self):
+        game = generated.Game()
+        with self.assertRaises(TypeError):
+            game.referee = generated.Hello()
+
     def test_string_reader(self):
         reader = StringReader("hello world!")
         hello = generated.Hello()
         self.assertEqual(hello.public_interface(reader), 12)
+
+
+class ClosingBytesIO(io.BytesIO):
+    def close(self):
+        self.closed_value = self.getvalue()
+        super(ClosingBytesIO, self).close()
+
+
+class TestStream(unittest.TestCase):
+    def test_file_objects_as_reader_and_writer(self):
+        dst = io.BytesIO()
+        self.assertEqual(generated.shout(dst, io.BytesIO(b"hello streams")), 13)
+        self.assertEqual(dst.getvalue(), b"HELLO STREAMS")
+
+    def test_go_reader_is_a_file_object(self):
+        reader = generated.new_greeting_reader("World")
+        self.assertIsInstance(reader, io.RawIOBase)
+        self.assertTrue(reader.readable())
+        self.assertFalse(reader.writable())
+        self.assertEqual(reader.read(), b"Hello, World!")
+        self.assertEqual(reader.read(), b"")
+
+    def test_copyfileobj(self):
+        dst = io.BytesIO()
+        shutil.copyfileobj(generated.new_greeting_reader("Gopher"), dst)
+        self.assertEqual(dst.getvalue(), b"Hello, Gopher!")
+
+    def test_go_writer_closes_python_file(self):
+        dst = ClosingBytesIO()
+        with generated.new_upper_writer(dst) as writer:
+            self.assertTrue(writer.writable())
+            shutil.copyfileobj(io.BytesIO(b"quiet please"), writer)
+        self.assertTrue(dst.closed)
+        self.assertEqual(dst.closed_value, b"QUIET PLEASE")
+
+    def test_python_errors_reach_go(self):
+        class Broken(io.RawIOBase):
+            def readinto(self, b):
+                raise ValueError("broken stream")
+
+        with self.assertRaises(generated.VeilError) as ctx:
+            generated.shout(io.BytesIO(), Broken())
+        self.assertIn("broken stream", str(ctx.exception))
+
+    def test_non_stream_type_checked(self):
+        with self.assertRaises(TypeError):
+            generated.shout(io.BytesIO(), 42)
+
+
+class TestOptions(unittest.TestCase):
+    def test_constructor_options(self):
+        client = generated.Client.new("localhost", timeout=5, agent=("veil", "1.0"))
+        self.assertEqual(client.addr, "localhost")
+        self.assertEqual(client.timeout, 5)
+        self.assertEqual(client.retries, 0)
+        self.assertEqual(client.agent, "veil/1.0")
+
+    def test_options_default_to_go(self):
+        client = generated.Client.new("localhost")
+        self.assertEqual(client.timeout, 30)
+        self.assertEqual(client.agent, "")
+
+    def test_method_options(self):
+        client = generated.Client.new("localhost", retries=2)
+        self.assertEqual(client.describe(timeout=1), 'localhost timeout=1 retries=2 agent=""')
+        self.assertEqual(client.timeout, 30)
+
+    def test_unknown_option(self):
+        with self.assertRaises(TypeError):
+            generated.Client.new("localhost", verbose=True)
+
+    def test_flag_options(self):
+        self.assertTrue(generated.Client.new("localhost", insecure=True).insecure)
+        self.assertFalse(generated.Client.new("localhost", insecure=False).insecure)
+        self.assertFalse(generated.Client.new("localhost").insecure)
+        with self.assertRaisesRegex(TypeError, "insecure must be a bool, not int"):
+            generated.Client.new("localhost", insecure=1)
+        with self.assertRaisesRegex(TypeError, "insecure must be a bool, not NoneType"):
+            generated.Client.new("localhost", insecure=None)
+
+    def test_option_tuple_checked(self):
+        with self.assertRaisesRegex(TypeError, "agent must be a tuple of 2 values, not 'curl'"):
+            generated.Client.new("localhost", agent="curl")
+        with self.assertRaisesRegex(TypeError, "agent must be a tuple of 2 values"):
+            generated.Client.new("localhost", agent=("a", "b", "c"))
+
+    def test_none_option(self):
+        self.assertEqual(generated.Client.new("localhost").proxy.addr, "proxy:3128")
+        self.assertIsNone(generated.Client.new("localhost", proxy=None).proxy)
+        proxy = generated.Proxy(addr="other:8080")
+        self.assertEqual(generated.Client.new("localhost", proxy=proxy).proxy.addr, "other:8080")
+
+
+class TestNamedResults(unittest.TestCase):
+    def test_named_results(self):
+        result = generated.divide(7, 2)
+        self.assertEqual(result.quotient, 3)
+        self.assertEqual(result.remainder, 1)
+        self.assertEqual(type(result).__name__, "DivideResult")
+
+    def test_method_and_func_results_are_distinct(self):
+        size = generated.World(something="hello big world").size()
+        self.assertEqual((size.letters, size.words), (15, 3))
+        self.assertEqual(type(size).__name__, "World_SizeResult")
+        size = generated.world_size()
+        self.assertEqual((size.width, size.height), (360, 180))
+        self.assertEqual(type(size).__name__, "WorldSizeResult")
+
+    def test_unpack_named_results(self):
+        quotient, remainder = generated.divide(9, 4)
+        self.assertEqual((quotient, remainder), (2, 1))
+
+    def test_positional_names(self):
+        result = generated.public_multi_return(42, "Hello world!")
+        self.assertEqual(result.r0, 42)
+        self.assertEqual(result.r1, "Hello world!")
+
+    def test_error_is_raised(self):
+        with self.assertRaises(generated.VeilError):
+            generated.divide(1, 0)
+
+
+class TestSerialization(unittest.TestCase):
+    @unittest.skipIf(_STRUCT_TAGS, "secret is hidden by its json tag")
+    def test_to_dict_respects_json_tags(self):
+        profile = generated.Profile(display_name="Gopher", secret="hidden")
+        self.assertEqual(profile.to_dict(), {"display_name": "Gopher", "tags": None})
+
+    def test_from_dict(self):
+        profile = generated.Profile.from_dict({"display_name": "Gopher", "age": 13, "tags": ["go"]})
+        self.assertEqual(profile.display_name, "Gopher")
+        self.assertEqual(profile.age, 13)
+        self.assertEqual(list(profile.tags), ["go"])
+
+    def test_json_round_trip(self):
+        profile = generated.Profile.from_json(b'{"display_name": "Gopher", "age": 13}')
+        self.assertEqual(json.loads(profile.to_json()), {"display_name": "Gopher", "age": 13, "tags": None})
+
+    def test_invalid_json(self):
+        with self.assertRaises(generated.VeilError):
+            generated.Profile.from_json('{"age": "old"}')
+
+    def test_pickle(self):
+        profile = generated.Profile(display_name="Gopher", age=13)
+        copied = pickle.loads(pickle.dumps(profile))
+        self.assertIsInstance(copied, generated.Profile)
+        self.assertEqual(copied.display_name, "Gopher")
+        self.assertEqual(copied.age, 13)
+
+    def test_pickle_rejects_undecodable_state(self):
+        game = generated.Game()
+        game.referee = generated.fixed_scorer(3)
+        with self.assertRaisesRegex(TypeError, "Referee"):
+            pickle.dumps(game)
+        with self.assertRaisesRegex(TypeError, "unexported"):
+            pickle.dumps(generated.Greeter.new("Ada"))
+
+    @unittest.skipUnless(hasattr(sys, "unraisablehook"), "needs sys.unraisablehook")
+    def test_failed_unpickling_is_collected_cleanly(self):
+        unraisable = []
+        hook, sys.unraisablehook = sys.unraisablehook, unraisable.append
+        try:
+            profile = generated.Profile.__new__(generated.Profile)
+            with self.assertRaises(generated.VeilError):
+                profile.__setstate__('{"age": "old"}')
+            del profile
+            gc.collect()
+        finally:
+            sys.unraisablehook = hook
+        self.assertEqual(unraisable, [])
+
+    @unittest.skipIf(_STRUCT_TAGS, "json:\"-\" fields aren't bound when generating with --py-struct-tags")
+    def test_pickle_leaves_out_json_skipped_fields(self):
+        profile = generated.Profile(display_name="Gopher", secret="hunter2")
+        self.assertNotIn("secret", profile.to_dict())
+        copied = pickle.loads(pickle.dumps(profile))
+        self.assertEqual(copied.display_name, "Gopher")
+        self.assertEqual(copied.secret, "")
+        self.assertIn('json:"-": secret', generated.Profile.__doc__)
+
+
+@unittest.skipUnless(_STRUCT_TAGS, "generated without --py-struct-tags")
+class TestStructTags(unittest.TestCase):
+    def test_tag_names(self):
+        account = generated.Account(id="acc-1", funds=10)
+        self.assertEqual(account.id, "acc-1")
+        self.assertEqual(account.funds, 10)
+        self.assertFalse(hasattr(account, "account_id"))
+        self.assertFalse(hasattr(account, "balance"))
+
+    def test_readonly(self):
+        account = generated.Account.new("acc-1", "Ada")
+        self.assertEqual(account.owner, "Ada")
+        with self.assertRaises(AttributeError):
+            account.owner = "Bob"
+        with self.assertRaises(TypeError):
+            generated.Account(owner="Bob")
+
+    def test_skipped(self):
+        account = generated.Account.new("acc-1", "Ada")
+        self.assertFalse(hasattr(account, "password"))
+        self.assertFalse(hasattr(account, "audit"))
+        self.assertEqual(account.to_dict(),
+                         {"id": "acc-1", "owner": "Ada", "balance": 0, "Audit": "opened by Ada", "class": ""})
+
+    def test_reserved_tag_name(self):
+        account = generated.Account(id="acc-1", class_="gold")
+        self.assertEqual(account.class_, "gold")
+        account.class_ = "silver"
+        self.assertEqual(account.to_dict()["class"], "silver")
+
+
+class TestNumericArgs(unittest.TestCase):
+    def test_in_range(self):
+        self.assertEqual(generated.checksum(1, -128, 255, 65535, 4294967295, 1, 0.5), 4295032959.5)
+        self.assertEqual(generated.checksum(2 ** 63 - 1, 0, 0, 0, 0, 0, 0), float(2 ** 63 - 1))
+        self.assertEqual(generated.checksum(0, 0, 0, 0, 0, 2 ** 64 - 1, 0), float(2 ** 64 - 1))
+
+    def test_overflow(self):
+        with self.assertRaisesRegex(OverflowError, "i8=128 is out of range for Go int8"):
+            generated.checksum(0, 128, 0, 0, 0, 0, 0)
+        with self.assertRaisesRegex(OverflowError, "u=-1 is out of range for Go uint"):
+            generated.checksum(0, 0, 0, 0, 0, -1, 0)
+        with self.assertRaisesRegex(OverflowError, "i=.* is out of range for Go int "):
+            generated.checksum(2 ** 63, 0, 0, 0, 0, 0, 0)
+        with self.assertRaisesRegex(OverflowError, "f32=.* is out of range for Go float32"):
+            generated.checksum(0, 0, 0, 0, 0, 0, 1e39)
+
+    def test_wrong_type(self):
+        with self.assertRaisesRegex(TypeError, "u8 must be an integer for Go uint8, not str"):
+            generated.checksum(0, 0, "1", 0, 0, 0, 0)
+        with self.assertRaisesRegex(TypeError, "i must be an integer for Go int, not bool"):
+            generated.checksum(True, 0, 0, 0, 0, 0, 0)
+        with self.assertRaisesRegex(TypeError, "f32 must be a real number for Go float32, not str"):
+            generated.checksum(0, 0, 0, 0, 0, 0, "1.0")
+
+    def test_field_setter(self):
+        profile = generated.Profile()
+        with self.assertRaisesRegex(OverflowError, "age=.* is out of range for Go int"):
+            profile.age = 2 ** 64
@@ -0,0 +1,43 @@
+//go:build go1.23
+
+package helloworld
+
+import (
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Countdown yields the numbers from n down to 1
+func Countdown(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := n; i > 0; i-- {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// Words yields the index and the word for each word in the Sentence
+func (s *Sentence) Words() iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		for i, word := range s.words {
+			if !yield(i, word) {
+				return
+			}
+		}
+	}
+}
+
+// ParseNumbers yields the numbers in text, stopping with an error at the first word which isn't a number
+func ParseNumbers(text string) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for _, word := range strings.Fields(text) {
+			n, err := strconv.Atoi(word)
+			if !yield(n, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -2,7 +2,6 @@ package bind
 
 import (
 	"bufio"
-	"fmt"
 	"github.com/devigned/veil/bind/python"
 	"github.com/devigned/veil/cgo"
 	"github.com/devigned/veil/core"
@@ -12,50 +11,142 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 )
 
+// Factory creates the binder of a target for pkg, configured by the options of the target
+type Factory func(pkg *cgo.Package, options *core.Options) (core.Binder, error)
+
 var (
-	registry = map[string]func(*cgo.Package) core.Binder{"py3": python.NewBinder}
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
 )
 
-type wrapper struct {
-	binder core.Binder
-	pkg    *cgo.Package
+func init() {
+	Register(python.TARGET_NAME, python.NewBinder)
 }
 
-func (b wrapper) Bind(outDir, libName string) error {
-	code := toCodeFile(b.pkg)
-	mainFile := path.Join(outDir, "main.go")
-	f, err := os.Create(mainFile)
+// Register makes the binder created by factory available as the target name. Binders linked into a custom build
+// of veil register themselves from init, like database/sql drivers. Register panics if factory is nil or if name
+// is already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("bind: Register factory is nil for target " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic("bind: Register called twice for target " + name)
+	}
+	registry[name] = factory
+}
+
+// Targets returns the sorted names of the registered targets
+func Targets() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBinder is a factory method for creating a new binder for a given target. Targets without a registered binder
+// are generated by the veil-gen-<target> plugin on the PATH. Options no binder reads are reported as unknown.
+func NewBinder(pkg *cgo.Package, target string, options *core.Options) (core.Binder, error) {
+	if options == nil {
+		options = core.NewOptions(target)
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[target]
+	registryMu.RUnlock()
+	if !ok {
+		factory = func(pkg *cgo.Package, options *core.Options) (core.Binder, error) {
+			return newPluginBinder(pkg, target, options)
+		}
+	}
+
+	binder, err := factory(pkg, options)
 	if err != nil {
-		return core.NewSystemErrorF("Unable to create %s", path.Join(outDir, "main.go"))
+		return nil, err
 	}
+	if unused := options.Unused(); len(unused) > 0 {
+		return nil, core.NewUserErrorF("Unknown options %s for target %s", strings.Join(unused, ", "), target)
+	}
+	return binder, nil
+}
 
-	defer f.Close()
+// Bind generates the bindings of pkg for targets into outDir. The build steps the binders need are run once
+// before any of them binds, and every file a binder declares must have been written once it's done.
+func Bind(pkg *cgo.Package, targets []string, options map[string]*core.Options, outDir, libName string) error {
+	binders := make([]core.Binder, len(targets))
+	steps := map[core.BuildStep]bool{}
+	for i, target := range targets {
+		binder, err := NewBinder(pkg, target, options[target])
+		if err != nil {
+			return err
+		}
+		binders[i] = binder
+		for _, step := range binder.BuildSteps() {
+			steps[step] = true
+		}
+	}
 
-	w := bufio.NewWriter(f)
-	printer.Fprint(w, &token.FileSet{}, code)
-	w.Flush()
+	if steps[core.BUILD_STEP_SHARED_LIB] {
+		steps[core.BUILD_STEP_CGO] = true
+	}
+	for _, step := range core.BUILD_STEPS {
+		if !steps[step] {
+			continue
+		}
+		if err := runBuildStep(pkg, step, outDir, libName); err != nil {
+			return err
+		}
+	}
 
-	getPackage("github.com/satori/go.uuid")
-	buildSharedLib(outDir, libName)
-	b.binder.Bind(outDir, libName)
+	for i, binder := range binders {
+		if err := binder.Bind(outDir, libName); err != nil {
+			return err
+		}
+		for _, file := range binder.Files(libName) {
+			if _, err := os.Stat(path.Join(outDir, file)); err != nil {
+				return core.NewSystemErrorF("The binder for %s didn't write %s: %v", targets[i], file, err)
+			}
+		}
+	}
 	return nil
 }
 
-// NewBinder is a factory method for creating a new binder for a given target
-func NewBinder(pkg *cgo.Package, target string) (core.Binder, error) {
-	binderFactory, ok := registry[target]
-	if !ok {
-		return nil, core.NewSystemError(fmt.Sprintf("I don't know how to create a binder for %s", target))
+func runBuildStep(pkg *cgo.Package, step core.BuildStep, outDir, libName string) error {
+	switch step {
+	case core.BUILD_STEP_CGO:
+		return writeCodeFile(pkg, outDir)
+	case core.BUILD_STEP_SHARED_LIB:
+		getPackage("github.com/satori/go.uuid")
+		return buildSharedLib(outDir, libName)
 	}
+	return core.NewSystemErrorF("Unknown build step %s", step)
+}
 
-	bindable := wrapper{
-		binder: binderFactory(pkg),
-		pkg:    pkg,
+// writeCodeFile writes the CGo wrapper of pkg to main.go
+func writeCodeFile(pkg *cgo.Package, outDir string) error {
+	code := toCodeFile(pkg)
+	mainFile := path.Join(outDir, "main.go")
+	f, err := os.Create(mainFile)
+	if err != nil {
+		return core.NewSystemErrorF("Unable to create %s", mainFile)
 	}
 
-	return bindable, nil
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	printer.Fprint(w, &token.FileSet{}, code)
+	return w.Flush()
 }
 
 func getPackage(packageName string) error {
@@ -94,9 +185,27 @@ func toCodeFile(pkg *cgo.Package) *ast.File {
 		List: append(cgo.IncludeComments("<stdlib.h>"), cgo.RawComments(pkg.CDefinitions()...)...),
 	}
 
+	imports := []string{"fmt", "reflect", "sync", "unsafe", "github.com/satori/go.uuid"}
+	if len(pkg.Interfaces()) > 0 || len(pkg.Seqs()) > 0 {
+		// host implementations of interfaces and sequences are released by finalizers
+		imports = append(imports, "runtime")
+	}
+	if len(pkg.Seqs()) > 0 {
+		// sequences are pulled with the iter package, which is only imported when needed
+		imports = append(imports, "iter")
+	}
+	if len(pkg.Structs()) > 0 {
+		// structs are encoded and decoded with encoding/json
+		imports = append(imports, cgo.JSON_PKG_PATH)
+	}
+	if len(pkg.Streams()) > 0 {
+		// host language streams report the end of a stream with io.EOF
+		imports = append(imports, "io")
+	}
+
 	declarations := []ast.Decl{
 		cImport,
-		cgo.Imports("fmt", "sync", "unsafe", "github.com/satori/go.uuid"), //, "strconv", "strings", "os"
+		cgo.Imports(imports...), //, "strconv", "strings", "os"
 		cgo.ImportsFromMap(pkg.ImportAliases()),
 		cgo.RefsStruct(),
 		cgo.CObjectStruct(),
@@ -104,12 +213,32 @@ func toCodeFile(pkg *cgo.Package) *ast.File {
 		cgo.IncrementRef(),
 		cgo.GetRef(),
 		cgo.GetUuidFromPtr(),
+		cgo.Handle(),
+		cgo.IfaceHandle(),
+		cgo.TypedNil(),
+		cgo.OpaqueHandle(),
+		cgo.OpaqueArg(),
+		cgo.OpaqueType(),
+		cgo.OpaqueParams(),
+		cgo.OpaqueCheck(),
+		cgo.ReleaseFuncVar(),
+		cgo.RegisterRelease(),
+		cgo.Release(),
 		cgo.Init(),
 		cgo.ErrorToString(),
 		cgo.CFree(),
 		cgo.IsErrorNil(),
+		cgo.DeepCopyFunc(),
+		cgo.HashFunc(),
+		cgo.HashKeyFunc(),
 	}
 
+	if len(pkg.Seqs()) > 0 {
+		declarations = append(declarations, cgo.SeqRuntime()...)
+	}
+	if len(pkg.Streams()) > 0 {
+		declarations = append(declarations, cgo.StreamRuntime()...)
+	}
 	declarations = append(declarations, pkg.ToAst()...)
 	declarations = append(declarations, cgo.MainFunc())
 	mainFile := &ast.File{
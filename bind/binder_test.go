@@ -0,0 +1,82 @@
+package bind
+
+import (
+	"testing"
+
+	"github.com/devigned/veil/bind/python"
+	"github.com/devigned/veil/cgo"
+	"github.com/devigned/veil/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBinder records the options it was created with
+type fakeBinder struct {
+	prefix string
+}
+
+func (b fakeBinder) Files(libName string) []string {
+	return []string{libName + ".fake"}
+}
+
+func (b fakeBinder) BuildSteps() []core.BuildStep {
+	return []core.BuildStep{core.BUILD_STEP_CGO}
+}
+
+func (b fakeBinder) Bind(outDir, libName string) error {
+	return nil
+}
+
+func greetPackage(t *testing.T) *cgo.Package {
+	pkg, err := cgo.NewPackageFromSources("github.com/foo/greet", map[string]string{
+		"greet.go": "package greet\nfunc Hello(name string) string { return name }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestRegister(t *testing.T) {
+	Register("registered", func(pkg *cgo.Package, options *core.Options) (core.Binder, error) {
+		return fakeBinder{prefix: options.String("prefix", "veil_")}, nil
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "registered")
+		registryMu.Unlock()
+	}()
+
+	assert.Contains(t, Targets(), "registered")
+	assert.Contains(t, Targets(), python.TARGET_NAME)
+	assert.Panics(t, func() { Register("registered", python.NewBinder) })
+	assert.Panics(t, func() { Register("nil", nil) })
+
+	options := core.NewOptions("registered")
+	options.Set("prefix", "greet_")
+	binder, err := NewBinder(greetPackage(t), "registered", options)
+	if assert.NoError(t, err) {
+		assert.Equal(t, fakeBinder{prefix: "greet_"}, binder)
+		assert.Equal(t, []string{"libgen.fake"}, binder.Files("libgen"))
+	}
+
+	options.Set("suffix", "_greet")
+	_, err = NewBinder(greetPackage(t), "registered", options)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Unknown options suffix")
+	}
+}
+
+func TestNewPythonBinderOptions(t *testing.T) {
+	binder, err := NewBinder(greetPackage(t), python.TARGET_NAME, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{python.FILE_NAME, python.STUB_FILE_NAME}, binder.Files("libgen"))
+		assert.Equal(t, []core.BuildStep{core.BUILD_STEP_SHARED_LIB}, binder.BuildSteps())
+	}
+
+	options := core.NewOptions(python.TARGET_NAME)
+	options.Set(python.OPTION_STRUCT_TAGS, "maybe")
+	_, err = NewBinder(greetPackage(t), python.TARGET_NAME, options)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), python.OPTION_STRUCT_TAGS)
+	}
+}
@@ -0,0 +1,147 @@
+package bind
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/devigned/veil/cgo"
+	"github.com/devigned/veil/core"
+	"github.com/devigned/veil/ir"
+)
+
+/*
+Targets other than the built in binders are generated by plugins, which are executables named veil-gen-<target>
+found on the PATH, in the way protoc finds protoc-gen-<lang>. For each target veil runs its plugin once, writing a
+PluginRequest as JSON to its stdin, and reads a PluginResponse as JSON from its stdout. The CGo shared library and
+its header are built before the plugin runs, and the options of the target are passed to it.
+
+	$ echo '{"version": "1", "target": "ruby", "lib_name": "libgen", ...}' | veil-gen-ruby
+	{"files": [{"name": "generated.rb", "content": "..."}]}
+
+A plugin reports errors it expects, such as an unsupported option, through the error of the response. Any other
+failure exits with a non-zero status, in which case the files are discarded and stderr is reported.
+*/
+
+const (
+	PLUGIN_PREFIX           = "veil-gen-"
+	PLUGIN_PROTOCOL_VERSION = "1"
+)
+
+// PluginRequest is what a plugin reads from stdin
+type PluginRequest struct {
+	// Version is the version of the plugin protocol
+	Version string `json:"version"`
+	Target  string `json:"target"`
+	// LibName is the name of the CGo shared library in the output directory
+	LibName string `json:"lib_name"`
+	// Header is the C header of the shared library
+	Header string `json:"header"`
+	// Options are the options of the target, such as package=mylib from --opt ruby:package=mylib
+	Options map[string]string `json:"options"`
+	IR      *ir.Package       `json:"ir"`
+}
+
+// PluginResponse is what a plugin writes to stdout
+type PluginResponse struct {
+	Files []*PluginFile `json:"files"`
+	// Error is set instead of Files when the plugin can't generate the binding
+	Error string `json:"error,omitempty"`
+}
+
+// PluginFile is a file generated by a plugin, whose name is relative to the output directory
+type PluginFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// pluginBinder binds a package by running the plugin of a target
+type pluginBinder struct {
+	target  string
+	path    string
+	pkg     *ir.Package
+	options map[string]string
+}
+
+// newPluginBinder finds the plugin of target on the PATH. Every option is passed on to the plugin.
+func newPluginBinder(pkg *cgo.Package, target string, options *core.Options) (core.Binder, error) {
+	path, err := exec.LookPath(PLUGIN_PREFIX + target)
+	if err != nil {
+		return nil, core.NewUserErrorF("I don't know how to create a binder for %s, and no %s plugin was found "+
+			"on the PATH", target, PLUGIN_PREFIX+target)
+	}
+	return &pluginBinder{target: target, path: path, pkg: ir.NewPackage(pkg), options: options.Values()}, nil
+}
+
+// Files returns nil, since the files of a plugin are only known once it runs
+func (b pluginBinder) Files(libName string) []string {
+	return nil
+}
+
+// BuildSteps returns the shared library, whose header is passed to the plugin
+func (b pluginBinder) BuildSteps() []core.BuildStep {
+	return []core.BuildStep{core.BUILD_STEP_SHARED_LIB}
+}
+
+// Bind runs the plugin and writes the files it generates to outDir
+func (b pluginBinder) Bind(outDir, libName string) error {
+	headerPath := filepath.Join(outDir, libName+".h")
+	header, err := os.ReadFile(headerPath)
+	if err != nil {
+		return core.NewSystemErrorF("Could not read the header of the shared library %s: %v", headerPath, err)
+	}
+
+	request, err := json.Marshal(&PluginRequest{
+		Version: PLUGIN_PROTOCOL_VERSION,
+		Target:  b.target,
+		LibName: libName,
+		Header:  string(header),
+		Options: b.options,
+		IR:      b.pkg,
+	})
+	if err != nil {
+		return core.NewSystemErrorF("Could not encode the request to plugin %s: %v", b.path, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(b.path)
+	cmd.Dir = outDir
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return core.NewUserErrorF("Plugin %s failed: %v\n%s", b.path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	response := PluginResponse{}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return core.NewUserErrorF("Plugin %s returned an invalid response: %v", b.path, err)
+	}
+	if response.Error != "" {
+		return core.NewUserErrorF("Plugin %s failed: %s", b.path, response.Error)
+	}
+	return writePluginFiles(outDir, response.Files)
+}
+
+// writePluginFiles writes the files generated by a plugin, which can't be written outside of outDir
+func writePluginFiles(outDir string, files []*PluginFile) error {
+	for _, file := range files {
+		name := filepath.Clean(filepath.FromSlash(file.Name))
+		if file.Name == "" || filepath.IsAbs(name) || name == ".." ||
+			strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return core.NewUserErrorF("Plugin file %q must be relative to the output directory", file.Name)
+		}
+
+		filePath := filepath.Join(outDir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return core.NewSystemErrorF("Could not create directory for %s: %v", filePath, err)
+		}
+		if err := os.WriteFile(filePath, []byte(file.Content), 0644); err != nil {
+			return core.NewSystemErrorF("Could not write %s: %v", filePath, err)
+		}
+	}
+	return nil
+}
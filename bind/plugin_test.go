@@ -0,0 +1,98 @@
+package bind
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/devigned/veil/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePlugin installs a veil-gen-<target> shell script on the PATH which saves its request next to itself
+func writePlugin(t *testing.T, target, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	dir := t.TempDir()
+	src := "#!/bin/sh\ncat > " + filepath.Join(dir, "request.json") + "\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, PLUGIN_PREFIX+target), []byte(src), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func bindWithPlugin(t *testing.T, target string, options *core.Options) (string, error) {
+	binder, err := NewBinder(greetPackage(t), target, options)
+	if err != nil {
+		return "", err
+	}
+
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outDir, "libgen.h"), []byte("extern char* Hello(char* name);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return outDir, binder.Bind(outDir, "libgen")
+}
+
+func TestPluginBinder(t *testing.T) {
+	dir := writePlugin(t, "fake",
+		`echo '{"files": [{"name": "greet/generated.txt", "content": "hello"}]}'`)
+	options := core.NewOptions("fake")
+	options.Set("package", "greet")
+
+	outDir, err := bindWithPlugin(t, "fake", options)
+	if !assert.NoError(t, err) {
+		return
+	}
+	content, err := os.ReadFile(filepath.Join(outDir, "greet", "generated.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+
+	data, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	request := PluginRequest{}
+	if assert.NoError(t, json.Unmarshal(data, &request)) {
+		assert.Equal(t, PLUGIN_PROTOCOL_VERSION, request.Version)
+		assert.Equal(t, "fake", request.Target)
+		assert.Equal(t, "libgen", request.LibName)
+		assert.Contains(t, request.Header, "Hello")
+		assert.Equal(t, map[string]string{"package": "greet"}, request.Options)
+		if assert.Len(t, request.IR.Funcs, 1) {
+			assert.Equal(t, "Hello", request.IR.Funcs[0].Name)
+		}
+	}
+}
+
+func TestPluginBinderErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"reported", `echo '{"error": "ruby 1.8 is not supported"}'`, "ruby 1.8 is not supported"},
+		{"exit", "echo 'boom' >&2; exit 3", "boom"},
+		{"invalid", "echo 'not json'", "invalid response"},
+		{"escape", `echo '{"files": [{"name": "../escaped.txt", "content": ""}]}'`, "relative to the output"},
+		{"absolute", `echo '{"files": [{"name": "/tmp/absolute.txt", "content": ""}]}'`, "relative to the output"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			writePlugin(t, c.name, c.script)
+			_, err := bindWithPlugin(t, c.name, nil)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+
+	_, err := bindWithPlugin(t, "missing-target", nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), PLUGIN_PREFIX+"missing-target")
+	}
+}
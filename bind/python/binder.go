@@ -5,15 +5,15 @@ import (
 	"fmt"
 	"github.com/devigned/veil/cgo"
 	"github.com/devigned/veil/core"
+	"github.com/devigned/veil/ir"
 	"github.com/emirpasic/gods/sets/hashset"
-	"go/token"
-	"go/types"
 	"log"
 	"os"
 	"os/exec"
 	"path"
 	"regexp"
 	"strings"
+	"text/template"
 )
 
 const (
@@ -21,6 +21,14 @@ const (
 	CFFI_HELPER_NAME = "_CffiHelper"
 	HEADER_FILE_NAME = "output.h"
 	FILE_NAME        = "generated.py"
+	TARGET_NAME      = "py3"
+
+	OPTION_ACCESSOR_METHODS = "accessor-methods"
+	OPTION_STRUCT_TAGS      = "struct-tags"
+	OPTION_NAMING           = "naming"
+
+	NAMING_SNAKE_CASE = "snake_case"
+	NAMING_GO         = "go"
 )
 
 var (
@@ -46,11 +54,27 @@ func init() {
 	for _, word := range words {
 		reserved_words.Add(word)
 	}
+	cgo.ReserveTagNames(words...)
+}
+
+// Options configure the Python binding
+type Options struct {
+	// KeepAccessorMethods keeps the method forms of getters and setters which are mapped to properties. A getter
+	// named like its property, such as Name(), is always hidden since the property takes its name.
+	KeepAccessorMethods bool
+	// UseStructTags names the properties of classes after the json and veil tags of the struct fields, rather
+	// than the snake case of the field names. A veil tag such as `veil:"name,readonly,skip"` takes precedence
+	// over the name of a json tag, and both `json:"-"` and `veil:"-"` hide the field.
+	UseStructTags bool
+	// Naming is NAMING_SNAKE_CASE to name functions, methods, params and properties after the snake case of their
+	// Go names, or NAMING_GO to keep the Go names. Classes always keep the Go names of their types.
+	Naming string
 }
 
 // Binder contains the data for generating a python 3 binding
 type Binder struct {
-	pkg *cgo.Package
+	pkg     *ir.Package
+	options Options
 }
 
 type TemplateData struct {
@@ -60,85 +84,149 @@ type TemplateData struct {
 	Classes        []*Class
 	Lists          []*List
 	Interfaces     []*Interface
+	ResultTuples   []*ResultTuple
 	CffiHelperName string
 	ReturnVarName  string
 	LibName        string
 }
 
-// NewBinder creates a new Binder for Python
-func NewBinder(pkg *cgo.Package) core.Binder {
+// NewBinder creates a new Binder for Python configured by the accessor-methods, struct-tags and naming options
+func NewBinder(pkg *cgo.Package, options *core.Options) (core.Binder, error) {
+	keepAccessorMethods, err := options.Bool(OPTION_ACCESSOR_METHODS, true)
+	if err != nil {
+		return nil, err
+	}
+	useStructTags, err := options.Bool(OPTION_STRUCT_TAGS, false)
+	if err != nil {
+		return nil, err
+	}
+	naming := options.String(OPTION_NAMING, NAMING_SNAKE_CASE)
+	if naming != NAMING_SNAKE_CASE && naming != NAMING_GO {
+		return nil, core.NewUserErrorF("Option %s of target %s must be %s or %s, not %q", OPTION_NAMING,
+			options.Target, NAMING_SNAKE_CASE, NAMING_GO, naming)
+	}
+	return NewBinderFromIR(ir.NewPackage(pkg), Options{
+		KeepAccessorMethods: keepAccessorMethods,
+		UseStructTags:       useStructTags,
+		Naming:              naming,
+	}), nil
+}
+
+// NewBinderFromIR creates a new Binder for Python from the IR of a package
+func NewBinderFromIR(pkg *ir.Package, options Options) core.Binder {
 	return &Binder{
-		pkg: pkg,
+		pkg:     pkg,
+		options: options,
 	}
 }
 
-func (p Binder) NewList(slice *cgo.Slice) *List {
-	v := types.NewVar(token.Pos(0), nil, "value", slice.Elem())
+// Files returns the Python module, which loads the shared library, and its type stubs
+func (p Binder) Files(libName string) []string {
+	return []string{FILE_NAME, STUB_FILE_NAME}
+}
+
+// BuildSteps returns the shared library, since the Python module is generated from its header
+func (p Binder) BuildSteps() []core.BuildStep {
+	return []core.BuildStep{core.BUILD_STEP_SHARED_LIB}
+}
+
+func (p Binder) NewList(slice *ir.Slice) *List {
+	v := &ir.Var{Name: "value", Type: slice.Elem}
 	return &List{
-		Slice:        slice,
-		MethodPrefix: slice.CGoName(),
+		slice:        slice,
+		MethodPrefix: slice.CName,
+		ViewClass:    viewClassName(slice.Elem),
 		InputFormat: func() string {
-			return InputFormat("value", slice.Elem())
+			return InputFormatWithLabel("value", "item", slice.Elem)
 		},
-		OutputFormat: p.NewParam(v, "value").ReturnFormatWithName,
+		InputArg:     CArgName("value", slice.Elem),
+		OutputFormat: NewParam(v, "value").ReturnFormatWithName,
 	}
 }
 
-func (p Binder) NewClass(s *cgo.Struct) *Class {
-	fields := []*Param{}
-	for i := 0; i < s.Struct().NumFields(); i++ {
-		field := s.Struct().Field(i)
-		param := p.NewParam(s.Struct().Field(i), fmt.Sprintf("param_%d", i))
-		if cgo.ShouldGenerateField(field) && !IsReservedWord(param.Name()) {
-			fields = append(fields, param)
+func (p Binder) NewClass(s *ir.Struct) *Class {
+	fields := []*Field{}
+	named := map[string]bool{}
+	for i, field := range s.Fields {
+		param := p.newFieldParam(field, fmt.Sprintf("param_%d", i))
+		tag := fieldTag(field, p.options.UseStructTags)
+		applyFieldTag(param, tag)
+		if !IsReservedWord(param.Name()) && !tag.Skip && !field.Hidden && !named[param.Name()] {
+			named[param.Name()] = true
+			fields = append(fields, &Field{
+				Param:      param,
+				GetterName: field.Getter,
+				SetterName: field.Setter,
+				RefName:    field.Ref,
+				Doc:        field.Doc,
+				Deprecated: field.Deprecated,
+				NotEncoded: IsNotEncoded(field.Tag),
+			})
 		}
 	}
 
-	constructors := []*Func{}
-	for _, f := range p.pkg.Funcs() {
-		if s.IsConstructor(f) {
-			constructors = append(constructors, p.ToConstructor(s, f))
+	initFields := []*Param{}
+	initNamed := map[string]bool{}
+	for _, field := range s.Fields {
+		if !field.Init {
+			continue
 		}
+		param := p.newFieldParam(field, fmt.Sprintf("param_%d", len(initFields)))
+		applyFieldTag(param, fieldTag(field, p.options.UseStructTags))
+		if initNamed[param.Name()] || field.Hidden {
+			// a tag can give two fields the same name, only the first of them is a keyword argument, and hidden
+			// fields are always passed as their zero value
+			param.ReadOnly = true
+		}
+		initNamed[param.Name()] = true
+		initFields = append(initFields, param)
 	}
 
-	methods := []*Func{}
-	for _, f := range s.ExportedMethods() {
-		fun := p.ToFunc(f)
-		if !IsReservedWord(fun.Name) {
-			methods = append(methods, fun)
-		}
+	constructors := []*Func{}
+	for _, constructor := range s.Constructors {
+		constructors = append(constructors, p.ToConstructor(constructor))
+	}
+
+	taken := map[string]bool{}
+	for _, field := range fields {
+		taken[field.Name()] = true
+	}
+	methods, properties := p.ToMethods(s.Methods, taken)
+
+	protocols := NewProtocols(className(s.Name, s.BindName), s.GoType, methods)
+	protocols.GoStr = true
+	if s.Comparable && protocols.Equal == nil {
+		protocols.EqualMethod = s.Symbols["equal"]
+		protocols.HashMethod = s.Symbols["hash"]
 	}
 
 	return &Class{
-		binder:       &p,
-		Struct:       s,
+		strct:        s,
 		Fields:       fields,
+		InitFields:   initFields,
 		Constructors: constructors,
 		Methods:      methods,
+		Properties:   properties,
+		Protocols:    protocols,
 	}
 }
 
-func (p Binder) NewInterface(i *cgo.Interface) *Interface {
-	methods := []*Func{}
-	for _, f := range i.ExportedMethods() {
-		fun := p.ToFunc(f)
-		if !IsReservedWord(fun.Name) {
-			methods = append(methods, fun)
+func (p Binder) NewInterface(i *ir.Interface) *Interface {
+	methods, properties := p.ToMethods(i.Methods, map[string]bool{})
+	protocols := NewProtocols(className(i.Name, i.BindName), i.GoType, methods)
+	protocols.GoStr = true
+	if len(i.Stream) > 0 {
+		// proxies of streams are Python file objects, so the Go methods move aside for the io.RawIOBase methods
+		for _, fun := range methods {
+			fun.ProxyName = "_go_" + fun.Name
 		}
 	}
 
 	return &Interface{
-		binder:    &p,
-		Interface: i,
-		Methods:   methods,
-	}
-}
-
-func (p *Binder) NewParam(v *types.Var, defaultName string) *Param {
-	return &Param{
-		underlying:  v,
-		binder:      p,
-		DefaultName: defaultName,
+		iface:      i,
+		Methods:    methods,
+		Properties: properties,
+		Protocols:  protocols,
 	}
 }
 
@@ -151,57 +239,68 @@ func (p Binder) Bind(outDir, libName string) error {
 		return core.NewSystemErrorF("Failed to generate Python CDefs: %v", err)
 	}
 
+	funcs, classes, interfaces := p.Funcs(), p.Classes(), p.Interfaces()
+	resultTuples, err := p.ResultTuples(funcs, classes, interfaces)
+	if err != nil {
+		return err
+	}
 	data := TemplateData{
 		CDef:           strings.Join(cdefText, "\n"),
-		Funcs:          p.Funcs(),
-		Classes:        p.Classes(),
+		Funcs:          funcs,
+		Classes:        classes,
 		Lists:          p.Lists(),
-		Interfaces:     p.Interfaces(),
+		Interfaces:     interfaces,
+		ResultTuples:   resultTuples,
 		CffiHelperName: CFFI_HELPER_NAME,
 		ReturnVarName:  RETURN_VAR_NAME,
 		LibName:        libName,
 	}
 
-	pythonFilePath := path.Join(outDir, FILE_NAME)
-	f, err := os.Create(pythonFilePath)
+	if err := writeTemplate(path.Join(outDir, FILE_NAME), pythonTemplate, data); err != nil {
+		return err
+	}
+	return writeTemplate(path.Join(outDir, STUB_FILE_NAME), stubTemplate, data)
+}
+
+// writeTemplate renders tmpl with data to the Python file at filePath and formats it
+func writeTemplate(filePath string, tmpl *template.Template, data TemplateData) error {
+	f, err := os.Create(filePath)
 	if err != nil {
-		return core.NewSystemErrorF("Unable to create %s", path.Join(outDir, FILE_NAME))
+		return core.NewSystemErrorF("Unable to create %s", filePath)
 	}
 
 	w := bufio.NewWriter(f)
-	err = pythonTemplate.Execute(w, data)
+	err = tmpl.Execute(w, data)
 	w.Flush()
 	f.Close()
 	if err != nil {
 		panic(err)
 	}
 
-	Format(pythonFilePath)
+	Format(filePath)
 
 	return nil
 }
 
 func (p Binder) Lists() []*List {
-	lists := []*List{}
-	for _, exp := range p.pkg.ExportedTypes() {
-		if slice, ok := exp.(cgo.Slice); ok {
-			lists = append(lists, p.NewList(&slice))
-		}
+	lists := make([]*List, len(p.pkg.Slices))
+	for idx, slice := range p.pkg.Slices {
+		lists[idx] = p.NewList(slice)
 	}
 	return lists
 }
 
 func (p Binder) Classes() []*Class {
-	classes := make([]*Class, len(p.pkg.Structs()))
-	for idx, s := range p.pkg.Structs() {
+	classes := make([]*Class, len(p.pkg.Structs))
+	for idx, s := range p.pkg.Structs {
 		classes[idx] = p.NewClass(s)
 	}
 	return classes
 }
 
 func (p Binder) Interfaces() []*Interface {
-	interfaces := make([]*Interface, len(p.pkg.Interfaces()))
-	for idx, i := range p.pkg.Interfaces() {
+	interfaces := make([]*Interface, len(p.pkg.Interfaces))
+	for idx, i := range p.pkg.Interfaces {
 		interfaces[idx] = p.NewInterface(i)
 	}
 	return interfaces
@@ -209,11 +308,8 @@ func (p Binder) Interfaces() []*Interface {
 
 func (p Binder) Funcs() []*Func {
 	funcs := []*Func{}
-	for _, f := range p.pkg.Funcs() {
-		if IsReservedWord(f.Name()) {
-			continue
-		}
-		if p.pkg.IsConstructor(f) {
+	for _, f := range p.pkg.Funcs {
+		if IsReservedWord(f.Name) {
 			continue
 		}
 		funcs = append(funcs, p.ToFunc(f))
@@ -221,36 +317,74 @@ func (p Binder) Funcs() []*Func {
 	return funcs
 }
 
-func (p Binder) ToConstructor(class *cgo.Struct, f *cgo.Func) *Func {
-	fun := p.ToGenericFunc(f)
-	fun.Name = core.ToSnake(class.ConstructorName(f))
+func (p Binder) ToConstructor(constructor *ir.Constructor) *Func {
+	fun := p.ToGenericFunc(constructor.Func)
+	if constructor.Func.BindName == "" {
+		fun.Name = p.pyName(constructor.Name)
+	}
 	return fun
 }
 
-func (p Binder) ToFunc(f *cgo.Func) *Func {
-	fun := p.ToGenericFunc(f)
-	fun.Name = core.ToSnake(f.Name())
-	return fun
+func (p Binder) ToFunc(f *ir.Func) *Func {
+	return p.ToGenericFunc(f)
 }
 
-func (p Binder) ToGenericFunc(f *cgo.Func) *Func {
-	pyParams := make([]*Param, f.Signature().Params().Len())
-	for i := 0; i < f.Signature().Params().Len(); i++ {
-		param := f.Signature().Params().At(i)
-		pyParams[i] = p.NewParam(param, fmt.Sprintf("param_%d", i))
+func (p Binder) ToGenericFunc(f *ir.Func) *Func {
+	pyParams := make([]*Param, len(f.Params))
+	for i, param := range f.Params {
+		pyParams[i] = p.newParam(param, fmt.Sprintf("param_%d", i))
 	}
 
-	pyResults := make([]*Param, f.Signature().Results().Len())
-	for i := 0; i < f.Signature().Results().Len(); i++ {
-		param := f.Signature().Results().At(i)
-		pyResults[i] = p.NewParam(param, fmt.Sprintf("r_%d", i))
+	pyResults := make([]*Param, len(f.Results))
+	for i, result := range f.Results {
+		pyResults[i] = p.newParam(result, fmt.Sprintf("r_%d", i))
 	}
-	return &Func{
+	fun := &Func{
 		fun:     f,
-		Name:    core.ToSnake(f.Name()),
+		Name:    p.pyName(f.Name),
 		Params:  pyParams,
 		Results: pyResults,
 	}
+	if f.BindName != "" {
+		fun.Name = f.BindName
+	}
+	if f.Options != nil {
+		// the variadic options are passed as keyword arguments
+		fun.Options = p.NewOptions(f.Options, pyParams)
+	}
+	return fun
+}
+
+// pyName returns the Python name of a Go func, param or property named goName
+func (p Binder) pyName(goName string) string {
+	if p.options.Naming == NAMING_GO {
+		return goName
+	}
+	return core.ToSnake(goName)
+}
+
+func (p Binder) newParam(v *ir.Var, defaultName string) *Param {
+	param := NewParam(v, defaultName)
+	param.KeepGoName = p.options.Naming == NAMING_GO
+	param.Stream = p.isStream(v.Type)
+	return param
+}
+
+// isStream returns true if typ is an interface of the package which Python file objects can be passed as
+func (p Binder) isStream(typ *ir.Type) bool {
+	for _, iface := range p.pkg.Interfaces {
+		if iface.GoType == typ.GoType && len(iface.Stream) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newFieldParam returns the param of a struct field, which takes the name the field is renamed to
+func (p Binder) newFieldParam(field *ir.Field, defaultName string) *Param {
+	param := p.newParam(&field.Var, defaultName)
+	param.PyName = field.BindName
+	return param
 }
 
 func (p Binder) cDefText(headerPath string) ([]string, error) {
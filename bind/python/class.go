@@ -1,25 +1,109 @@
 package python
 
 import (
-	"github.com/devigned/veil/cgo"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/devigned/veil/ir"
 )
 
 type Class struct {
-	*cgo.Struct
-	binder       *Binder
-	Fields       []*Param
+	strct        *ir.Struct
+	Fields       []*Field
+	InitFields   []*Param
 	Constructors []*Func
 	Methods      []*Func
+	Properties   []*Property
+	Protocols    *Protocols
+}
+
+// Field is a Python property backed by the accessors of a struct field
+type Field struct {
+	*Param
+	GetterName string
+	SetterName string
+	// RefName returns a live handle to fields with value semantics
+	RefName string
+	// Doc is the Go doc comment of the field, and Deprecated the notice of its "Deprecated: " paragraph
+	Doc        string
+	Deprecated string
+	// NotEncoded is true if the field is tagged json:"-", so it is left out of JSON, dicts and pickles
+	NotEncoded bool
 }
 
 func (c Class) Name() string {
-	return c.Named.Obj().Name()
+	return className(c.strct.Name, c.strct.BindName)
+}
+
+// className returns the name of the Python class of a struct or interface, which is its Go name unless it is
+// renamed
+func className(name, bindName string) string {
+	if bindName != "" {
+		return bindName
+	}
+	return name
+}
+
+// typeName returns the name of the Python class of a named type
+func typeName(typ *ir.Type) string {
+	return className(typ.Name, typ.BindName)
+}
+
+func (c Class) NewWithFieldsMethodName() string {
+	return c.strct.Symbols["new_with"]
+}
+
+func (c Class) ToStringMethodName() string {
+	return c.strct.Symbols["str"]
+}
+
+func (c Class) CopyMethodName() string {
+	return c.strct.Symbols["copy"]
+}
+
+func (c Class) DeepCopyMethodName() string {
+	return c.strct.Symbols["deepcopy"]
+}
+
+func (c Class) ToJsonMethodName() string {
+	return c.strct.Symbols["to_json"]
+}
+
+func (c Class) FromJsonMethodName() string {
+	return c.strct.Symbols["from_json"]
+}
+
+// PickleError returns the Python string raised as a TypeError when pickling the class, or an empty string if its
+// JSON encoding can be decoded back
+func (c Class) PickleError() string {
+	if c.strct.Undecodable == "" {
+		return ""
+	}
+	return strconv.Quote(fmt.Sprintf("cannot pickle %s: %s", c.Name(), c.strct.Undecodable))
 }
 
-func (c Class) MethodName(p *Param) string {
-	return c.FieldName(p.underlying)
+// InitKwargs returns the keyword arguments accepted when constructing the class, each defaulting to unset
+func (c Class) InitKwargs() string {
+	kwargs := []string{}
+	for _, field := range c.InitFields {
+		if field.IsSettable() {
+			kwargs = append(kwargs, field.Name()+"=_VEIL_UNSET")
+		}
+	}
+	return strings.Join(kwargs, ", ")
 }
 
-func (c Class) NewMethodName() string {
-	return c.Struct.NewMethodName()
+// InitArgs returns the arguments passed to Go after the mask when constructing the class. Fields which can't be
+// named or set in Python are always passed as their zero value.
+func (c Class) InitArgs() string {
+	args := []string{}
+	for _, field := range c.InitFields {
+		if !field.IsSettable() {
+			args = append(args, field.ZeroValue())
+		} else {
+			args = append(args, field.CArg())
+		}
+	}
+	return strings.Join(args, ", ")
 }
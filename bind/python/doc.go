@@ -0,0 +1,181 @@
+package python
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DOC_INDENT          = "    "
+	DEPRECATION_WARNING = "warnings.warn(%s, DeprecationWarning, stacklevel=2)"
+)
+
+// docstring renders a Go doc comment followed by sections such as Args: as a Python docstring, where every line
+// after the first is indented by indent. It returns an empty string if doc is empty, so only documented symbols
+// have docstrings.
+func docstring(doc string, sections []string, indent string) string {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return ""
+	}
+	text := strings.Join(append([]string{doc}, sections...), "\n\n")
+	text = strings.Replace(text, "\t", DOC_INDENT, -1)
+	text = strings.Replace(text, `\`, `\\`, -1)
+	text = strings.Replace(text, `"""`, `\"\"\"`, -1)
+
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		if strings.HasSuffix(text, `"`) {
+			text = text[:len(text)-1] + `\"`
+		}
+		return `"""` + text + `"""`
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return `"""` + strings.Join(lines, "\n") + "\n" + indent + `"""`
+}
+
+// docSection renders a section of a docstring such as Args: with one indented line per entry, or an empty string
+// if there are no entries
+func docSection(title string, entries []string) []string {
+	if len(entries) == 0 {
+		return nil
+	}
+	return []string{title + ":\n" + DOC_INDENT + strings.Join(entries, "\n"+DOC_INDENT)}
+}
+
+// deprecation returns the statement warning that the symbol named name is deprecated, or an empty string if
+// notice is empty
+func deprecation(name string, notice string) string {
+	if notice == "" {
+		return ""
+	}
+	return fmt.Sprintf(DEPRECATION_WARNING, strconv.Quote(name+" is deprecated: "+notice))
+}
+
+// Docstring returns the docstring of the function, with the Args, Returns and Raises sections describing its
+// Python signature
+func (f Func) Docstring(indent string) string {
+	args := []string{}
+	for _, param := range f.Params {
+		if !param.IsOut() {
+			args = append(args, fmt.Sprintf("%s (%s)", param.Name(), docType(param.PyType())))
+		}
+	}
+	for _, option := range f.Options {
+		if option.IsFlag() {
+			args = append(args, fmt.Sprintf("%s (bool, optional): applies %s when True", option.Name, option.Helper))
+		} else {
+			args = append(args, fmt.Sprintf("%s (optional): sets the option of %s", option.Name, option.Helper))
+		}
+	}
+
+	returns := []string{}
+	if tuple := f.ResultTuple(); tuple != nil {
+		fields := make([]string, len(tuple.Fields))
+		for i, field := range tuple.Fields {
+			fields[i] = fmt.Sprintf("%s (%s)", field.Name, docType(field.PyType))
+		}
+		returns = append(returns, tuple.Name+": "+strings.Join(fields, ", "))
+	} else {
+		for _, result := range append(append([]*Param{}, f.Results...), f.OutParams()...) {
+			if !result.IsError() {
+				returns = append(returns, docType(result.PyType()))
+			}
+		}
+	}
+
+	raises := []string{}
+	for _, result := range f.Results {
+		if result.IsError() {
+			raises = append(raises, "VeilError: if Go returns an error")
+			break
+		}
+	}
+
+	sections := append(docSection("Args", args), docSection("Returns", returns)...)
+	return docstring(f.fun.Doc, append(sections, docSection("Raises", raises)...), indent)
+}
+
+// Deprecation returns the statement warning that the function is deprecated, or an empty string if it isn't.
+// Methods are named after the class named owner, which is empty for functions.
+func (f Func) Deprecation(owner string) string {
+	if owner != "" {
+		return deprecation(owner+"."+f.Name, f.fun.Deprecated)
+	}
+	return deprecation(f.Name, f.fun.Deprecated)
+}
+
+// docType returns a Python type annotation without the quotes of forward references to classes
+func docType(pyType string) string {
+	return strings.Trim(pyType, `"`)
+}
+
+// Docstring returns the docstring of the class, noting the fields which are lost when it is pickled or converted
+// to JSON or a dict, as they are tagged json:"-", and why it can't be pickled if its JSON can't be decoded
+func (c Class) Docstring(indent string) string {
+	notes := []string{}
+	notEncoded := []string{}
+	for _, field := range c.Fields {
+		if field.NotEncoded {
+			notEncoded = append(notEncoded, field.Name())
+		}
+	}
+	if len(notEncoded) > 0 {
+		notes = append(notes, fmt.Sprintf("Pickling, to_json and to_dict go through encoding/json, which leaves out "+
+			"the fields tagged json:\"-\": %s. They are unpickled as their zero values.", strings.Join(notEncoded, ", ")))
+	}
+	if c.strct.Undecodable != "" {
+		notes = append(notes, fmt.Sprintf("It can't be pickled, since its JSON encoding can't be decoded back (%s).",
+			c.strct.Undecodable))
+	}
+
+	if len(notes) == 0 {
+		return docstring(c.strct.Doc, nil, indent)
+	}
+	if strings.TrimSpace(c.strct.Doc) == "" {
+		return docstring(notes[0], notes[1:], indent)
+	}
+	return docstring(c.strct.Doc, notes, indent)
+}
+
+// Deprecation returns the statement warning that the class is deprecated, or an empty string if it isn't
+func (c Class) Deprecation() string {
+	return deprecation(c.Name(), c.strct.Deprecated)
+}
+
+// Docstring returns the docstring of the property of the field
+func (f Field) Docstring(indent string) string {
+	return docstring(f.Doc, nil, indent)
+}
+
+// Deprecation returns the statement warning that the field of the class named className is deprecated, or an
+// empty string if it isn't
+func (f Field) Deprecation(className string) string {
+	return deprecation(className+"."+f.Name(), f.Deprecated)
+}
+
+// Docstring returns the docstring of the property, which is the doc comment of its getter
+func (p Property) Docstring(indent string) string {
+	return docstring(p.Getter.fun.Doc, nil, indent)
+}
+
+// Docstring returns the docstring of the class implementing the interface in Python, which is followed by how
+// Go values implementing it are returned
+func (iface Interface) Docstring(indent string) string {
+	usage := fmt.Sprintf("Subclass to implement %s in Python. Go values implementing %s are returned as %s.",
+		iface.Name(), iface.Name(), iface.ProxyName())
+	if iface.iface.Doc == "" {
+		return docstring(usage, nil, indent)
+	}
+	return docstring(iface.iface.Doc, []string{usage}, indent)
+}
+
+// Deprecation returns the statement warning that the interface is deprecated, or an empty string if it isn't
+func (iface Interface) Deprecation() string {
+	return deprecation(iface.Name(), iface.iface.Deprecated)
+}
@@ -2,33 +2,90 @@ package python
 
 import (
 	"fmt"
-	"github.com/devigned/veil/cgo"
+	"github.com/devigned/veil/ir"
 	"strings"
 )
 
 type Func struct {
-	fun     *cgo.Func
-	Name    string
-	Params  []*Param
-	Results []*Param
+	fun      *ir.Func
+	Name     string
+	Params   []*Param
+	Results  []*Param
+	Property string
+	IsSetter bool
+	// ProxyName is the name of the method on Python proxies of Go values when it differs from Name
+	ProxyName string
+	// Options are the keyword arguments setting Go functional options passed to the variadic last param
+	Options []*Option
+}
+
+// ProxyMethodName returns the name of the method on Python proxies of Go values
+func (f Func) ProxyMethodName() string {
+	if f.ProxyName != "" {
+		return f.ProxyName
+	}
+	return f.Name
 }
 
 func (f Func) InputTransforms() []string {
 	inputTranforms := []string{}
 	for _, param := range f.Params {
-		if format := param.InputFormat(); format != "" {
+		if param.IsOut() {
+			inputTranforms = append(inputTranforms, param.OutTransforms()...)
+		} else if format := param.InputFormat(); format != "" {
 			inputTranforms = append(inputTranforms, format)
 		}
 	}
+	if len(f.Options) > 0 {
+		inputTranforms = append(inputTranforms, OPTIONS_MASK_VAR_NAME+" = 0")
+		for _, option := range f.Options {
+			inputTranforms = append(inputTranforms, option.Transforms()...)
+		}
+	}
 	return inputTranforms
 }
 
 func (f Func) Call() string {
 	if f.IsBound() {
-		return f.fun.CName() + "(" + f.PrintArgs() + ")"
+		return f.fun.CName + "(" + f.CallArgs() + ")"
 	} else {
-		return f.fun.CName() + "(self.uuid_ptr(), " + f.PrintArgs() + ")"
+		return f.fun.CName + "(self.uuid_ptr(), " + f.CallArgs() + ")"
+	}
+}
+
+// CallArgs returns the args passed to C, which are the converted params followed by the options
+func (f Func) CallArgs() string {
+	if len(f.Options) == 0 {
+		return f.CArgs()
+	}
+	if len(f.Params) == 0 {
+		return optionsArgs(f.Options)
 	}
+	return f.CArgs() + ", " + optionsArgs(f.Options)
+}
+
+// CArgs returns the names of the params once they are converted for C by InputTransforms
+func (f Func) CArgs() string {
+	names := make([]string, len(f.Params))
+	for i, param := range f.Params {
+		names[i] = param.CArg()
+	}
+	return strings.Join(names, ", ")
+}
+
+// PrintParams returns the params of the Python function, which are the params other than out-params followed by
+// the options as keyword arguments
+func (f Func) PrintParams() string {
+	params := []string{}
+	for _, param := range f.Params {
+		if !param.IsOut() {
+			params = append(params, param.Name())
+		}
+	}
+	for _, option := range f.Options {
+		params = append(params, option.Kwarg())
+	}
+	return strings.Join(params, ", ")
 }
 
 func (f Func) PrintArgs() string {
@@ -39,24 +96,28 @@ func (f Func) PrintArgs() string {
 	return strings.Join(names, ", ")
 }
 
+// PrintReturns returns the statement returning the results other than errors followed by the out-params, as a
+// named tuple if there are several of them
 func (f Func) PrintReturns() string {
-	returns := ""
-	if len(f.Results) > 1 {
-		names := []string{}
-		for i := 0; i < len(f.Results); i++ {
-			result := f.Results[i]
-			if !cgo.ImplementsError(result.underlying.Type()) {
-				names = append(names, result.ReturnFormatWithName(fmt.Sprintf(RETURN_VAR_NAME+".r%d", i)))
-			}
+	values := []string{}
+	for i, result := range f.Results {
+		if result.IsError() {
+			continue
 		}
-		returns = strings.Join(names, ", ")
-	} else if len(f.Results) == 1 {
-		if !cgo.ImplementsError(f.Results[0].underlying.Type()) {
-			result := f.Results[0]
-			returns = result.ReturnFormatWithName(RETURN_VAR_NAME)
+		varName := RETURN_VAR_NAME
+		if len(f.Results) > 1 {
+			varName = fmt.Sprintf(RETURN_VAR_NAME+".r%d", i)
 		}
+		values = append(values, result.ReturnFormatWithName(varName))
+	}
+	for _, param := range f.OutParams() {
+		values = append(values, param.OutName())
 	}
 
+	returns := strings.Join(values, ", ")
+	if tuple := f.ResultTuple(); tuple != nil {
+		returns = tuple.Name + "(" + returns + ")"
+	}
 	if returns != "" {
 		return "return " + returns
 	} else {
@@ -64,6 +125,26 @@ func (f Func) PrintReturns() string {
 	}
 }
 
+// OutParams returns the params which are created by the Python function and returned after the results
+func (f Func) OutParams() []*Param {
+	outs := []*Param{}
+	for _, param := range f.Params {
+		if param.IsOut() {
+			outs = append(outs, param)
+		}
+	}
+	return outs
+}
+
+// ConstructorReturn returns the statement returning the value built by a constructor as an instance of cls
+func (f Func) ConstructorReturn() string {
+	varName := RETURN_VAR_NAME
+	if len(f.Results) > 1 {
+		varName += ".r0"
+	}
+	return "return " + fmt.Sprintf(STRUCT_OUTPUT_TRANSFORM, "cls", varName, "True")
+}
+
 func (f Func) ParamsLength() int {
 	return len(f.Params)
 }
@@ -75,11 +156,24 @@ func (f Func) ResultsLength() int {
 
 // IsBound returns true if the function is bound to a named type
 func (f Func) IsBound() bool {
-	return f.fun.BoundRecv == nil
+	return f.fun.Receiver == ""
 }
 
 func (f Func) RegistrationName() string {
-	return f.fun.Name()
+	return f.fun.Name
+}
+
+// CallbackInvocation returns the statement which invokes the Python implementation of the method from a callback,
+// assigning the result to ret. Accessors are invoked through their property.
+func (f Func) CallbackInvocation(obj string) string {
+	switch {
+	case f.Property != "" && f.IsSetter:
+		return fmt.Sprintf("ret = setattr(%s, \"%s\", %s)", obj, f.Property, f.PrintArgs())
+	case f.Property != "":
+		return fmt.Sprintf("ret = %s.%s", obj, f.Property)
+	default:
+		return fmt.Sprintf("ret = %s.%s(%s)", obj, f.Name, f.PrintArgs())
+	}
 }
 
 func (f Func) CallbackAttribute() string {
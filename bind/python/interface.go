@@ -1,26 +1,65 @@
 package python
 
 import (
-	"github.com/devigned/veil/cgo"
-	"go/ast"
+	"strings"
+
+	"github.com/devigned/veil/ir"
 )
 
 type Interface struct {
-	*cgo.Interface
-	binder  *Binder
-	Methods []*Func
+	iface      *ir.Interface
+	Methods    []*Func
+	Properties []*Property
+	Protocols  *Protocols
 }
 
 func (iface Interface) Name() string {
-	return iface.Interface.Name()
+	return className(iface.iface.Name, iface.iface.BindName)
 }
 
 func (iface Interface) CName() string {
-	return iface.Interface.CName()
+	return iface.iface.CName
+}
+
+func (iface Interface) ToStringMethodName() string {
+	return iface.iface.Symbols["str"]
+}
+
+// ProxyName is the name of the Python class for Go values implementing the interface
+func (iface Interface) ProxyName() string {
+	return proxyClassName(iface.Name())
+}
+
+func proxyClassName(ifaceName string) string {
+	return "_" + ifaceName + "Proxy"
+}
+
+// IsStream returns true if the interface can be implemented by a Python file object
+func (iface Interface) IsStream() bool {
+	return len(iface.iface.Stream) > 0
+}
+
+// HasStreamMethod returns true if the interface is a stream with the method named name
+func (iface Interface) HasStreamMethod(name string) bool {
+	for _, meth := range iface.iface.Stream {
+		if meth == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamMethodName returns the name of the function calling the stream method named name through a buffer
+func (iface Interface) StreamMethodName(name string) string {
+	return iface.iface.Symbols["stream_"+strings.ToLower(name)]
 }
 
-// ToAst returns the go/ast representation of the CGo wrapper of the named type
-func (iface Interface) ToAst() []ast.Decl {
-	decls := []ast.Decl{}
-	return decls
+// StreamAttributes is the Python tuple of file object methods an object needs to be adapted to the interface,
+// such as ("read", "close"). A file object may provide readinto rather than read.
+func (iface Interface) StreamAttributes() string {
+	names := []string{}
+	for _, meth := range iface.iface.Stream {
+		names = append(names, `"`+strings.ToLower(meth)+`"`)
+	}
+	return "(" + strings.Join(names, ", ") + ",)"
 }
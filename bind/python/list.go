@@ -1,29 +1,39 @@
 package python
 
 import (
-	"github.com/devigned/veil/cgo"
 	"github.com/devigned/veil/core"
+	"github.com/devigned/veil/ir"
 	"strings"
 )
 
 type List struct {
-	*cgo.Slice
+	slice        *ir.Slice
 	MethodPrefix string
+	ViewClass    string
 	InputFormat  func() string
+	// InputArg is the name of the value once it is converted by InputFormat
+	InputArg     string
 	OutputFormat func(string) string
 }
 
 func (l List) ListTypeName() string {
-	return l.Name() + "List"
+	return listTypeName(l.slice.Elem)
 }
 
-func (l List) Name() string {
-	typeString := l.Slice.ElementPackageAliasAndPath(nil)
-	typeString = strings.Replace(typeString, "[]", "SliceOf", -1)
+// listTypeName returns the name of the Python list class of slices of elem, such as HelloList for []Hello
+func listTypeName(elem *ir.Type) string {
+	named := elem
+	if named.Kind == ir.KIND_POINTER {
+		named = named.Elem
+	}
+	if named.BindName != "" {
+		return named.BindName + "List"
+	}
+	typeString := strings.Replace(elem.GoType, "[]", "SliceOf", -1)
 	splits := strings.Split(typeString, ".")
 	if len(splits) > 1 {
-		return splits[len(splits)-1]
+		return splits[len(splits)-1] + "List"
 	} else {
-		return core.ToCap(splits[0])
+		return core.ToCap(splits[0]) + "List"
 	}
 }
@@ -0,0 +1,114 @@
+package python
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/devigned/veil/ir"
+)
+
+const (
+	OPTIONS_MASK_VAR_NAME = "_veil_options_mask"
+	OPTION_KWARG_PREFIX   = "with_"
+)
+
+// Option is a keyword argument which sets a Go functional option through its With* helper. The keyword takes
+// the param of the helper, or a tuple of its params if it has several.
+type Option struct {
+	Name  string
+	Index int
+	// Helper is the Go function setting the option, such as WithTimeout
+	Helper string
+	Params []*Param
+}
+
+// NewOptions converts the helpers of Go functional options to keyword arguments, renaming keywords which would
+// collide with the params of the func or Python reserved words
+func (p Binder) NewOptions(options *ir.Options, params []*Param) []*Option {
+	taken := map[string]bool{}
+	for _, param := range params {
+		taken[param.Name()] = true
+	}
+
+	pyOptions := make([]*Option, len(options.Helpers))
+	for i, helper := range options.Helpers {
+		name := p.pyName(helper.Name)
+		if taken[name] || IsReservedWord(name) {
+			name = OPTION_KWARG_PREFIX + name
+		}
+
+		pyParams := make([]*Param, len(helper.Params))
+		for j, param := range helper.Params {
+			pyParams[j] = p.newParam(param, param.Name)
+		}
+		pyOptions[i] = &Option{Name: name, Index: i, Helper: helper.Func, Params: pyParams}
+	}
+	return pyOptions
+}
+
+// Kwarg returns the keyword argument of the option in a Python signature. It defaults to a private sentinel
+// rather than None, so None can be passed to helpers taking a nilable param.
+func (o Option) Kwarg() string {
+	return o.Name + "=_VEIL_UNSET"
+}
+
+// IsFlag reports whether the helper of the option takes no params, such as WithInsecure(). Its keyword argument
+// is a bool which only applies the option when True.
+func (o Option) IsFlag() bool {
+	return len(o.Params) == 0
+}
+
+// Transforms returns the single line statements which set the bit of the option in the mask and convert the
+// value of the keyword argument into the args of the helper, or their zero values when it wasn't passed
+func (o Option) Transforms() []string {
+	if o.IsFlag() {
+		return []string{
+			fmt.Sprintf("if %s is not _VEIL_UNSET and not isinstance(%s, bool): "+
+				"raise TypeError(\"%s must be a bool, not %%s\" %% type(%s).__name__)", o.Name, o.Name, o.Name, o.Name),
+			fmt.Sprintf("if %s is True: %s |= 1 << %d", o.Name, OPTIONS_MASK_VAR_NAME, o.Index),
+		}
+	}
+
+	transforms := []string{}
+	if len(o.Params) > 1 {
+		transforms = append(transforms, fmt.Sprintf("if %s is not _VEIL_UNSET and "+
+			"(not isinstance(%s, tuple) or len(%s) != %d): "+
+			"raise TypeError(\"%s must be a tuple of %d values, not %%r\" %% (%s,))",
+			o.Name, o.Name, o.Name, len(o.Params), o.Name, len(o.Params), o.Name))
+	}
+	transforms = append(transforms,
+		fmt.Sprintf("if %s is not _VEIL_UNSET: %s |= 1 << %d", o.Name, OPTIONS_MASK_VAR_NAME, o.Index))
+	for i, param := range o.Params {
+		value := o.Name
+		if len(o.Params) > 1 {
+			value = fmt.Sprintf("%s[%d]", o.Name, i)
+		}
+		transforms = append(transforms,
+			fmt.Sprintf("%s = %s if %s is _VEIL_UNSET else %s", param.Name(), param.ZeroValue(), o.Name, value))
+		if cArg := param.CArg(); cArg != param.Name() {
+			transforms = append(transforms, fmt.Sprintf("%s = %s", cArg, param.Name()))
+		}
+		if format := param.InputFormatWithNameAndLabel(param.Name(), o.Name); format != "" {
+			transforms = append(transforms, fmt.Sprintf("if %s is not _VEIL_UNSET: %s", o.Name, format))
+		}
+	}
+	return transforms
+}
+
+// Args returns the args passed to C for the option
+func (o Option) Args() []string {
+	args := make([]string, len(o.Params))
+	for i, param := range o.Params {
+		args[i] = param.CArg()
+	}
+	return args
+}
+
+// optionsArgs returns the mask followed by the args of every option
+func optionsArgs(options []*Option) string {
+	args := []string{OPTIONS_MASK_VAR_NAME}
+	for _, option := range options {
+		args = append(args, option.Args()...)
+	}
+	return strings.Join(args, ", ")
+}
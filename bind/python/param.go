@@ -2,35 +2,168 @@ package python
 
 import (
 	"fmt"
-	"github.com/devigned/veil/cgo"
 	"github.com/devigned/veil/core"
-	"go/types"
+	"github.com/devigned/veil/ir"
+	"math"
 	"strconv"
+	"strings"
 )
 
 const (
-	STRING_OUTPUT_TRANSFORM = "_CffiHelper.c2py_string(%s)"
-	STRING_INPUT_TRANSFORM  = "%s = _CffiHelper.py2c_string(%s)"
-	STRUCT_INPUT_TRANSFORM  = "%s = _CffiHelper.py2c_veil_object(%s)"
-	STRUCT_OUTPUT_TRANSFORM = "%s(uuid_ptr=%s, tracked=%s)"
+	STRING_OUTPUT_TRANSFORM      = "_CffiHelper.c2py_string(%s)"
+	STRING_INPUT_TRANSFORM       = "%s = _CffiHelper.py2c_string(%s)"
+	STRUCT_INPUT_TRANSFORM       = "%s = _CffiHelper.py2c_veil_object(%s)"
+	STRUCT_VALUE_INPUT_TRANSFORM = "%s = _CffiHelper.py2c_veil_value(%s, \"%s\")"
+	STRUCT_OUTPUT_TRANSFORM      = "_CffiHelper.c2py_veil_object(%s, %s, tracked=%s)"
+	INTERFACE_INPUT_TRANSFORM    = "%s = _CffiHelper.py2c_interface(%s, %s)"
+	INTERFACE_OUTPUT_TRANSFORM   = "_CffiHelper.c2py_interface(%s, %s, tracked=%s)"
+	OPAQUE_INPUT_TRANSFORM       = "%s = _CffiHelper.py2c_go_opaque(%s, \"%s\", %s)"
+	OPAQUE_OUTPUT_TRANSFORM      = "_CffiHelper.c2py_veil_object(GoOpaque, %s, tracked=%s)"
+	SEQ_OUTPUT_TRANSFORM         = "_CffiHelper.c2py_seq(%s, _CffiHelper.lib.%s, lambda values: %s)"
+	SEQ_ERROR_TRANSFORM          = "_CffiHelper.raise_if_error(%s, %s)"
+	INT_INPUT_TRANSFORM          = "%s = _CffiHelper.py2c_int(%s, \"%s\", \"%s\", %s, %s)"
+	FLOAT_INPUT_TRANSFORM        = "%s = _CffiHelper.py2c_float(%s, \"%s\", \"%s\", %s)"
+	CALLBACK_BASIC_TRANSFORM     = "ffi.cast(\"%s *\", %s)[0]"
+	OUT_PARAM_TRANSFORM          = "%s = %s()"
+	OUT_PARAM_SUFFIX             = "_out"
+	// C_ARG_PREFIX names the local holding the handle of a Python object passed to C. The object stays bound
+	// to its own name, so it isn't released before the call returns.
+	C_ARG_PREFIX = "_c_"
 )
 
+// intRanges are the bounds of the Go integer types. int, uint and uintptr are 64 bits wide, which cgo requires
+// of the platforms it builds on.
+var intRanges = map[string][2]string{
+	"int":     {strconv.FormatInt(math.MinInt64, 10), strconv.FormatInt(math.MaxInt64, 10)},
+	"int8":    {strconv.FormatInt(math.MinInt8, 10), strconv.FormatInt(math.MaxInt8, 10)},
+	"int16":   {strconv.FormatInt(math.MinInt16, 10), strconv.FormatInt(math.MaxInt16, 10)},
+	"int32":   {strconv.FormatInt(math.MinInt32, 10), strconv.FormatInt(math.MaxInt32, 10)},
+	"int64":   {strconv.FormatInt(math.MinInt64, 10), strconv.FormatInt(math.MaxInt64, 10)},
+	"uint":    {"0", strconv.FormatUint(math.MaxUint64, 10)},
+	"uint8":   {"0", strconv.FormatUint(math.MaxUint8, 10)},
+	"uint16":  {"0", strconv.FormatUint(math.MaxUint16, 10)},
+	"uint32":  {"0", strconv.FormatUint(math.MaxUint32, 10)},
+	"uint64":  {"0", strconv.FormatUint(math.MaxUint64, 10)},
+	"uintptr": {"0", strconv.FormatUint(math.MaxUint64, 10)},
+}
+
+// cTypes are the C types Go basic values are passed to and returned from host language callbacks as
+var cTypes = map[string]string{
+	"bool":    "_Bool",
+	"int":     "long long",
+	"int8":    "signed char",
+	"int16":   "short",
+	"int32":   "int",
+	"int64":   "long long",
+	"uint":    "unsigned long long",
+	"uint8":   "unsigned char",
+	"uint16":  "unsigned short",
+	"uint32":  "unsigned int",
+	"uint64":  "unsigned long long",
+	"uintptr": "unsigned long long",
+	"float32": "float",
+	"float64": "double",
+}
+
 type Param struct {
-	underlying  *types.Var
-	binder      *Binder
+	underlying  *ir.Var
 	DefaultName string
+	// PyName overrides the Python name of the param, such as the name given by the struct tag of a field
+	PyName string
+	// ReadOnly params can't be set from Python
+	ReadOnly bool
+	// KeepGoName names the param after its Go name rather than the snake case of it
+	KeepGoName bool
+	// Stream is true if the param is a stream interface, which Python file objects are adapted to
+	Stream bool
+}
+
+func NewParam(v *ir.Var, defaultName string) *Param {
+	return &Param{
+		underlying:  v,
+		DefaultName: defaultName,
+	}
 }
 
 func (p Param) Name() string {
+	if p.PyName != "" {
+		return p.PyName
+	}
 	name := p.DefaultName
-	if p.underlying.Name() != "" {
-		name = p.underlying.Name()
+	if p.underlying.Name != "" {
+		name = p.underlying.Name
+	}
+	if p.KeepGoName {
+		return name
 	}
 	return core.ToSnake(name)
 }
 
+// IsOut returns true if the param is an out-param, which points to a struct the Python function creates
+func (p Param) IsOut() bool {
+	return p.underlying.Out
+}
+
+// OutName returns the name of the Python variable holding the struct created for an out-param
+func (p Param) OutName() string {
+	return p.Name() + OUT_PARAM_SUFFIX
+}
+
+// OutTransforms returns the statements creating the struct of an out-param and passing it to C
+func (p Param) OutTransforms() []string {
+	return []string{
+		fmt.Sprintf(OUT_PARAM_TRANSFORM, p.OutName(), typeName(p.underlying.Type.Elem)),
+		fmt.Sprintf(STRUCT_INPUT_TRANSFORM, p.CArg(), p.OutName()),
+	}
+}
+
+// IsReservedWord returns true if the name of the param can't be used as a Python identifier
+func (p Param) IsReservedWord() bool {
+	return IsReservedWord(p.Name())
+}
+
+// IsSettable returns true if the param can be set from Python
+func (p Param) IsSettable() bool {
+	return !p.ReadOnly && !p.IsReservedWord()
+}
+
+// ZeroValue returns the Python placeholder passed to C for the param when it isn't set
+func (p Param) ZeroValue() string {
+	typ := p.underlying.Type
+	if typ.Kind == ir.KIND_POINTER {
+		typ = typ.Elem
+	}
+	if typ.Kind == ir.KIND_BASIC {
+		switch typ.Basic {
+		case "string":
+			return "ffi.NULL"
+		case "bool":
+			return "False"
+		default:
+			return "0"
+		}
+	}
+	return "ffi.NULL"
+}
+
+// CType returns the C type of a numeric or boolean param, or an empty string for any other param
+func (p Param) CType() string {
+	if p.underlying.Type.Kind == ir.KIND_BASIC {
+		return cTypes[p.underlying.Type.Basic]
+	}
+	return ""
+}
+
+// CallbackFormat converts the param of a host language callback from the pointer Go passes it as
+func (p Param) CallbackFormat() string {
+	if ctype := p.CType(); ctype != "" {
+		return fmt.Sprintf(CALLBACK_BASIC_TRANSFORM, ctype, p.Name())
+	}
+	return p.ReturnFormatUntracked()
+}
+
 func (p Param) IsError() bool {
-	return cgo.ImplementsError(p.underlying.Type())
+	return p.underlying.Type.Error
 }
 
 func (p Param) ReturnFormatWithName(varName string) string {
@@ -38,52 +171,159 @@ func (p Param) ReturnFormatWithName(varName string) string {
 }
 
 func (p Param) ReturnFormatWithNameAndTracked(varName string, tracked bool) string {
-	return p.returnFormatWithTypeAndNameAndTracked(p.underlying.Type(), varName, tracked)
+	return returnFormat(p.underlying.Type, varName, tracked)
 }
 
-func (p Param) returnFormatWithTypeAndNameAndTracked(typ types.Type, varName string, tracked bool) string {
+func returnFormat(typ *ir.Type, varName string, tracked bool) string {
 	trackedBoolStr := core.ToCap(strconv.FormatBool(tracked))
-	switch t := typ.(type) {
-	case *types.Basic:
-		if t.Kind() == types.String {
+	switch typ.Kind {
+	case ir.KIND_SEQ:
+		return seqReturnFormat(typ, varName)
+	case ir.KIND_OPAQUE:
+		return fmt.Sprintf(OPAQUE_OUTPUT_TRANSFORM, varName, trackedBoolStr)
+	case ir.KIND_BASIC:
+		if typ.Basic == "string" {
 			return fmt.Sprintf(STRING_OUTPUT_TRANSFORM, varName)
 		}
 		return varName
-	case *types.Named:
-		if cgo.ImplementsError(t) {
-			return fmt.Sprintf(STRUCT_OUTPUT_TRANSFORM, "VeilError", varName, trackedBoolStr)
-		} else if _, ok := t.Underlying().(*types.Struct); ok {
-			class := p.binder.NewClass(cgo.NewStruct(t))
-			return fmt.Sprintf(STRUCT_OUTPUT_TRANSFORM, class.Name(), varName, trackedBoolStr)
-		} else {
+	case ir.KIND_STRUCT, ir.KIND_INTERFACE, ir.KIND_NAMED:
+		if !isNamed(typ) {
 			return varName
+		} else if typ.Error {
+			return fmt.Sprintf(STRUCT_OUTPUT_TRANSFORM, "VeilError", varName, trackedBoolStr)
+		} else if typ.Kind == ir.KIND_STRUCT {
+			return fmt.Sprintf(STRUCT_OUTPUT_TRANSFORM, typeName(typ), varName, trackedBoolStr)
+		} else if typ.Kind == ir.KIND_INTERFACE {
+			return fmt.Sprintf(INTERFACE_OUTPUT_TRANSFORM, proxyClassName(typeName(typ)), varName, trackedBoolStr)
 		}
-	case *types.Slice:
-		slice := p.binder.NewList(cgo.NewSlice(t.Elem()))
-		return fmt.Sprintf(STRUCT_OUTPUT_TRANSFORM, slice.ListTypeName(), varName, trackedBoolStr)
-	case *types.Pointer:
-		return p.returnFormatWithTypeAndNameAndTracked(t.Elem(), varName, tracked)
+		return varName
+	case ir.KIND_SLICE:
+		return fmt.Sprintf(STRUCT_OUTPUT_TRANSFORM, listTypeName(typ.Elem), varName, trackedBoolStr)
+	case ir.KIND_POINTER:
+		return returnFormat(typ.Elem, varName, tracked)
 	default:
 		return varName
 	}
 }
 
-func InputFormat(varName string, typ types.Type) string {
-	switch t := typ.(type) {
-	case *types.Basic:
-		if t.Kind() == types.String {
-			return fmt.Sprintf(STRING_INPUT_TRANSFORM, varName, varName)
+// seqReturnFormat converts a sequence into a generator. The values pulled from Go are converted from the fields
+// of the next function result, which follow the ok flag. Values of an iter.Seq2 are yielded as tuples, unless the
+// second value is an error, which is raised instead.
+func seqReturnFormat(seq *ir.Type, varName string) string {
+	values := make([]string, len(seq.Elems))
+	for i, elem := range seq.Elems {
+		values[i] = returnFormat(elem, fmt.Sprintf("values.r%d", i+1), true)
+	}
+
+	convert := values[0]
+	if len(seq.Elems) == 2 {
+		if seq.Elems[1].Error {
+			convert = fmt.Sprintf(SEQ_ERROR_TRANSFORM, "values.r2", values[0])
+		} else {
+			convert = "(" + strings.Join(values, ", ") + ")"
+		}
+	}
+	return fmt.Sprintf(SEQ_OUTPUT_TRANSFORM, varName, seq.Next, convert)
+}
+
+// ViewClassName returns the Python class used to view the param in place within its container, or an empty
+// string if the param is always copied
+func (p Param) ViewClassName() string {
+	return viewClassName(p.underlying.Type)
+}
+
+func viewClassName(typ *ir.Type) string {
+	if !typ.ValueSemantics {
+		return ""
+	}
+	switch typ.Kind {
+	case ir.KIND_STRUCT:
+		return typeName(typ)
+	case ir.KIND_SLICE:
+		return listTypeName(typ.Elem)
+	}
+	return ""
+}
+
+// isNamed returns true for named types, as opposed to unnamed types such as interface{}
+func isNamed(typ *ir.Type) bool {
+	return typ.Name != ""
+}
+
+// isInteger returns true if basic names a Go integer type
+func isInteger(basic string) bool {
+	_, ok := intRanges[basic]
+	return ok
+}
+
+func InputFormat(varName string, typ *ir.Type) string {
+	return InputFormatWithLabel(varName, varName, typ)
+}
+
+// InputFormatWithLabel converts varName for C, where label names the value in the errors raised when it has the
+// wrong type or doesn't fit in its Go type. The converted value is in CArgName(varName, typ).
+func InputFormatWithLabel(varName string, label string, typ *ir.Type) string {
+	return inputFormat(varName, label, typ, "")
+}
+
+// inputFormat converts varName for C. Opaque handles are checked against the param of the wrapper registered
+// under check, if there is one, so values of another Go type are rejected before the call.
+func inputFormat(varName string, label string, typ *ir.Type, check string) string {
+	cArg := CArgName(varName, typ)
+	switch typ.Kind {
+	case ir.KIND_OPAQUE, ir.KIND_SEQ:
+		checkArg := "None"
+		if check != "" {
+			checkArg = strconv.Quote(check)
+		}
+		return fmt.Sprintf(OPAQUE_INPUT_TRANSFORM, cArg, varName, label, checkArg)
+	case ir.KIND_BASIC:
+		if typ.Basic == "string" {
+			return fmt.Sprintf(STRING_INPUT_TRANSFORM, cArg, varName)
 		}
-	case *types.Named, *types.Slice, *types.Interface:
-		return fmt.Sprintf(STRUCT_INPUT_TRANSFORM, varName, varName)
-	case *types.Pointer:
-		if _, ok := t.Elem().(*types.Named); ok {
-			return fmt.Sprintf(STRUCT_INPUT_TRANSFORM, varName, varName)
+		if bounds, ok := intRanges[typ.Basic]; ok {
+			return fmt.Sprintf(INT_INPUT_TRANSFORM, cArg, varName, label, typ.GoType, bounds[0], bounds[1])
+		}
+		switch typ.Basic {
+		case "float32":
+			limit := strconv.FormatFloat(math.MaxFloat32, 'g', -1, 64)
+			return fmt.Sprintf(FLOAT_INPUT_TRANSFORM, cArg, varName, label, typ.GoType, limit)
+		case "float64":
+			return fmt.Sprintf(FLOAT_INPUT_TRANSFORM, cArg, varName, label, typ.GoType, "None")
+		}
+	case ir.KIND_STRUCT:
+		// struct values can't be nil, so None is rejected before crossing the bridge
+		return fmt.Sprintf(STRUCT_VALUE_INPUT_TRANSFORM, cArg, varName, label)
+	case ir.KIND_INTERFACE:
+		if isNamed(typ) && !typ.Error {
+			return fmt.Sprintf(INTERFACE_INPUT_TRANSFORM, cArg, varName, typeName(typ))
+		}
+		return fmt.Sprintf(STRUCT_INPUT_TRANSFORM, cArg, varName)
+	case ir.KIND_NAMED, ir.KIND_SLICE:
+		return fmt.Sprintf(STRUCT_INPUT_TRANSFORM, cArg, varName)
+	case ir.KIND_POINTER:
+		if isNamed(typ.Elem) {
+			return fmt.Sprintf(STRUCT_INPUT_TRANSFORM, cArg, varName)
 		}
 	}
 	return ""
 }
 
+// CArgName returns the name of the local holding the C value of varName once it is converted by InputFormat.
+// Python objects are converted into a local of their own, so the object is still referenced, and its Go value
+// still tracked, while Go uses the handle. Other values are converted in place.
+func CArgName(varName string, typ *ir.Type) string {
+	switch typ.Kind {
+	case ir.KIND_OPAQUE, ir.KIND_SEQ, ir.KIND_STRUCT, ir.KIND_INTERFACE, ir.KIND_NAMED, ir.KIND_SLICE:
+		return C_ARG_PREFIX + varName
+	case ir.KIND_POINTER:
+		if isNamed(typ.Elem) {
+			return C_ARG_PREFIX + varName
+		}
+	}
+	return varName
+}
+
 func (p Param) ReturnFormatUntracked() string {
 	return p.ReturnFormatWithNameAndTracked(p.Name(), false)
 }
@@ -93,9 +333,25 @@ func (p Param) ReturnFormat() string {
 }
 
 func (p Param) InputFormat() string {
-	return InputFormat(p.Name(), p.underlying.Type())
+	return inputFormat(p.Name(), p.Name(), p.underlying.Type, p.underlying.Check)
+}
+
+// CArg returns the name of the local holding the C value of the param once it is converted by InputFormat
+func (p Param) CArg() string {
+	return CArgName(p.Name(), p.underlying.Type)
+}
+
+// CArgWithName returns the name of the local holding the C value of the variable name holding the param
+func (p Param) CArgWithName(name string) string {
+	return CArgName(name, p.underlying.Type)
 }
 
 func (p Param) InputFormatWithName(name string) string {
-	return InputFormat(name, p.underlying.Type())
+	return InputFormat(name, p.underlying.Type)
+}
+
+// InputFormatWithNameAndLabel converts the variable name holding the value of the param, which is called label
+// in errors
+func (p Param) InputFormatWithNameAndLabel(name string, label string) string {
+	return InputFormatWithLabel(name, label, p.underlying.Type)
 }
@@ -0,0 +1,107 @@
+package python
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/devigned/veil/ir"
+)
+
+// Property is a Python property backed by a Go getter and setter method pair, such as Name() / SetName(string)
+// or GetName() / SetName(string)
+type Property struct {
+	Name   string
+	Getter *Func
+	Setter *Func
+}
+
+// ToMethods converts Go methods to Python methods and maps getter and setter pairs to properties. Names in taken
+// are already used by the class, so no property will be created with those names.
+func (p Binder) ToMethods(methods []*ir.Func, taken map[string]bool) ([]*Func, []*Property) {
+	funcs := []*Func{}
+	byGoName := map[string]*Func{}
+	for _, f := range methods {
+		fun := p.ToFunc(f)
+		if !IsReservedWord(fun.Name) {
+			funcs = append(funcs, fun)
+			byGoName[f.Name] = fun
+			taken[fun.Name] = true
+		}
+	}
+
+	properties := []*Property{}
+	for _, getter := range funcs {
+		if getter.Property != "" || !isGetter(getter.fun) {
+			continue
+		}
+
+		base := getter.fun.Name
+		if trimmed := strings.TrimPrefix(base, "Get"); trimmed != "" && unicode.IsUpper([]rune(trimmed)[0]) {
+			base = trimmed
+		}
+
+		setter, ok := byGoName["Set"+base]
+		if !ok || setter.Property != "" || !isSetter(setter.fun, getter.fun.Results[0].Type) {
+			continue
+		}
+
+		name := p.pyName(base)
+		if IsReservedWord(name) || (taken[name] && getter.Name != name) {
+			continue
+		}
+
+		getter.Property = name
+		setter.Property = name
+		setter.IsSetter = true
+		properties = append(properties, &Property{Name: name, Getter: getter, Setter: setter})
+	}
+
+	for _, fun := range funcs {
+		if fun.Property != "" && (!p.options.KeepAccessorMethods || fun.Name == fun.Property) {
+			fun.Name = "_" + fun.Name
+		}
+	}
+
+	return funcs, properties
+}
+
+// isGetter returns true for methods without params returning a single value, optionally followed by an error
+func isGetter(f *ir.Func) bool {
+	if numParams(f) != 0 {
+		return false
+	}
+	switch len(f.Results) {
+	case 1:
+		return !f.Results[0].Type.Error
+	case 2:
+		return !f.Results[0].Type.Error && f.Results[1].Type.Error
+	}
+	return false
+}
+
+// isSetter returns true for methods with a single param of type t, optionally returning an error
+func isSetter(f *ir.Func, t *ir.Type) bool {
+	if numParams(f) != 1 || !sameType(f.Params[0].Type, t) {
+		return false
+	}
+	switch len(f.Results) {
+	case 0:
+		return true
+	case 1:
+		return f.Results[0].Type.Error
+	}
+	return false
+}
+
+// numParams returns the number of params of the Go func, counting the variadic functional options
+func numParams(f *ir.Func) int {
+	if f.Options != nil {
+		return len(f.Params) + 1
+	}
+	return len(f.Params)
+}
+
+// sameType returns true if a and b are the same Go type
+func sameType(a, b *ir.Type) bool {
+	return a.Kind == b.Kind && a.GoType == b.GoType
+}
@@ -0,0 +1,122 @@
+package python
+
+import (
+	"github.com/devigned/veil/ir"
+)
+
+// Protocols are the Python dunder methods a class implements through well known Go methods
+type Protocols struct {
+	// ClassName is the class other values must be instances of to be compared
+	ClassName string
+	// GoStr is true if the class defines __go_str__, which is used for __repr__
+	GoStr bool
+	// EqualMethod and HashMethod are the C functions comparing and hashing values with Go ==
+	EqualMethod string
+	HashMethod  string
+	// String() string
+	String *Func
+	// Equal(T) bool
+	Equal *Func
+	// Close() or Close() error
+	Close *Func
+	// Len() int with At(int) T
+	Len *Func
+	At  *Func
+	// Less(T) bool
+	Less *Func
+	// Compare(T) int
+	Compare *Func
+	// Next() bool with Value() T and optionally Err() error
+	Next  *Func
+	Value *Func
+	Err   *Func
+}
+
+// HasEquality returns true if the class defines __eq__
+func (p Protocols) HasEquality() bool {
+	return p.Equal != nil || p.EqualMethod != ""
+}
+
+// HasOrdering returns true if the class defines rich comparisons
+func (p Protocols) HasOrdering() bool {
+	return p.Less != nil || p.Compare != nil
+}
+
+// NewProtocols finds the methods of the Go type goType which implement Python protocols
+func NewProtocols(className string, goType string, methods []*Func) *Protocols {
+	protocols := &Protocols{ClassName: className}
+	for _, fun := range methods {
+		params, results := fun.fun.Params, fun.fun.Results
+		if fun.fun.Options != nil {
+			// funcs taking functional options don't match any protocol
+			continue
+		}
+		switch fun.fun.Name {
+		case "String":
+			if len(params) == 0 && len(results) == 1 && isBasic(results[0].Type, "string") {
+				protocols.String = fun
+			}
+		case "Equal":
+			if len(params) == 1 && isSelf(params[0].Type, goType) &&
+				len(results) == 1 && isBasic(results[0].Type, "bool") {
+				protocols.Equal = fun
+			}
+		case "Close":
+			if len(params) == 0 && (len(results) == 0 || (len(results) == 1 && results[0].Type.Error)) {
+				protocols.Close = fun
+			}
+		case "Len":
+			if len(params) == 0 && len(results) == 1 && isBasic(results[0].Type, "int") {
+				protocols.Len = fun
+			}
+		case "At":
+			if len(params) == 1 && isBasic(params[0].Type, "int") && len(results) == 1 {
+				protocols.At = fun
+			}
+		case "Less":
+			if len(params) == 1 && isSelf(params[0].Type, goType) &&
+				len(results) == 1 && isBasic(results[0].Type, "bool") {
+				protocols.Less = fun
+			}
+		case "Compare":
+			if len(params) == 1 && isSelf(params[0].Type, goType) &&
+				len(results) == 1 && isBasic(results[0].Type, "int") {
+				protocols.Compare = fun
+			}
+		case "Next":
+			if len(params) == 0 && len(results) == 1 && isBasic(results[0].Type, "bool") {
+				protocols.Next = fun
+			}
+		case "Value":
+			if len(params) == 0 && len(results) == 1 && !results[0].Type.Error {
+				protocols.Value = fun
+			}
+		case "Err":
+			if len(params) == 0 && len(results) == 1 && results[0].Type.Error {
+				protocols.Err = fun
+			}
+		}
+	}
+
+	// a cursor needs to advance and to read the current value
+	if protocols.Next == nil || protocols.Value == nil {
+		protocols.Next, protocols.Value, protocols.Err = nil, nil, nil
+	}
+	// a sequence needs both its length and its items
+	if protocols.Len == nil || protocols.At == nil {
+		protocols.Len, protocols.At = nil, nil
+	}
+	return protocols
+}
+
+func isBasic(t *ir.Type, basic string) bool {
+	return t.Kind == ir.KIND_BASIC && t.Basic == basic
+}
+
+// isSelf returns true if t is the named type goType or a pointer to it
+func isSelf(t *ir.Type, goType string) bool {
+	if t.Kind == ir.KIND_POINTER {
+		t = t.Elem
+	}
+	return isNamed(t) && t.GoType == goType
+}
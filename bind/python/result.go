@@ -0,0 +1,157 @@
+package python
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/devigned/veil/core"
+	"github.com/devigned/veil/ir"
+)
+
+const (
+	RESULT_CLASS_SUFFIX       = "Result"
+	RESULT_RECEIVER_SEPARATOR = "_"
+)
+
+// ResultTuple is the typing.NamedTuple returned by functions with two or more non-error results and out-params,
+// such as (width, height int). Fields take the Go result and param names, or positional names like r0 when a
+// result is unnamed or its name can't be used as a field.
+type ResultTuple struct {
+	Name   string
+	Fields []*ResultField
+}
+
+// ResultField is a field of a ResultTuple
+type ResultField struct {
+	Name   string
+	PyType string
+	// StubType is the type hint of the field in the type stubs
+	StubType string
+}
+
+// PrintFields returns the Python list of (name, type) pairs defining the fields of the tuple
+func (r ResultTuple) PrintFields() string {
+	fields := make([]string, len(r.Fields))
+	for i, field := range r.Fields {
+		fields[i] = fmt.Sprintf("(\"%s\", %s)", field.Name, field.PyType)
+	}
+	return "[" + strings.Join(fields, ", ") + "]"
+}
+
+// ResultTuple returns the named tuple returned by the function, or nil if it returns a single value
+func (f Func) ResultTuple() *ResultTuple {
+	fields := []*ResultField{}
+	taken := map[string]bool{}
+	// out-params are numbered after the results
+	for i, value := range append(append([]*Param{}, f.Results...), f.OutParams()...) {
+		if value.IsError() {
+			continue
+		}
+		name := value.underlying.Name
+		if !value.KeepGoName {
+			name = core.ToSnake(name)
+		}
+		if name == "" || strings.HasPrefix(name, "_") || IsReservedWord(name) || taken[name] {
+			name = fmt.Sprintf("r%d", i)
+		}
+		taken[name] = true
+		fields = append(fields, &ResultField{Name: name, PyType: value.PyType(), StubType: value.StubReturnType()})
+	}
+	if len(fields) < 2 {
+		return nil
+	}
+	return &ResultTuple{Name: f.ResultClassName(), Fields: fields}
+}
+
+// ResultClassName returns the name of the named tuple returned by the function, such as DimensionsResult for a
+// func or World_SizeResult for a method. The receiver is separated from the method, so the method World.Size
+// and a func WorldSize return different tuples.
+func (f Func) ResultClassName() string {
+	if f.fun.Receiver != "" {
+		return f.fun.Receiver + RESULT_RECEIVER_SEPARATOR + f.fun.Name + RESULT_CLASS_SUFFIX
+	}
+	return f.fun.Name + RESULT_CLASS_SUFFIX
+}
+
+// PyType returns the Python type of the param as used in type annotations. Classes are named as strings, so they
+// can be referred to before they are defined.
+func (p Param) PyType() string {
+	return pyType(p.underlying.Type)
+}
+
+func pyType(typ *ir.Type) string {
+	switch typ.Kind {
+	case ir.KIND_SEQ:
+		return "object"
+	case ir.KIND_OPAQUE:
+		return "GoOpaque"
+	case ir.KIND_BASIC:
+		switch {
+		case typ.Basic == "string":
+			return "str"
+		case typ.Basic == "bool":
+			return "bool"
+		case typ.Basic == "float32" || typ.Basic == "float64":
+			return "float"
+		case isInteger(typ.Basic):
+			return "int"
+		}
+	case ir.KIND_STRUCT, ir.KIND_INTERFACE, ir.KIND_NAMED:
+		if !isNamed(typ) {
+			return "object"
+		} else if typ.Error {
+			return "VeilError"
+		} else if typ.Kind == ir.KIND_NAMED {
+			if typ.Underlying == nil {
+				return "object"
+			}
+			return pyType(typ.Underlying)
+		}
+		return "\"" + typeName(typ) + "\""
+	case ir.KIND_SLICE:
+		return "\"" + listTypeName(typ.Elem) + "\""
+	case ir.KIND_POINTER:
+		return pyType(typ.Elem)
+	}
+	return "object"
+}
+
+// ResultTuples returns the named tuples returned by the functions and methods of the package. Functions returning
+// the same fields share a tuple, while two tuples with different fields and the same name, or a tuple named like a
+// class, are an error.
+func (p Binder) ResultTuples(funcs []*Func, classes []*Class, interfaces []*Interface) ([]*ResultTuple, error) {
+	all := append([]*Func{}, funcs...)
+	classNames := map[string]bool{}
+	for _, class := range classes {
+		all = append(all, class.Methods...)
+		classNames[class.Name()] = true
+	}
+	for _, iface := range interfaces {
+		all = append(all, iface.Methods...)
+		classNames[iface.Name()] = true
+		classNames[iface.ProxyName()] = true
+	}
+
+	tuples := []*ResultTuple{}
+	seen := map[string]*ResultTuple{}
+	for _, fun := range all {
+		tuple := fun.ResultTuple()
+		if tuple == nil {
+			continue
+		}
+		if classNames[tuple.Name] {
+			return nil, core.NewUserErrorF("Named tuple %s returned by %s would shadow the class %s; rename one of them",
+				tuple.Name, fun.fun.Name, tuple.Name)
+		}
+		if other, ok := seen[tuple.Name]; ok {
+			if other.PrintFields() != tuple.PrintFields() {
+				return nil, core.NewUserErrorF("Named tuple %s would be returned with fields %s and %s; rename %s",
+					tuple.Name, other.PrintFields(), tuple.PrintFields(), fun.fun.Name)
+			}
+			continue
+		}
+		seen[tuple.Name] = tuple
+		tuples = append(tuples, tuple)
+	}
+	return tuples, nil
+}
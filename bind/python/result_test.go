@@ -0,0 +1,44 @@
+package python
+
+import (
+	"testing"
+
+	"github.com/devigned/veil/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+func resultFunc(receiver, name string, results ...string) *Func {
+	fun := &Func{fun: &ir.Func{Name: name, Receiver: receiver}, Name: name}
+	for _, result := range results {
+		v := &ir.Var{Name: result, Type: &ir.Type{Kind: ir.KIND_BASIC, Basic: "int"}}
+		fun.Results = append(fun.Results, NewParam(v, result))
+	}
+	return fun
+}
+
+func TestResultTuples(t *testing.T) {
+	binder := Binder{}
+	method := resultFunc("World", "Size", "letters", "words")
+	class := &Class{strct: &ir.Struct{Name: "World"}, Methods: []*Func{method}}
+
+	tuples, err := binder.ResultTuples([]*Func{resultFunc("", "WorldSize", "width", "height")}, []*Class{class}, nil)
+	if assert.NoError(t, err) && assert.Len(t, tuples, 2) {
+		assert.Equal(t, "WorldSizeResult", tuples[0].Name)
+		assert.Equal(t, "World_SizeResult", tuples[1].Name)
+	}
+
+	shared := []*Func{resultFunc("", "Divide", "q", "r"), resultFunc("", "Divide", "q", "r")}
+	tuples, err = binder.ResultTuples(shared, nil, nil)
+	if assert.NoError(t, err) {
+		assert.Len(t, tuples, 1, "funcs returning the same fields share a tuple")
+	}
+
+	clashing := []*Func{resultFunc("", "World_Size", "width", "height")}
+	_, err = binder.ResultTuples(clashing, []*Class{class}, nil)
+	assert.Error(t, err, "tuples with different fields can't share a name")
+
+	divide := []*Func{resultFunc("", "Divide", "q", "r")}
+	shadowed := &Class{strct: &ir.Struct{Name: "DivideResult"}}
+	_, err = binder.ResultTuples(divide, []*Class{shadowed}, nil)
+	assert.Error(t, err, "tuples can't shadow classes")
+}
@@ -0,0 +1,321 @@
+package python
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/devigned/veil/ir"
+)
+
+const (
+	STUB_FILE_NAME = "generated.pyi"
+
+	// STUB_TEMPLATE renders the type stubs of the Python module, which type checkers such as mypy read in place of
+	// the untyped module
+	STUB_TEMPLATE = `# Type stubs of generated.py
+import uuid
+from typing import (Any, BinaryIO, ClassVar, Dict, Iterable, Iterator, MutableSequence, NamedTuple, Optional,
+	Protocol, Tuple, Type, TypeVar, Union)
+
+_T = TypeVar("_T")
+_L = TypeVar("_L", bound="VeilList[Any]")
+_V = TypeVar("_V", bound="VeilObject")
+
+VEIL_VIEW: str
+VEIL_COPY: str
+
+
+class VeilObject:
+	_veil_semantics: ClassVar[str]
+
+	def __init__(self, uuid_ptr: Any, tracked: bool = ..., resolver: Any = ...) -> None: ...
+	def go_uuid(self) -> uuid.UUID: ...
+	def uuid_ptr(self) -> Any: ...
+
+
+class VeilList(MutableSequence[_T]):
+	_veil_semantics: ClassVar[str]
+
+	def __init__(self, data: Optional[Iterable[_T]] = ..., uuid_ptr: Any = ..., tracked: bool = ...,
+		resolver: Any = ...) -> None: ...
+	def __len__(self) -> int: ...
+	# Go slices are indexed by int only
+	def __getitem__(self, idx: int) -> _T: ...  # type: ignore[override]
+	def __setitem__(self, idx: int, val: _T) -> None: ...  # type: ignore[override]
+	def __delitem__(self, idx: int) -> None: ...  # type: ignore[override]
+	def insert(self, idx: int, val: _T) -> None: ...
+	def __copy__(self: _L) -> _L: ...
+	def __deepcopy__(self: _L, memo: Any) -> _L: ...
+	def uuid_ptr(self) -> Any: ...
+
+
+class VeilError(Exception):
+	veil_obj: VeilObject
+
+	def __init__(self, uuid_ptr: Any, tracked: bool = ...) -> None: ...
+	@staticmethod
+	def is_nil(uuid_ptr: Any) -> bool: ...
+
+
+class VeilNilError(ValueError):
+	def __init__(self, type_name: str) -> None: ...
+
+
+class GoOpaque(VeilObject):
+	def __init__(self, uuid_ptr: Any, tracked: bool = ...) -> None: ...
+	def go_type(self) -> str: ...
+
+{{range $_, $list := .Lists}}
+class {{$list.ListTypeName}}(VeilList[{{$list.StubElemType}}]): ...
+{{end}}
+{{range $_, $tuple := .ResultTuples}}
+class {{$tuple.Name}}(NamedTuple):
+	{{- range $_, $field := $tuple.Fields}}
+	{{$field.Name}}: {{$field.StubType}}
+	{{- end}}
+{{end}}
+{{range $_, $func := .Funcs}}
+def {{$func.Name}}({{$func.StubParams}}) -> {{$func.StubReturns}}: ...
+{{- end}}
+{{range $_, $class := .Classes}}
+
+class {{$class.Name}}(VeilObject):
+	def __init__(self, uuid_ptr: Any = ..., tracked: bool = ..., resolver: Any = ...{{with $class.StubInitKwargs}}, *, {{.}}{{end}}) -> None: ...
+	def __copy__(self) -> {{$class.Name}}: ...
+	def __deepcopy__(self, memo: Any) -> {{$class.Name}}: ...
+	def to_json(self) -> str: ...
+	@classmethod
+	def from_json(cls: Type[_V], data: Union[str, bytes]) -> _V: ...
+	def to_dict(self) -> Dict[str, Any]: ...
+	@classmethod
+	def from_dict(cls: Type[_V], data: Dict[str, Any]) -> _V: ...
+	{{- range $_, $func := $class.Constructors}}
+	@classmethod
+	def {{$func.Name}}(cls{{with $func.StubParams}}, {{.}}{{end}}) -> {{$class.Name}}: ...
+	{{- end}}
+	{{- range $_, $func := $class.Methods}}
+	def {{$func.Name}}(self{{with $func.StubParams}}, {{.}}{{end}}) -> {{$func.StubReturns}}: ...
+	{{- end}}
+	{{- range $_, $prop := $class.Properties}}
+	{{template "stubProperty" $prop}}
+	{{- end}}
+	{{- range $_, $field := $class.Fields}}
+	@property
+	def {{$field.Name}}(self) -> {{$field.StubReturnType}}: ...
+	{{- if not $field.ReadOnly}}
+	@{{$field.Name}}.setter
+	def {{$field.Name}}(self, value: {{$field.StubType}}) -> None: ...
+	{{- end}}
+	{{- end}}
+	{{- template "stubProtocols" $class.Protocols}}
+{{- end}}
+{{range $_, $iface := .Interfaces}}
+
+class {{$iface.Name}}(Protocol):
+	{{- range $_, $func := $iface.Methods}}{{if not $func.Property}}
+	def {{$func.Name}}(self{{with $func.StubParams}}, {{.}}{{end}}) -> {{$func.StubReturns}}: ...
+	{{- end}}{{end}}
+	{{- range $_, $prop := $iface.Properties}}
+	{{template "stubProperty" $prop}}
+	{{- end}}
+	{{- if and (not $iface.Methods) (not $iface.Properties)}}
+	...
+	{{- end}}
+{{end}}
+
+{{- define "stubProperty"}}@property
+	def {{.Name}}(self) -> {{.Getter.StubReturns}}: ...
+	@{{.Name}}.setter
+	def {{.Name}}(self, value: {{.StubValueType}}) -> None: ...
+{{- end}}
+
+{{- define "stubProtocols"}}
+	{{- if .GoStr}}
+	def __repr__(self) -> str: ...
+	{{- end}}
+	{{- if .String}}
+	def __str__(self) -> str: ...
+	{{- end}}
+	{{- if .HasEquality}}
+	def __eq__(self, other: object) -> bool: ...
+	def __ne__(self, other: object) -> bool: ...
+	{{- if .HashMethod}}
+	def __hash__(self) -> int: ...
+	{{- else}}
+	__hash__: ClassVar[None]  # type: ignore[assignment]
+	{{- end}}
+	{{- end}}
+	{{- if .HasOrdering}}
+	def __lt__(self, other: {{.ClassName}}) -> bool: ...
+	def __le__(self, other: {{.ClassName}}) -> bool: ...
+	def __gt__(self, other: {{.ClassName}}) -> bool: ...
+	def __ge__(self, other: {{.ClassName}}) -> bool: ...
+	{{- end}}
+	{{- if .Len}}
+	def __len__(self) -> int: ...
+	def __getitem__(self, idx: int) -> {{.At.StubReturns}}: ...
+	{{- end}}
+	{{- if .Next}}
+	def __iter__(self) -> Iterator[{{.Value.StubReturns}}]: ...
+	{{- end}}
+	{{- if .Close}}
+	def __enter__(self) -> {{.ClassName}}: ...
+	def __exit__(self, exc_type: Any, exc_value: Any, traceback: Any) -> bool: ...
+	{{- end}}
+{{- end}}`
+)
+
+var stubTemplate *template.Template
+
+func init() {
+	stubTemplate = template.Must(template.New("stubTemplate").Parse(removeTabs(STUB_TEMPLATE)))
+}
+
+// stubType returns the Python type hint of Go values of typ received from Go. Pointers and interfaces may be nil,
+// so they are Optional, while struct values are never None.
+func stubType(typ *ir.Type) string {
+	switch typ.Kind {
+	case ir.KIND_SEQ:
+		elems := make([]string, len(typ.Elems))
+		for i, elem := range typ.Elems {
+			elems[i] = stubType(elem)
+		}
+		if len(elems) == 2 && typ.Elems[1].Error {
+			// the error of an iter.Seq2 is raised rather than yielded
+			return "Iterator[" + elems[0] + "]"
+		} else if len(elems) == 2 {
+			return "Iterator[Tuple[" + strings.Join(elems, ", ") + "]]"
+		}
+		return "Iterator[" + elems[0] + "]"
+	case ir.KIND_OPAQUE:
+		return "GoOpaque"
+	case ir.KIND_BASIC:
+		switch {
+		case typ.Basic == "string":
+			return "str"
+		case typ.Basic == "bool":
+			return "bool"
+		case typ.Basic == "float32" || typ.Basic == "float64":
+			return "float"
+		case isInteger(typ.Basic):
+			return "int"
+		}
+	case ir.KIND_STRUCT, ir.KIND_INTERFACE, ir.KIND_NAMED:
+		if !isNamed(typ) {
+			return "Any"
+		} else if typ.Error {
+			return "Optional[VeilError]"
+		} else if typ.Kind == ir.KIND_NAMED {
+			if typ.Underlying == nil {
+				return "Any"
+			}
+			return stubType(typ.Underlying)
+		} else if typ.Kind == ir.KIND_INTERFACE {
+			return "Optional[" + typeName(typ) + "]"
+		}
+		return typeName(typ)
+	case ir.KIND_SLICE:
+		return listTypeName(typ.Elem)
+	case ir.KIND_POINTER:
+		if !isNamed(typ.Elem) {
+			return stubType(typ.Elem)
+		}
+		return optional(stubType(typ.Elem))
+	}
+	return "Any"
+}
+
+// optional returns the type hint of a value which may also be None
+func optional(hint string) string {
+	if hint == "Any" || strings.HasPrefix(hint, "Optional[") {
+		return hint
+	}
+	return "Optional[" + hint + "]"
+}
+
+// StubType returns the type hint of the param as an argument of a Python function. Sequences can only be passed
+// back to Go as the opaque handles they are held in, while streams also accept binary file objects.
+func (p Param) StubType() string {
+	if p.underlying.Type.Kind == ir.KIND_SEQ {
+		return "GoOpaque"
+	} else if p.Stream {
+		return "Optional[Union[" + typeName(p.underlying.Type) + ", BinaryIO]]"
+	}
+	return stubType(p.underlying.Type)
+}
+
+// StubReturnType returns the type hint of the param as a value returned to Python. The struct of an out-param is
+// always created by Python, so it is never None.
+func (p Param) StubReturnType() string {
+	if p.IsOut() {
+		return stubType(p.underlying.Type.Elem)
+	}
+	return stubType(p.underlying.Type)
+}
+
+// StubParams returns the params of the function with their type hints, followed by the options as keyword
+// arguments
+func (f Func) StubParams() string {
+	params := []string{}
+	for _, param := range f.Params {
+		if !param.IsOut() {
+			params = append(params, param.Name()+": "+param.StubType())
+		}
+	}
+	for _, option := range f.Options {
+		params = append(params, option.Name+": "+option.StubType()+" = ...")
+	}
+	return strings.Join(params, ", ")
+}
+
+// StubReturns returns the type hint of the value returned by the function, which is its named tuple if it returns
+// several values
+func (f Func) StubReturns() string {
+	if tuple := f.ResultTuple(); tuple != nil {
+		return tuple.Name
+	}
+	for _, result := range append(append([]*Param{}, f.Results...), f.OutParams()...) {
+		if !result.IsError() {
+			return result.StubReturnType()
+		}
+	}
+	return "None"
+}
+
+// StubType returns the type hint of the keyword argument of the option, which is a bool if the helper takes no
+// params and a tuple if it takes several
+func (o Option) StubType() string {
+	if o.IsFlag() {
+		return "bool"
+	}
+	hints := make([]string, len(o.Params))
+	for i, param := range o.Params {
+		hints[i] = param.StubType()
+	}
+	if len(hints) == 1 {
+		return hints[0]
+	}
+	return fmt.Sprintf("Tuple[%s]", strings.Join(hints, ", "))
+}
+
+// StubInitKwargs returns the keyword arguments accepted when constructing the class with their type hints
+func (c Class) StubInitKwargs() string {
+	kwargs := []string{}
+	for _, field := range c.InitFields {
+		if field.IsSettable() {
+			kwargs = append(kwargs, field.Name()+": "+field.StubType()+" = ...")
+		}
+	}
+	return strings.Join(kwargs, ", ")
+}
+
+// StubValueType returns the type hint of the value the property is set to
+func (p Property) StubValueType() string {
+	return p.Setter.Params[0].StubType()
+}
+
+// StubElemType returns the type hint of the items of the list
+func (l List) StubElemType() string {
+	return stubType(l.slice.Elem)
+}
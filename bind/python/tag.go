@@ -0,0 +1,97 @@
+package python
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/devigned/veil/ir"
+)
+
+const (
+	VEIL_TAG_KEY      = "veil"
+	JSON_TAG_KEY      = "json"
+	TAG_SKIP          = "-"
+	TAG_SKIP_OPTION   = "skip"
+	TAG_READONLY_FLAG = "readonly"
+)
+
+// FieldTag is what the struct tags of a field say about its Python property
+type FieldTag struct {
+	Name     string
+	ReadOnly bool
+	Skip     bool
+}
+
+// ParseFieldTag reads the json and veil keys of a struct tag. Names which aren't Python identifiers are ignored,
+// so the property falls back to the snake case of the field name, and reserved words such as class gain an
+// underscore, as in class_.
+func ParseFieldTag(tag string) FieldTag {
+	fieldTag := FieldTag{}
+	structTag := reflect.StructTag(tag)
+
+	if value, ok := structTag.Lookup(JSON_TAG_KEY); ok {
+		name := strings.Split(value, ",")[0]
+		if value == TAG_SKIP {
+			fieldTag.Skip = true
+		} else if isIdentifier(name) {
+			fieldTag.Name = name
+		}
+	}
+
+	if value, ok := structTag.Lookup(VEIL_TAG_KEY); ok {
+		parts := strings.Split(value, ",")
+		if parts[0] == TAG_SKIP {
+			fieldTag.Skip = true
+		} else if isIdentifier(parts[0]) {
+			fieldTag.Name = parts[0]
+		}
+		for _, option := range parts[1:] {
+			switch strings.TrimSpace(option) {
+			case TAG_READONLY_FLAG:
+				fieldTag.ReadOnly = true
+			case TAG_SKIP_OPTION:
+				fieldTag.Skip = true
+			}
+		}
+	}
+
+	if IsReservedWord(fieldTag.Name) {
+		fieldTag.Name += "_"
+	}
+	return fieldTag
+}
+
+// IsNotEncoded reports whether the json key of a struct tag leaves the field out of its JSON encoding
+func IsNotEncoded(tag string) bool {
+	return reflect.StructTag(tag).Get(JSON_TAG_KEY) == TAG_SKIP
+}
+
+// fieldTag returns the tag of the field, which is empty unless useStructTags is set
+func fieldTag(field *ir.Field, useStructTags bool) FieldTag {
+	if !useStructTags {
+		return FieldTag{}
+	}
+	return ParseFieldTag(field.Tag)
+}
+
+// applyFieldTag names param after tag and marks it read only if the tag says so
+func applyFieldTag(param *Param, tag FieldTag) {
+	if tag.Name != "" && param.PyName == "" {
+		// a field renamed by a directive keeps its name
+		param.PyName = tag.Name
+	}
+	param.ReadOnly = tag.ReadOnly || tag.Skip
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -6,15 +6,31 @@ import (
 )
 
 const (
-	PYTHON_TEMPLATE = `import os
+	PYTHON_TEMPLATE = `import io
+import json
+import math
+import numbers
+import os
 import sys
 import uuid
+import warnings
 import cffi as _cffi_backend
-from collections import MutableSequence
+try:
+	from collections.abc import MutableSequence
+except ImportError:
+	from collections import MutableSequence
 from abc import abstractmethod
 
 _PY3 = sys.version_info[0] == 3
 
+# VEIL_VIEW items and fields are live views into their Go container. VEIL_COPY items and fields are Go value
+# copies which are independent of their container.
+VEIL_VIEW = "view"
+VEIL_COPY = "copy"
+
+# Marks struct fields which weren't passed when constructing a class, so they keep their Go zero value
+_VEIL_UNSET = object()
+
 ffi = _cffi_backend.FFI()
 ffi.cdef("""{{.CDef}}""")
 
@@ -52,6 +68,28 @@ class _CffiHelper(object):
 			pystr = pystr.decode('utf-8')
 		return pystr
 
+	@staticmethod
+	def py2c_int(value, name, go_type, low, high):
+		"""Check value is an integer within the range of the Go integer type before it is passed as name"""
+		if isinstance(value, bool) or not isinstance(value, numbers.Integral):
+			raise TypeError("%s must be an integer for Go %s, not %s" % (name, go_type, type(value).__name__))
+		if value < low or value > high:
+			raise OverflowError("%s=%d is out of range for Go %s [%d, %d]" % (name, value, go_type, low, high))
+		return int(value)
+
+	@staticmethod
+	def py2c_float(value, name, go_type, limit):
+		"""Check value is a real number which fits the Go float type before it is passed as name"""
+		if isinstance(value, bool) or not isinstance(value, numbers.Real):
+			raise TypeError("%s must be a real number for Go %s, not %s" % (name, go_type, type(value).__name__))
+		try:
+			value = float(value)
+		except OverflowError:
+			raise OverflowError("%s is out of range for Go %s" % (name, go_type))
+		if limit is not None and not math.isinf(value) and abs(value) > limit:
+			raise OverflowError("%s=%r is out of range for Go %s" % (name, value, go_type))
+		return value
+
 	@staticmethod
 	def py2c_string(s):
 		if _PY3:
@@ -66,15 +104,138 @@ class _CffiHelper(object):
 			return ffi.NULL
 
 	@staticmethod
-	def py2c(value):
-		if isinstance(value, int):
-			return ffi.new("int *", value)
+	def py2c_veil_value(vo, name):
+		if vo is None:
+			raise TypeError("{} is a Go value type and cannot be None".format(name))
+		return vo.uuid_ptr()
+
+	@staticmethod
+	def py2c_go_opaque(vo, name, check=None):
+		if vo is None:
+			return ffi.NULL
+		if not isinstance(vo, GoOpaque):
+			raise TypeError("{} must be a GoOpaque returned from Go, not {}".format(name, type(vo).__name__))
+		if check is not None:
+			error = _CffiHelper.lib.cgo_opaque_check(vo.uuid_ptr(), _CffiHelper.py2c_string(check))
+			if error != ffi.NULL:
+				raise TypeError("{}: {}".format(name, _CffiHelper.c2py_string(error)))
+		return vo.uuid_ptr()
+
+	@staticmethod
+	def c2py_veil_object(cls, ptr, tracked=True):
+		if ptr == ffi.NULL:
+			return None
+		return cls(uuid_ptr=ptr, tracked=tracked)
+
+	@staticmethod
+	def c2py_seq(ptr, next_func, convert):
+		"""Returns a generator which lazily pulls the values of a Go iter.Seq or iter.Seq2"""
+		if ptr == ffi.NULL:
+			return None
+		return _CffiHelper.pull_seq(VeilObject(ptr, tracked=True), next_func, convert)
+
+	@staticmethod
+	def pull_seq(seq, next_func, convert):
+		try:
+			while True:
+				values = next_func(seq.uuid_ptr())
+				if not values.r0:
+					return
+				yield convert(values)
+		finally:
+			# stop the Go iterator as soon as the generator is exhausted or closed
+			_CffiHelper.lib.cgo_seq_stop(seq.uuid_ptr())
+
+	@staticmethod
+	def raise_if_error(err, value):
+		if not VeilError.is_nil(err):
+			raise VeilError(err)
+		return value
+
+	@staticmethod
+	def py2c_interface(value, cls):
+		if value is None:
+			return ffi.NULL
+		if not isinstance(value, cls):
+			if _CffiHelper.is_stream(value, cls):
+				return _CffiHelper.py2c_stream(value, cls)
+			raise TypeError("expected an implementation of {}, not {}".format(cls.__name__, type(value).__name__))
+		if getattr(value, "_handle", None) is not None:
+			# Python implementations are pinned while Go references them and released by a Go finalizer
+			ptr = value.__get_method__("pin")(value.uuid_ptr())
+			_CffiHelper.pin(value)
+			return ffi.gc(ptr, _CffiHelper.cgo_decref)
+		return value.uuid_ptr()
+
+	@staticmethod
+	def is_stream(value, cls):
+		attributes = getattr(cls, "__go_stream__", ())
+		for attribute in attributes:
+			if not hasattr(value, attribute) and not (attribute == "read" and hasattr(value, "readinto")):
+				return False
+		return len(attributes) > 0
+
+	@staticmethod
+	def py2c_stream(value, cls):
+		# file objects are pinned while Go references them and released by a Go finalizer
+		stream = _VeilStream(value)
+		ptr = cls.__get_method__("from_stream")(stream.handle)
+		_CffiHelper.pin(stream)
+		return ffi.gc(ptr, _CffiHelper.cgo_decref)
+
+	@staticmethod
+	def c2py_interface(proxy_cls, ptr, tracked=True):
+		ptr = _CffiHelper.c2py_handle(ptr, tracked=tracked)
+		if ptr is None:
+			return None
+		handle = proxy_cls.__get_method__("py_handle")(ptr)
+		if handle != ffi.NULL:
+			# a Python implementation which crossed into Go is returned as itself
+			if tracked:
+				_CffiHelper.cgo_decref(ptr)
+			return ffi.from_handle(handle)
+		return proxy_cls(uuid_ptr=ptr, tracked=tracked)
+
+	pinned = {}
+
+	@staticmethod
+	def pin(obj):
+		entry = _CffiHelper.pinned.setdefault(id(obj), [obj, 0])
+		entry[1] += 1
+
+	@staticmethod
+	def unpin(obj):
+		entry = _CffiHelper.pinned.get(id(obj))
+		if entry is not None:
+			entry[1] -= 1
+			if entry[1] <= 0:
+				del _CffiHelper.pinned[id(obj)]
+
+	@staticmethod
+	def c2py_handle(ptr, tracked=True):
+		if ptr == ffi.NULL:
+			return None
+		typed_nil = _CffiHelper.lib.cgo_typed_nil(ptr)
+		if typed_nil != ffi.NULL:
+			if tracked:
+				_CffiHelper.cgo_decref(ptr)
+			raise VeilNilError(_CffiHelper.c2py_string(typed_nil))
+		return ptr
+
+	@staticmethod
+	def py2c(value, ctype=None):
+		if ctype is not None:
+			return ffi.new(ctype + " *", value)
+		elif isinstance(value, bool):
+			return ffi.new("_Bool *", value)
+		elif isinstance(value, numbers.Integral):
+			return ffi.new("long long *", value)
 		elif isinstance(value, float):
-			return ffi.new("float *", value)
+			return ffi.new("double *", value)
 		elif isinstance(value, str):
 			return _CffiHelper.py2c_string(value)
 		elif isinstance(value, VeilList):
-			return _CffiHelper.py2c_veil_object(value.veil_obj())
+			return _CffiHelper.py2c_veil_object(value)
 		elif isinstance(value, VeilObject):
 			return _CffiHelper.py2c_veil_object(value)
 		elif value is None:
@@ -82,30 +243,47 @@ class _CffiHelper(object):
 		else:
 			return ffi.NULL
 
+def set_semantics(cls, semantics):
+	"""Set whether items and fields of type cls are returned as live views (VEIL_VIEW) or copies (VEIL_COPY)"""
+	if semantics not in (VEIL_VIEW, VEIL_COPY):
+		raise ValueError("semantics must be VEIL_VIEW or VEIL_COPY")
+	cls._veil_semantics = semantics
+
+
 class VeilObject(object):
-	def __init__(self, uuid_ptr, tracked=True):
+	_veil_semantics = VEIL_VIEW
+
+	def __init__(self, uuid_ptr, tracked=True, resolver=None):
 		self._uuid_ptr = uuid_ptr
 		self._tracked = tracked
+		self._resolver = resolver
 
 	def __del__(self):
-		if self._tracked:
+		if self._tracked and self._uuid_ptr is not None and self._uuid_ptr != ffi.NULL:
 			_CffiHelper.cgo_decref(self._uuid_ptr)
 
 	def go_uuid(self):
-    	ba = bytearray(16)
-    	ffi.memmove(ba, self._uuid_ptr, 16)
-    	return uuid.UUID(bytes=bytes(ba))
+		ba = bytearray(16)
+		ffi.memmove(ba, self.uuid_ptr(), 16)
+		return uuid.UUID(bytes=bytes(ba))
 
 	def uuid_ptr(self):
-        return self._uuid_ptr
+		if self._resolver is not None:
+			# views resolve their Go value on every access, so they follow the container through reallocation
+			old, self._uuid_ptr = self._uuid_ptr, self._resolver()
+			if old is not None:
+				_CffiHelper.cgo_decref(old)
+		return self._uuid_ptr
 
 
 class VeilList(MutableSequence):
-	def __init__(self, data=None, uuid_ptr=None, tracked=True):
-		if uuid_ptr is None:
+	_veil_semantics = VEIL_VIEW
+
+	def __init__(self, data=None, uuid_ptr=None, tracked=True, resolver=None):
+		if uuid_ptr is None and resolver is None:
 			tracked = True
 			uuid_ptr = self.__get_method__("new")()
-		self._veil_obj = VeilObject(uuid_ptr, tracked=tracked)
+		self._veil_obj = VeilObject(uuid_ptr, tracked=tracked, resolver=resolver)
 		super(VeilList, self).__init__()
 
 	@abstractmethod
@@ -119,6 +297,10 @@ class VeilList(MutableSequence):
 	def __go_type_output_transform__(self, value):
 		return value
 
+	def __go_view_class__(self):
+		"""The class of items which can be viewed in place, or None if items are always copied"""
+		return None
+
 	def __len__(self):
 		"""List length"""
 		return self.__get_method__("len")(self._veil_obj.uuid_ptr())
@@ -127,50 +309,154 @@ class VeilList(MutableSequence):
 		"""Get a list item"""
 		if idx >= self.__len__():
 			raise IndexError
+		view_class = self.__go_view_class__()
+		if view_class is not None and view_class._veil_semantics == VEIL_VIEW:
+			return view_class(resolver=self.__item_resolver__(idx))
 		value = self.__get_method__("item")(self._veil_obj.uuid_ptr(), idx)
 		return self.__go_type_output_transform__(value)
 
+	def __item_resolver__(self, idx):
+		def resolve():
+			if idx >= self.__len__():
+				raise IndexError("list item {} is no longer in range".format(idx))
+			return self.__get_method__("item_ref")(self._veil_obj.uuid_ptr(), idx)
+		return resolve
+
 	def __delitem__(self, idx):
 		"""Delete an item"""
 		self.__get_method__("item_del")(self._veil_obj.uuid_ptr(), idx)
 
 	def __setitem__(self, idx, val):
-		val = self.__go_type_input_transform__(val)
-		self.__get_method__("item_set")(self._veil_obj.uuid_ptr(), idx, val)
+		c_val = self.__go_type_input_transform__(val)
+		self.__get_method__("item_set")(self._veil_obj.uuid_ptr(), idx, c_val)
 
 	def insert(self, idx, val):
-		val = self.__go_type_input_transform__(val)
-		self.__get_method__("item_insert")(self._veil_obj.uuid_ptr(), idx, val)
+		c_val = self.__go_type_input_transform__(val)
+		self.__get_method__("item_insert")(self._veil_obj.uuid_ptr(), idx, c_val)
 
 	def __go_str__(self):
 		cret = self.__get_method__("str")(self._veil_obj.uuid_ptr())
 		return _CffiHelper.c2py_string(cret)
 
+	def __copy__(self):
+		return self.__class__(uuid_ptr=self.__get_method__("copy")(self._veil_obj.uuid_ptr()))
+
+	def __deepcopy__(self, memo):
+		return self.__class__(uuid_ptr=self.__get_method__("deepcopy")(self._veil_obj.uuid_ptr()))
+
 	def __get_method__(self, method_name):
 		return getattr(_CffiHelper.lib, self.__go_slice_type__() + "_" + method_name)
 
+	def uuid_ptr(self):
+		return self._veil_obj.uuid_ptr()
+
 
 class VeilError(Exception):
-    def __init__(self, uuid_ptr):
-        self.veil_obj = VeilObject(uuid_ptr=uuid_ptr)
+    def __init__(self, uuid_ptr, tracked=True):
+        self.veil_obj = VeilObject(uuid_ptr=uuid_ptr, tracked=tracked)
         message = _CffiHelper.error_string(uuid_ptr)
         super(VeilError, self).__init__(message)
 
     @staticmethod
     def is_nil(uuid_ptr):
-        return _CffiHelper.lib.cgo_is_error_nil(uuid_ptr)
+        return uuid_ptr == ffi.NULL or _CffiHelper.lib.cgo_is_error_nil(uuid_ptr)
+
+
+class VeilNilError(ValueError):
+    def __init__(self, type_name):
+        message = "Go returned an interface holding a nil {}".format(type_name)
+        super(VeilNilError, self).__init__(message)
+
+
+@ffi.callback("void(void*)")
+def _veil_release(handle):
+	_CffiHelper.unpin(ffi.from_handle(handle))
+
+
+_CffiHelper.lib.cgo_register_release(_veil_release)
+
+
+class _VeilStream(object):
+	"""Adapts a binary Python file object, such as a file, io.BytesIO or socket file, to a Go stream"""
+
+	def __init__(self, stream):
+		self.stream = stream
+		self.handle = ffi.new_handle(self)
+
+	def read(self, buf, n):
+		view = ffi.buffer(buf, n)
+		if hasattr(self.stream, "readinto"):
+			return self.stream.readinto(view) or 0
+		data = self.stream.read(n)
+		view[0:len(data)] = data
+		return len(data)
+
+	def write(self, buf, n):
+		count = self.stream.write(ffi.buffer(buf, n)[:])
+		return n if count is None else count
+
+	def close(self, buf, n):
+		self.stream.close()
+		return 0
+
+
+def _veil_stream_callback(method):
+	def call(handle, buf, n, err):
+		try:
+			return getattr(ffi.from_handle(handle), method)(buf, n)
+		except Exception as e:
+			message = "{}: {}".format(type(e).__name__, e).encode("utf-8")[:255]
+			ffi.memmove(err, message, len(message))
+			return -1
+	return ffi.callback("long long(void*, void*, long long, char*)", call)
+
+
+_veil_stream_read = _veil_stream_callback("read")
+_veil_stream_write = _veil_stream_callback("write")
+_veil_stream_close = _veil_stream_callback("close")
+
+if hasattr(_CffiHelper.lib, "cgo_register_streams"):
+	_CffiHelper.lib.cgo_register_streams(_veil_stream_read, _veil_stream_write, _veil_stream_close)
+
+
+def _veil_result_tuple(name, fields):
+	"""Create the named tuple returned by a function with several results"""
+	try:
+		from typing import NamedTuple
+		return NamedTuple(name, fields)
+	except ImportError:
+		from collections import namedtuple
+		return namedtuple(name, [field for field, _ in fields])
+
+
+class GoOpaque(VeilObject):
+	"""A handle to a Go value which can't be represented in Python. It can be stored and passed back into Go."""
+
+	def __init__(self, uuid_ptr, tracked=True):
+		super(GoOpaque, self).__init__(uuid_ptr, tracked=tracked)
+
+	def go_type(self):
+		"""The Go type of the value behind the handle"""
+		return _CffiHelper.c2py_string(_CffiHelper.lib.cgo_opaque_type(self.uuid_ptr()))
+
+	def __repr__(self):
+		return "<GoOpaque {}>".format(self.go_type())
 
 {{range $_, $listType := .Lists}}
 class {{$listType.ListTypeName}}(VeilList):
-	def __init__(self, data=None, uuid_ptr=None, tracked=True):
-		super({{$listType.ListTypeName}}, self).__init__(data=data, uuid_ptr=uuid_ptr, tracked=tracked)
+	def __init__(self, data=None, uuid_ptr=None, tracked=True, resolver=None):
+		super({{$listType.ListTypeName}}, self).__init__(data=data, uuid_ptr=uuid_ptr, tracked=tracked,
+			resolver=resolver)
 
 	def __go_slice_type__(self):
 		return "{{$listType.MethodPrefix}}"
-
+{{if $listType.ViewClass}}
+	def __go_view_class__(self):
+		return {{$listType.ViewClass}}
+{{end}}
 	def __go_type_input_transform__(self, value):
 		{{call $listType.InputFormat }}
-		return value
+		return {{$listType.InputArg}}
 
 	def __go_type_output_transform__(self, value):
 		return {{call $listType.OutputFormat "value"}}
@@ -178,17 +464,33 @@ class {{$listType.ListTypeName}}(VeilList):
 {{end}}
 
 
+# Named tuples returned by functions with several results
+{{range $_, $tuple := .ResultTuples -}}
+{{$tuple.Name}} = _veil_result_tuple("{{$tuple.Name}}", {{$tuple.PrintFields}})
+{{end}}
+
 # Globally defined functions
 {{range $_, $func := .Funcs}}
-def {{$func.Name}}({{$func.PrintArgs}}):
+def {{$func.Name}}({{$func.PrintParams}}):
+{{- with $func.Docstring "    "}}
+    {{.}}
+{{- end}}
+{{- with $func.Deprecation ""}}
+    {{.}}
+{{- end}}
     {{ range $_, $inTrx := $func.InputTransforms -}}
       {{ $inTrx }}
     {{ end -}}
     {{$cret}} = _CffiHelper.lib.{{$func.Call -}}
     {{ range $idx, $result := $func.Results }}
 		{{if $result.IsError -}}
-			if not VeilError.is_nil(cret.r1):
+		{{if gt ($func.ResultsLength) 1 -}}
+			{{ printf "if not VeilError.is_nil(%s.r%d):" $cret $idx }}
 				{{ printf "raise VeilError(%s.r%d)" $cret $idx -}}
+		{{else -}}
+			if not VeilError.is_nil({{$cret}}):
+				raise VeilError({{$cret}})
+		{{- end}}
 		{{end}}
 	{{ end }}
 
@@ -197,24 +499,107 @@ def {{$func.Name}}({{$func.PrintArgs}}):
 
 {{range $_, $class := .Classes}}
 class {{$class.Name}}(VeilObject):
-
-		def __init__(self, uuid_ptr=None, tracked=True):
-			if uuid_ptr is None:
-				uuid_ptr = _CffiHelper.lib.{{$class.NewMethodName}}()
+{{- with $class.Docstring "    "}}
+		{{.}}
+{{- end}}
+
+		def __init__(self, uuid_ptr=None, tracked=True, resolver=None, **fields):
+			# __del__ still runs when constructing raises, so it must find nothing to release
+			self._uuid_ptr = None
+			self._tracked = False
+			if uuid_ptr is None and resolver is None:
+{{- with $class.Deprecation}}
+				{{.}}
+{{- end}}
+				uuid_ptr = {{$class.Name}}.__go_new__(**fields)
 				tracked = True
-			super({{$class.Name}}, self).__init__(uuid_ptr, tracked=tracked)
+			elif fields:
+				raise TypeError("fields can only be set when constructing a new {{$class.Name}}")
+			super({{$class.Name}}, self).__init__(uuid_ptr, tracked=tracked, resolver=resolver)
+
+		@staticmethod
+		def __go_new__({{$class.InitKwargs}}):
+			_veil_mask = 0
+			{{ range $idx, $field := $class.InitFields -}}
+			{{if $field.IsSettable -}}
+			if {{$field.Name}} is _VEIL_UNSET:
+				{{$field.CArg}} = {{$field.ZeroValue}}
+			else:
+				_veil_mask |= 1 << {{$idx}}
+				{{$field.InputFormatWithName $field.Name}}
+			{{end -}}
+			{{ end -}}
+			return _CffiHelper.lib.{{$class.NewWithFieldsMethodName}}(_veil_mask{{if $class.InitArgs}}, {{$class.InitArgs}}{{end}})
 
 		def __go_str__(self):
-			cret = _CffiHelper.lib.{{$class.ToStringMethodName}}(self._uuid_ptr)
+			cret = _CffiHelper.lib.{{$class.ToStringMethodName}}(self.uuid_ptr())
 			return _CffiHelper.c2py_string(cret)
 
+		def __copy__(self):
+			return {{$class.Name}}(uuid_ptr=_CffiHelper.lib.{{$class.CopyMethodName}}(self.uuid_ptr()))
+
+		def __deepcopy__(self, memo):
+			return {{$class.Name}}(uuid_ptr=_CffiHelper.lib.{{$class.DeepCopyMethodName}}(self.uuid_ptr()))
+
+		def to_json(self):
+			"""Encode the {{$class.Name}} as JSON with Go's encoding/json, which respects the json tags of its fields"""
+			{{$cret}} = _CffiHelper.lib.{{$class.ToJsonMethodName}}(self.uuid_ptr())
+			data = _CffiHelper.c2py_string({{$cret}}.r0)
+			if not VeilError.is_nil({{$cret}}.r1):
+				raise VeilError({{$cret}}.r1)
+			return data
+
+		@classmethod
+		def from_json(cls, data):
+			"""Decode JSON into a new {{$class.Name}} with Go's encoding/json"""
+			return cls(uuid_ptr=cls.__go_from_json__(data))
+
+		@classmethod
+		def __go_from_json__(cls, data):
+			if _PY3 and isinstance(data, bytes):
+				data = data.decode("utf-8")
+			{{$cret}} = _CffiHelper.lib.{{$class.FromJsonMethodName}}(_CffiHelper.py2c_string(data))
+			if not VeilError.is_nil({{$cret}}.r1):
+				_CffiHelper.cgo_decref({{$cret}}.r0)
+				raise VeilError({{$cret}}.r1)
+			return {{$cret}}.r0
+
+		def to_dict(self):
+			"""Convert the {{$class.Name}} to plain Python data in a single call through its JSON encoding"""
+			return json.loads(self.to_json())
+
+		@classmethod
+		def from_dict(cls, data):
+			"""Create a new {{$class.Name}} from plain Python data in a single call through its JSON encoding"""
+			return cls.from_json(json.dumps(data))
+
+		def __getstate__(self):
+			{{- if $class.PickleError}}
+			raise TypeError({{$class.PickleError}})
+			{{- else}}
+			"""Pickle the {{$class.Name}} as its JSON encoding, which leaves out fields tagged json:"-" """
+			return self.to_json()
+			{{- end}}
+
+		def __setstate__(self, state):
+			# __del__ still runs when decoding raises, so it must find nothing to release
+			self._uuid_ptr = None
+			self._tracked = False
+			{{$class.Name}}.__init__(self, uuid_ptr=self.__go_from_json__(state))
+
 		{{if $class.Constructors}}# Constructors{{end}}
 
 		{{range $_, $func := $class.Constructors }}
-		@staticmethod
-		def {{$func.Name}}({{$func.PrintArgs}}):
-			{{ range $_, $param := $func.Params -}}
-			  {{ $param.InputFormat }}
+		@classmethod
+		def {{$func.Name}}(cls{{if $func.PrintParams}}, {{end}}{{$func.PrintParams}}):
+{{- with $func.Docstring "      "}}
+			{{.}}
+{{- end}}
+{{- with $func.Deprecation $class.Name}}
+			{{.}}
+{{- end}}
+			{{ range $_, $inTrx := $func.InputTransforms -}}
+			  {{ $inTrx }}
 			{{ end -}}
 			{{$cret}} = _CffiHelper.lib.{{$func.Call -}}
 			{{ range $idx, $result := $func.Results -}}
@@ -229,15 +614,21 @@ class {{$class.Name}}(VeilObject):
 				{{end}}
 				{{end}}
 			{{ end -}}
-			{{$func.PrintReturns}}
+			{{$func.ConstructorReturn}}
 
 		{{end -}}
 
 		{{if $class.Methods}}# Methods{{end}}
 		{{range $_, $func := $class.Methods }}
-		def {{$func.Name}}(self{{if $func.PrintArgs}}, {{end}}{{$func.PrintArgs}}):
-			{{ range $_, $param := $func.Params -}}
-			  {{ $param.InputFormat }}
+		def {{$func.Name}}(self{{if $func.PrintParams}}, {{end}}{{$func.PrintParams}}):
+{{- with $func.Docstring "      "}}
+			{{.}}
+{{- end}}
+{{- with $func.Deprecation $class.Name}}
+			{{.}}
+{{- end}}
+			{{ range $_, $inTrx := $func.InputTransforms -}}
+			  {{ $inTrx }}
 			{{ end -}}
 			{{$cret}} = _CffiHelper.lib.{{$func.Call -}}
 			{{ range $idx, $result := $func.Results -}}
@@ -256,17 +647,48 @@ class {{$class.Name}}(VeilObject):
 
 		{{end -}}
 
+		{{range $_, $prop := $class.Properties -}}
+		@property
+		def {{$prop.Name}}(self):
+{{- with $prop.Docstring "      "}}
+			{{.}}
+{{- end}}
+			return self.{{$prop.Getter.Name}}()
+
+		@{{$prop.Name}}.setter
+		def {{$prop.Name}}(self, value):
+			self.{{$prop.Setter.Name}}(value)
+
+		{{end -}}
+
+		{{template "protocols" $class.Protocols}}
+
 		{{if $class.Fields}}# Properties{{end}}
 		{{ range $_, $field := $class.Fields -}}
 		@property
 		def {{$field.Name}}(self):
-			cret = _CffiHelper.lib.{{$class.MethodName $field}}_get(self._uuid_ptr)
+{{- with $field.Docstring "      "}}
+			{{.}}
+{{- end}}
+{{- with $field.Deprecation $class.Name}}
+			{{.}}
+{{- end}}
+			{{if $field.ViewClassName -}}
+			if {{$field.ViewClassName}}._veil_semantics == VEIL_VIEW:
+				return {{$field.ViewClassName}}(resolver=lambda: _CffiHelper.lib.{{$field.RefName}}(self.uuid_ptr()))
+			{{end -}}
+			cret = _CffiHelper.lib.{{$field.GetterName}}(self.uuid_ptr())
 			return {{$field.ReturnFormatWithName "cret"}}
 
+		{{if not $field.ReadOnly -}}
 		@{{$field.Name}}.setter
 		def {{$field.Name}}(self, value):
-			{{with $format := $field.InputFormatWithName "value"}}{{if $format}}{{$format}}{{end}}{{end}}
-			_CffiHelper.lib.{{$class.MethodName $field}}_set(self._uuid_ptr, value)
+{{- with $field.Deprecation $class.Name}}
+			{{.}}
+{{- end}}
+			{{with $format := $field.InputFormatWithNameAndLabel "value" $field.Name}}{{if $format}}{{$format}}{{end}}{{end}}
+			_CffiHelper.lib.{{$field.SetterName}}(self.uuid_ptr(), {{$field.CArgWithName "value"}})
+		{{end -}}
     {{ end -}}
 
 {{end}}
@@ -281,42 +703,240 @@ def _internal_{{$iface.CName}}_{{$func.Name}}(userdata):
 {{end}}
 	obj = ffi.from_handle(userdata)
 	{{ range $_, $param := $func.Params -}}
-	  {{ printf "%s = %s" $param.Name $param.ReturnFormatUntracked }}
+	  {{ printf "%s = %s" $param.Name $param.CallbackFormat }}
 	{{ end -}}
-	ret = obj.{{$func.Name}}({{$func.PrintArgs}})
+	{{$func.CallbackInvocation "obj"}}
 	{{$cret}} = ffi.new("ReturnType_2 *")
 	{{range $idx, $res := $func.Results -}}
-	{{$cret}}.r{{$idx}} = _CffiHelper.py2c(ret[{{$idx}}])
+	{{$cret}}.r{{$idx}} = _CffiHelper.py2c(ret[{{$idx}}]{{with $ctype := $res.CType}}, "{{$ctype}}"{{end}})
 	{{end -}}
 	return {{$cret}}
 
 {{end -}}
 class {{$iface.Name}}(VeilObject):
-		def __init__(self, uuid_ptr=None):
+		{{$iface.Docstring "    "}}
+
+		def __init__(self, uuid_ptr=None, tracked=True):
+			self._handle = None
 			if uuid_ptr is None:
+{{- with $iface.Deprecation}}
+				{{.}}
+{{- end}}
 				self._handle = ffi.new_handle(self)
 				uuid_ptr = self.__get_method__("new")(self._handle)
-			super({{$iface.Name}}, self).__init__(uuid_ptr)
-			{{range $_, $func := $iface.Methods }}
-			self.__get_method__("register_callback")(self.uuid_ptr(), _CffiHelper.py2c_string("{{$func.RegistrationName}}"), _internal_{{$iface.CName}}_{{$func.Name}})
-			{{end}}
+				tracked = True
+			super({{$iface.Name}}, self).__init__(uuid_ptr, tracked=tracked)
+			if self._handle is not None:
+				{{range $_, $func := $iface.Methods -}}
+				self.__get_method__("register_callback")(self.uuid_ptr(), _CffiHelper.py2c_string("{{$func.RegistrationName}}"), _internal_{{$iface.CName}}_{{$func.Name}})
+				{{end}}
 
+		{{- if $iface.IsStream}}
+		# file objects with these methods are adapted to {{$iface.Name}} when passed to Go
+		__go_stream__ = {{$iface.StreamAttributes}}
+		{{end}}
 
-		def __go_type__(self):
+		@classmethod
+		def __go_type__(cls):
 			return "{{$iface.CName}}"
 
-		def __get_method__(self, method_name):
-			return getattr(_CffiHelper.lib, self.__go_type__() + "_" + method_name)
+		@classmethod
+		def __get_method__(cls, method_name):
+			return getattr(_CffiHelper.lib, cls.__go_type__() + "_" + method_name)
 
 
-		{{range $_, $func := $iface.Methods }}
+		{{range $_, $func := $iface.Methods }}{{if not $func.Property}}
 		@abstractmethod
 		def {{$func.Name}}(self, {{$func.PrintArgs}}):
+{{- with $func.Docstring "      "}}
+			{{.}}
+{{- end}}
+			pass
+		{{end}}
+		{{end}}
+		{{- range $_, $prop := $iface.Properties}}
+		@property
+		@abstractmethod
+		def {{$prop.Name}}(self):
+			pass
+
+		@{{$prop.Name}}.setter
+		@abstractmethod
+		def {{$prop.Name}}(self, value):
 			pass
 
+		{{end}}
+
+class {{$iface.ProxyName}}({{$iface.Name}}{{if $iface.IsStream}}, io.RawIOBase{{end}}):
+		"""A Go value implementing {{$iface.Name}}{{if $iface.IsStream}}, usable as a Python file object{{end}}"""
+
+		def __init__(self, uuid_ptr, tracked=True):
+			super({{$iface.ProxyName}}, self).__init__(uuid_ptr=uuid_ptr, tracked=tracked)
+
+		def __go_str__(self):
+			cret = _CffiHelper.lib.{{$iface.ToStringMethodName}}(self.uuid_ptr())
+			return _CffiHelper.c2py_string(cret)
+
+		{{template "protocols" $iface.Protocols}}
+
+		{{range $_, $func := $iface.Methods }}
+		def {{$func.ProxyMethodName}}(self{{if $func.PrintArgs}}, {{end}}{{$func.PrintArgs}}):
+			{{ range $_, $param := $func.Params -}}
+			  {{ $param.InputFormat }}
+			{{ end -}}
+			{{$cret}} = _CffiHelper.lib.{{$func.Call -}}
+			{{ range $idx, $result := $func.Results -}}
+				{{if $result.IsError}}
+				{{if gt ($func.ResultsLength) 1}}
+			{{ printf "if not VeilError.is_nil(%s.r%d):" $cret $idx}}
+				{{ printf "raise VeilError(%s.r%d)" $cret $idx -}}
+				{{end}}
+				{{if eq ($func.ResultsLength) 1}}
+			if not VeilError.is_nil({{$cret}}):
+				raise VeilError({{$cret}})
+				{{end}}
+				{{end}}
+			{{ end -}}
+			{{$func.PrintReturns}}
+
+		{{end -}}
+
+		{{- if $iface.IsStream}}
+		def readable(self):
+			return {{if $iface.HasStreamMethod "Read"}}True{{else}}False{{end}}
+
+		def writable(self):
+			return {{if $iface.HasStreamMethod "Write"}}True{{else}}False{{end}}
+
+		{{if $iface.HasStreamMethod "Read" -}}
+		def read(self, size=-1):
+			return io.RawIOBase.read(self, size)
+
+		def readinto(self, b):
+			buf = ffi.from_buffer(b)
+			{{$cret}} = _CffiHelper.lib.{{$iface.StreamMethodName "Read"}}(self.uuid_ptr(), buf, len(buf))
+			if {{$cret}}.r1 != ffi.NULL:
+				raise IOError(_CffiHelper.c2py_string({{$cret}}.r1))
+			return {{$cret}}.r0
+
+		{{end -}}
+		{{if $iface.HasStreamMethod "Write" -}}
+		def write(self, b):
+			buf = ffi.from_buffer(b)
+			{{$cret}} = _CffiHelper.lib.{{$iface.StreamMethodName "Write"}}(self.uuid_ptr(), buf, len(buf))
+			if {{$cret}}.r1 != ffi.NULL:
+				raise IOError(_CffiHelper.c2py_string({{$cret}}.r1))
+			return {{$cret}}.r0
+
+		{{end -}}
+		def close(self):
+			if self.closed:
+				return
+			try:
+				{{if $iface.HasStreamMethod "Close"}}self._go_close(){{else}}pass{{end}}
+			finally:
+				io.RawIOBase.close(self)
+
+		{{end -}}
+
+		{{range $_, $prop := $iface.Properties -}}
+		@property
+		def {{$prop.Name}}(self):
+{{- with $prop.Docstring "      "}}
+			{{.}}
+{{- end}}
+			return self.{{$prop.Getter.Name}}()
+
+		@{{$prop.Name}}.setter
+		def {{$prop.Name}}(self, value):
+			self.{{$prop.Setter.Name}}(value)
+
 		{{end -}}
 {{end}}
 
+{{define "protocols"}}
+		{{- if .GoStr}}
+		def __repr__(self):
+			return self.__go_str__()
+		{{end}}
+		{{- if .String}}
+		def __str__(self):
+			return self.{{.String.Name}}()
+		{{end}}
+		{{- if .HasEquality}}
+		def __eq__(self, other):
+			if not isinstance(other, {{.ClassName}}):
+				return NotImplemented
+			{{if .Equal -}}
+			return self.{{.Equal.Name}}(other)
+			{{- else -}}
+			return bool(_CffiHelper.lib.{{.EqualMethod}}(self.uuid_ptr(), other.uuid_ptr()))
+			{{- end}}
+
+		def __ne__(self, other):
+			equal = self.__eq__(other)
+			return equal if equal is NotImplemented else not equal
+		{{if .HashMethod}}
+		def __hash__(self):
+			return int(_CffiHelper.lib.{{.HashMethod}}(self.uuid_ptr()))
+		{{else}}
+		__hash__ = None
+		{{end}}
+		{{- end}}
+		{{- if .HasOrdering}}
+		def __lt__(self, other):
+			if not isinstance(other, {{.ClassName}}):
+				return NotImplemented
+			return {{if .Compare}}self.{{.Compare.Name}}(other) < 0{{else}}self.{{.Less.Name}}(other){{end}}
+
+		def __le__(self, other):
+			if not isinstance(other, {{.ClassName}}):
+				return NotImplemented
+			return {{if .Compare}}self.{{.Compare.Name}}(other) <= 0{{else}}not other.{{.Less.Name}}(self){{end}}
+
+		def __gt__(self, other):
+			if not isinstance(other, {{.ClassName}}):
+				return NotImplemented
+			return {{if .Compare}}self.{{.Compare.Name}}(other) > 0{{else}}other.{{.Less.Name}}(self){{end}}
+
+		def __ge__(self, other):
+			if not isinstance(other, {{.ClassName}}):
+				return NotImplemented
+			return {{if .Compare}}self.{{.Compare.Name}}(other) >= 0{{else}}not self.{{.Less.Name}}(other){{end}}
+		{{end}}
+		{{- if .Len}}
+		def __len__(self):
+			return self.{{.Len.Name}}()
+
+		def __getitem__(self, idx):
+			length = len(self)
+			if isinstance(idx, slice):
+				return [self.{{.At.Name}}(i) for i in range(*idx.indices(length))]
+			if not isinstance(idx, numbers.Integral):
+				raise TypeError("{{.ClassName}} indices must be integers or slices, not {}".format(type(idx).__name__))
+			if idx < 0:
+				idx += length
+			if idx < 0 or idx >= length:
+				raise IndexError("{{.ClassName}} index out of range")
+			return self.{{.At.Name}}(idx)
+		{{end}}
+		{{- if .Next}}
+		def __iter__(self):
+			while self.{{.Next.Name}}():
+				yield self.{{.Value.Name}}()
+			{{- if .Err}}
+			self.{{.Err.Name}}()
+			{{- end}}
+		{{end}}
+		{{- if .Close}}
+		def __enter__(self):
+			return self
+
+		def __exit__(self, exc_type, exc_value, traceback):
+			self.{{.Close.Name}}()
+			return False
+		{{end}}
+{{- end}}
 `
 )
 
@@ -0,0 +1,342 @@
+package python
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/devigned/veil/core"
+)
+
+const (
+	INIT_FILE_NAME      = "__init__.py"
+	PY_TYPED_FILE_NAME  = "py.typed"
+	PYPROJECT_FILE_NAME = "pyproject.toml"
+
+	// WHEEL_TAG prefixes the platform tag of wheels. The cffi ABI mode binding loads the shared library at runtime,
+	// so wheels work with any Python 3 and ABI.
+	WHEEL_TAG = "py3-none-"
+
+	INIT_TEMPLATE = `"""{{.Summary}}"""
+from .generated import *  # noqa: F401,F403
+`
+
+	PYPROJECT_TEMPLATE = `[build-system]
+requires = ["setuptools>=61"]
+build-backend = "setuptools.build_meta"
+
+[project]
+name = "{{.Name}}"
+version = "{{.Version}}"
+description = "{{.Summary}}"
+requires-python = ">=3"
+dependencies = ["cffi>=1.0"]
+
+[tool.setuptools]
+packages = ["{{.Name}}"]
+
+[tool.setuptools.package-data]
+{{.Name}} = ["{{.LibName}}", "*.pyi", "{{.PyTypedFileName}}"]
+`
+
+	METADATA_TEMPLATE = `Metadata-Version: 2.1
+Name: {{.Name}}
+Version: {{.Version}}
+Summary: {{.Summary}}
+Requires-Python: >=3
+Requires-Dist: cffi>=1.0
+`
+
+	WHEEL_METADATA_TEMPLATE = `Wheel-Version: 1.0
+Generator: veil {{.Generator}}
+Root-Is-Purelib: false
+Tag: {{.Tag}}
+`
+)
+
+var (
+	initTemplate          = template.Must(template.New("init").Parse(INIT_TEMPLATE))
+	pyprojectTemplate     = template.Must(template.New("pyproject").Parse(PYPROJECT_TEMPLATE))
+	metadataTemplate      = template.Must(template.New("metadata").Parse(METADATA_TEMPLATE))
+	wheelMetadataTemplate = template.Must(template.New("wheel").Parse(WHEEL_METADATA_TEMPLATE))
+
+	packageNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	semverPattern      = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+	pseudoPattern      = regexp.MustCompile(`(?:^|\.)(\d{14})-([0-9a-f]{12})$`)
+	preReleasePattern  = regexp.MustCompile(`^(alpha|a|beta|b|rc|c|pre|preview|dev)[.-]?(\d*)$`)
+	nonAlphanumeric    = regexp.MustCompile(`[^0-9A-Za-z]+`)
+
+	// preReleaseSegments are the PEP 440 segments of the prerelease identifiers of semantic versions
+	preReleaseSegments = map[string]string{
+		"alpha": "a", "a": "a", "beta": "b", "b": "b", "rc": "rc", "c": "rc", "pre": "rc", "preview": "rc",
+		"dev": ".dev",
+	}
+
+	// platformTags are the wheel platform tags of the GOOS/GOARCH pairs the shared library can be built for.
+	// Linux wheels are tagged for the build machine rather than manylinux, since cgo links against its libc.
+	platformTags = map[string]string{
+		"linux/amd64":   "linux_x86_64",
+		"linux/386":     "linux_i686",
+		"linux/arm64":   "linux_aarch64",
+		"linux/arm":     "linux_armv7l",
+		"linux/ppc64le": "linux_ppc64le",
+		"linux/s390x":   "linux_s390x",
+		"linux/riscv64": "linux_riscv64",
+		"darwin/amd64":  "macosx_11_0_x86_64",
+		"darwin/arm64":  "macosx_11_0_arm64",
+		"windows/amd64": "win_amd64",
+		"windows/386":   "win32",
+		"windows/arm64": "win_arm64",
+	}
+
+	// wheelTime is the modification time of the files in wheels, so building the same binding twice produces the
+	// same wheel. Zip files can't represent earlier times.
+	wheelTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Project is the Python project which packages a binding with its shared library, so it can be installed with pip
+type Project struct {
+	// Name is the name of the project and the Python package it is imported as
+	Name string
+	// Version is the PEP 440 version of the project
+	Version string
+	Summary string
+	LibName string
+	// Platform is the wheel platform tag of the shared library, such as linux_x86_64
+	Platform string
+}
+
+// NewProject creates the Project named name packaging the binding built for goos and goarch
+func NewProject(name, version, summary, libName, goos, goarch string) (*Project, error) {
+	if !packageNamePattern.MatchString(name) || IsReservedWord(name) {
+		return nil, core.NewUserErrorF("Python package name %q must be a valid identifier", name)
+	}
+	platform, ok := platformTags[goos+"/"+goarch]
+	if !ok {
+		return nil, core.NewUserErrorF("Wheels can't be built for %s/%s", goos, goarch)
+	}
+	return &Project{
+		Name:     name,
+		Version:  version,
+		Summary:  summary,
+		LibName:  libName,
+		Platform: platform,
+	}, nil
+}
+
+// PythonVersion converts the semantic version of a Go module, such as v1.2.0-rc.1, into a PEP 440 version such
+// as 1.2.0rc1. Pseudo-versions of untagged commits become development releases, and build metadata becomes a
+// local version.
+func PythonVersion(goVersion string) (string, error) {
+	match := semverPattern.FindStringSubmatch(strings.TrimSuffix(goVersion, "+incompatible"))
+	if match == nil {
+		return "", core.NewUserErrorF("Version %q isn't a semantic version", goVersion)
+	}
+
+	version := match[1] + "." + match[2] + "." + match[3]
+	local := match[5]
+	if pre := match[4]; pre != "" {
+		if pseudo := pseudoPattern.FindStringSubmatch(pre); pseudo != nil {
+			version += ".dev" + pseudo[1]
+			local = pseudo[2]
+		} else if segment := preReleasePattern.FindStringSubmatch(strings.ToLower(pre)); segment != nil {
+			number := segment[2]
+			if number == "" {
+				number = "0"
+			}
+			version += preReleaseSegments[segment[1]] + number
+		} else {
+			return "", core.NewUserErrorF("Prerelease %q of version %q has no Python equivalent", pre, goVersion)
+		}
+	}
+	if local != "" {
+		version += "+" + strings.Trim(nonAlphanumeric.ReplaceAllString(local, "."), ".")
+	}
+	return version, nil
+}
+
+// DistName returns the name of the project as written in the names of wheels and their metadata directories,
+// which is normalized to lower case
+func (p Project) DistName() string {
+	return strings.ToLower(p.Name)
+}
+
+// WheelName returns the file name of the wheel of the project
+func (p Project) WheelName() string {
+	return fmt.Sprintf("%s-%s-%s.whl", p.DistName(), strings.Replace(p.Version, "-", "_", -1), p.Tag())
+}
+
+// Tag returns the compatibility tag of the wheel of the project
+func (p Project) Tag() string {
+	return WHEEL_TAG + p.Platform
+}
+
+// PyTypedFileName returns the name of the PEP 561 marker of packages with type hints
+func (p Project) PyTypedFileName() string {
+	return PY_TYPED_FILE_NAME
+}
+
+// Layout lays out the project in projectDir from the binding and shared library built in buildDir. The Python
+// package is replaced, so files of earlier bindings don't linger.
+func (p Project) Layout(buildDir, projectDir string) error {
+	pkgDir := filepath.Join(projectDir, p.Name)
+	if err := os.RemoveAll(pkgDir); err != nil {
+		return core.NewSystemErrorF("Unable to remove %s: %v", pkgDir, err)
+	}
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		return core.NewSystemErrorF("Unable to create %s: %v", pkgDir, err)
+	}
+
+	for _, name := range []string{FILE_NAME, STUB_FILE_NAME, p.LibName} {
+		if err := copyFile(filepath.Join(buildDir, name), filepath.Join(pkgDir, name)); err != nil {
+			return err
+		}
+	}
+
+	files := map[string]*template.Template{
+		filepath.Join(pkgDir, INIT_FILE_NAME):          initTemplate,
+		filepath.Join(projectDir, PYPROJECT_FILE_NAME): pyprojectTemplate,
+	}
+	for filePath, tmpl := range files {
+		data, err := render(tmpl, p)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, data, 0644); err != nil {
+			return core.NewSystemErrorF("Unable to write %s: %v", filePath, err)
+		}
+	}
+
+	// the package has the type hints of generated.pyi
+	typedPath := filepath.Join(pkgDir, PY_TYPED_FILE_NAME)
+	if err := os.WriteFile(typedPath, nil, 0644); err != nil {
+		return core.NewSystemErrorF("Unable to write %s: %v", typedPath, err)
+	}
+	return nil
+}
+
+// BuildWheel builds the wheel of the project laid out in projectDir into distDir and returns its path
+func (p Project) BuildWheel(projectDir, distDir string) (string, error) {
+	pkgDir := filepath.Join(projectDir, p.Name)
+	entries, err := os.ReadDir(pkgDir)
+	if err != nil {
+		return "", core.NewSystemErrorF("Unable to read %s: %v", pkgDir, err)
+	}
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return "", core.NewSystemErrorF("Unable to create %s: %v", distDir, err)
+	}
+
+	wheelPath := filepath.Join(distDir, p.WheelName())
+	var buf bytes.Buffer
+	wheel := zip.NewWriter(&buf)
+	record := []string{}
+	add := func(name string, data []byte, mode os.FileMode) error {
+		if err := writeZipFile(wheel, name, data, mode); err != nil {
+			return core.NewSystemErrorF("Unable to add %s to %s: %v", name, wheelPath, err)
+		}
+		sum := sha256.Sum256(data)
+		record = append(record, fmt.Sprintf("%s,sha256=%s,%d", name,
+			base64.RawURLEncoding.EncodeToString(sum[:]), len(data)))
+		return nil
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filePath := filepath.Join(pkgDir, name)
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return "", core.NewSystemErrorF("Unable to read %s: %v", filePath, err)
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", core.NewSystemErrorF("Unable to read %s: %v", filePath, err)
+		}
+		if err := add(path.Join(p.Name, name), data, info.Mode().Perm()); err != nil {
+			return "", err
+		}
+	}
+
+	distInfo := fmt.Sprintf("%s-%s.dist-info", p.DistName(), p.Version)
+	metadata, err := render(metadataTemplate, p)
+	if err != nil {
+		return "", err
+	}
+	wheelMetadata, err := render(wheelMetadataTemplate, map[string]string{"Generator": core.Version, "Tag": p.Tag()})
+	if err != nil {
+		return "", err
+	}
+	if err := add(path.Join(distInfo, "METADATA"), metadata, 0644); err != nil {
+		return "", err
+	}
+	if err := add(path.Join(distInfo, "WHEEL"), wheelMetadata, 0644); err != nil {
+		return "", err
+	}
+	// the RECORD lists every file of the wheel with its hash, except for itself
+	recordName := path.Join(distInfo, "RECORD")
+	record = append(record, recordName+",,")
+	err = writeZipFile(wheel, recordName, []byte(strings.Join(record, "\n")+"\n"), 0644)
+	if err == nil {
+		err = wheel.Close()
+	}
+	if err != nil {
+		return "", core.NewSystemErrorF("Unable to build %s: %v", wheelPath, err)
+	}
+
+	if err := os.WriteFile(wheelPath, buf.Bytes(), 0644); err != nil {
+		return "", core.NewSystemErrorF("Unable to write %s: %v", wheelPath, err)
+	}
+	return wheelPath, nil
+}
+
+// writeZipFile adds the file name with data and the permissions mode to w
+func writeZipFile(w *zip.Writer, name string, data []byte, mode os.FileMode) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: wheelTime}
+	header.SetMode(mode)
+	f, err := w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// render executes tmpl with data
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, core.NewSystemErrorF("Unable to render %s: %v", tmpl.Name(), err)
+	}
+	return buf.Bytes(), nil
+}
+
+// copyFile copies the file at src to dst, keeping its permissions
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return core.NewSystemErrorF("Unable to read %s: %v", src, err)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return core.NewSystemErrorF("Unable to read %s: %v", src, err)
+	}
+	if err := os.WriteFile(dst, data, info.Mode().Perm()); err != nil {
+		return core.NewSystemErrorF("Unable to write %s: %v", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package python
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPythonVersion(t *testing.T) {
+	versions := map[string]string{
+		"v1.2.3":                               "1.2.3",
+		"v1.2.0-rc.1":                          "1.2.0rc1",
+		"v2.0.0-beta":                          "2.0.0b0",
+		"v1.0.0+build.7":                       "1.0.0+build.7",
+		"v3.1.0+incompatible":                  "3.1.0",
+		"v0.0.0-20180103174451-36e9d2ebbde5":   "0.0.0.dev20180103174451+36e9d2ebbde5",
+		"v1.2.4-0.20180103174451-36e9d2ebbde5": "1.2.4.dev20180103174451+36e9d2ebbde5",
+	}
+	for goVersion, expected := range versions {
+		version, err := PythonVersion(goVersion)
+		if assert.NoError(t, err, goVersion) {
+			assert.Equal(t, expected, version, goVersion)
+		}
+	}
+
+	_, err := PythonVersion("v1.0.0-custom")
+	assert.Error(t, err, "prereleases without a Python equivalent")
+	_, err = PythonVersion("latest")
+	assert.Error(t, err)
+}
+
+func TestBuildWheel(t *testing.T) {
+	_, err := NewProject("my-package", "1.0.0", "", "libgen", "linux", "amd64")
+	assert.Error(t, err, "package names must be identifiers")
+	_, err = NewProject("hello", "1.0.0", "", "libgen", "plan9", "amd64")
+	assert.Error(t, err, "unsupported platforms")
+
+	buildDir, outDir := t.TempDir(), t.TempDir()
+	for _, name := range []string{FILE_NAME, STUB_FILE_NAME, "libgen"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(buildDir, name), []byte(name), 0644))
+	}
+	project, err := NewProject("Hello", "1.0.0rc1", "Python bindings of hello", "libgen", "linux", "amd64")
+	if !assert.NoError(t, err) {
+		return
+	}
+	projectDir := filepath.Join(outDir, "Hello")
+	assert.NoError(t, project.Layout(buildDir, projectDir))
+	wheelPath, err := project.BuildWheel(projectDir, filepath.Join(outDir, "dist"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "hello-1.0.0rc1-py3-none-linux_x86_64.whl", filepath.Base(wheelPath))
+
+	wheel, err := zip.OpenReader(wheelPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer wheel.Close()
+	files := map[string]string{}
+	for _, f := range wheel.File {
+		r, err := f.Open()
+		if assert.NoError(t, err) {
+			data, _ := io.ReadAll(r)
+			files[f.Name] = string(data)
+			r.Close()
+		}
+	}
+
+	for _, name := range []string{INIT_FILE_NAME, FILE_NAME, STUB_FILE_NAME, PY_TYPED_FILE_NAME, "libgen"} {
+		assert.Contains(t, files, "Hello/"+name)
+	}
+	assert.Contains(t, files["Hello/"+INIT_FILE_NAME], "from .generated import *")
+	assert.Contains(t, files["hello-1.0.0rc1.dist-info/METADATA"], "Requires-Dist: cffi")
+	assert.Contains(t, files["hello-1.0.0rc1.dist-info/WHEEL"], "Tag: py3-none-linux_x86_64")
+	record := strings.Split(strings.TrimSpace(files["hello-1.0.0rc1.dist-info/RECORD"]), "\n")
+	assert.Len(t, record, len(files), "every file is recorded")
+	assert.Contains(t, record, "Hello/libgen,sha256=nxkAfIAPGO2RK5zuwZdJG00GGklqo2KRfPgyl1s6cs8,6")
+}
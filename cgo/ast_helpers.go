@@ -16,9 +16,13 @@ const (
 	ERROR_TO_STRING_FUNC_NAME = "cgo_error_to_string"
 	IS_ERROR_NIL_FUNC_NAME    = "cgo_is_error_nil"
 	CFREE_FUNC_NAME           = "cgo_cfree"
+	HANDLE_FUNC_NAME          = "cgo_handle"
+	IFACE_HANDLE_FUNC_NAME    = "cgo_iface_handle"
+	TYPED_NIL_FUNC_NAME       = "cgo_typed_nil"
 	COBJECT_STRUCT_TYPE_NAME  = "cobject"
 	REFS_VAR_NAME             = "refs"
 	REFS_STRUCT_FIELD_NAME    = "refs"
+	TYPED_NILS_FIELD_NAME     = "typedNils"
 )
 
 var (
@@ -119,6 +123,13 @@ func RefsStruct() ast.Decl {
 									Value: NewIdent(COBJECT_STRUCT_TYPE_NAME),
 								},
 							},
+							{
+								Names: []*ast.Ident{NewIdent(TYPED_NILS_FIELD_NAME)},
+								Type: &ast.MapType{
+									Key:   uuidType,
+									Value: NewIdent("string"),
+								},
+							},
 						},
 					},
 				},
@@ -372,6 +383,27 @@ func Init() ast.Decl {
 					},
 				},
 			},
+		},
+		// refs.typedNils = make(map[uuid.UUID]string)
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{
+				&ast.SelectorExpr{
+					X:   refsVar,
+					Sel: NewIdent(TYPED_NILS_FIELD_NAME),
+				},
+			},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun: NewIdent("make"),
+					Args: []ast.Expr{
+						&ast.MapType{
+							Key:   uuidType,
+							Value: NewIdent("string"),
+						},
+					},
+				},
+			},
 		})
 	return &ast.FuncDecl{
 		Name: NewIdent("init"),
@@ -393,7 +425,18 @@ func DecrementRef() ast.Decl {
 	cnt := NewIdent("cnt")
 	del := NewIdent("delete")
 
-	statements := refLockUnlockDefer()
+	statements := []ast.Stmt{
+		// if ptr == nil {
+		//   return
+		// }
+		&ast.IfStmt{
+			Cond: IsNilExpr(ptr),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{&ast.ReturnStmt{}},
+			},
+		},
+	}
+	statements = append(statements, refLockUnlockDefer()...)
 	statements = append(statements,
 		// uid := *cgo_get_uuid_from_ptr(ptr)
 		&ast.AssignStmt{
@@ -493,6 +536,19 @@ func DecrementRef() ast.Decl {
 							},
 						},
 					},
+					// delete(refs.typedNils, uid)
+					&ast.ExprStmt{
+						X: &ast.CallExpr{
+							Fun: del,
+							Args: []ast.Expr{
+								&ast.SelectorExpr{
+									X:   refsType,
+									Sel: NewIdent(TYPED_NILS_FIELD_NAME),
+								},
+								uid,
+							},
+						},
+					},
 					&ast.ReturnStmt{},
 				},
 			},
@@ -850,6 +906,39 @@ func ErrorToString() ast.Decl {
 						DeRef(CastUnsafePtrOfTypeUuid(DeRef(NewIdent("error")), self)),
 					},
 				},
+				// if v := reflect.ValueOf(err); v.Kind() == reflect.Ptr && v.IsNil() {
+				//   return C.CString(fmt.Sprintf("non-nil error holding a nil %T", err))
+				// }
+				&ast.IfStmt{
+					Init: &ast.AssignStmt{
+						Lhs: []ast.Expr{NewIdent("v")},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{reflectValueOf(err)},
+					},
+					Cond: &ast.BinaryExpr{
+						X: &ast.BinaryExpr{
+							X:  &ast.CallExpr{Fun: &ast.SelectorExpr{X: NewIdent("v"), Sel: NewIdent("Kind")}},
+							Op: token.EQL,
+							Y:  &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("Ptr")},
+						},
+						Op: token.LAND,
+						Y:  &ast.CallExpr{Fun: &ast.SelectorExpr{X: NewIdent("v"), Sel: NewIdent("IsNil")}},
+					},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							Return(ToCString(&ast.CallExpr{
+								Fun: &ast.SelectorExpr{
+									X:   NewIdent("fmt"),
+									Sel: NewIdent("Sprintf"),
+								},
+								Args: []ast.Expr{
+									&ast.BasicLit{Kind: token.STRING, Value: "\"non-nil error holding a nil %T\""},
+									err,
+								},
+							})),
+						},
+					},
+				},
 				// return C.CString(err.Error())
 				Return(ToCString(
 					&ast.CallExpr{
@@ -884,6 +973,15 @@ func IsErrorNil() ast.Decl {
 		},
 		Body: &ast.BlockStmt{
 			List: []ast.Stmt{
+				// if self == nil {
+				//   return true
+				// }
+				&ast.IfStmt{
+					Cond: IsNilExpr(self),
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{Return(NewIdent("true"))},
+					},
+				},
 				// err := *(*error)(cgo_get_ref(cgo_get_uuid_from_ptr(ptr)))
 				&ast.AssignStmt{
 					Lhs: []ast.Expr{
@@ -953,6 +1051,14 @@ func ToCChar(targets ...ast.Expr) ast.Expr {
 	return ToC("char", targets...)
 }
 
+func ToCSChar(targets ...ast.Expr) ast.Expr {
+	return ToC("schar", targets...)
+}
+
+func ToCUChar(targets ...ast.Expr) ast.Expr {
+	return ToC("uchar", targets...)
+}
+
 func ToCShort(targets ...ast.Expr) ast.Expr {
 	return ToC("short", targets...)
 }
@@ -1102,19 +1208,18 @@ func Return(expressions ...ast.Expr) *ast.ReturnStmt {
 }
 
 // FormatSprintf takes a format and a target expression and returns a fmt.Sprintf expression
-func FormatSprintf(format string, target ast.Expr) *ast.CallExpr {
+func FormatSprintf(format string, targets ...ast.Expr) *ast.CallExpr {
 	fmtSprintf := &ast.CallExpr{
 		Fun: &ast.SelectorExpr{
 			X:   NewIdent("fmt"),
 			Sel: NewIdent("Sprintf"),
 		},
-		Args: []ast.Expr{
+		Args: append([]ast.Expr{
 			&ast.BasicLit{
 				Kind:  token.STRING,
 				Value: "\"" + format + "\"",
 			},
-			target,
-		},
+		}, targets...),
 	}
 
 	return fmtSprintf
@@ -1170,7 +1275,9 @@ func InstanceMethodParams(fields ...*ast.Field) *ast.FieldList {
 
 // FuncAst returns an FuncDecl which wraps the func
 func FuncAst(f *Func) *ast.FuncDecl {
-	if f.BoundRecv != nil {
+	if options := f.Options(); options != nil {
+		return buildOptionsMethod(f, options)
+	} else if f.BoundRecv != nil {
 		return buildBoundMethod(f)
 	} else {
 		return buildUnboundMethod(f)
@@ -1191,6 +1298,10 @@ func buildBoundMethod(f *Func) *ast.FuncDecl {
 	params := InstanceMethodParams(args...)
 
 	castExpression := CastUnsafePtrOfTypeUuid(DeRef(f.BoundRecv.CTypeName()), NewIdent("self"))
+	if isInterface(f.BoundRecv.Named) {
+		// methods can't be called through a pointer to an interface
+		castExpression = DeRef(castExpression)
+	}
 
 	selfCastAssign := &ast.AssignStmt{
 		Lhs: []ast.Expr{castSelfIdent},
@@ -1219,12 +1330,24 @@ func buildBoundMethod(f *Func) *ast.FuncDecl {
 		Args: callArgs,
 	}
 
-	assign, returnStmt, results := buildFuncResults(sig, functionCall)
 	var bodyStmts []ast.Stmt
-	if returnStmt == nil {
-		bodyStmts = append(assignStmts, selfCastAssign, assign)
+	var returnStmt *ast.ReturnStmt
+	var results *ast.FieldList
+	if HasOpaqueParams(sig) {
+		var callStmts []ast.Stmt
+		method := &ast.SelectorExpr{
+			X:   castSelfIdent,
+			Sel: NewIdent(f.Name()),
+		}
+		callStmts, returnStmt, results = buildOpaqueCall(sig, method, paramNames(sig.Params()))
+		bodyStmts = append([]ast.Stmt{selfCastAssign}, callStmts...)
 	} else {
-		bodyStmts = append(assignStmts, selfCastAssign, assign, returnStmt)
+		var assign ast.Stmt
+		assign, returnStmt, results = buildFuncResults(sig, functionCall)
+		bodyStmts = append(assignStmts, selfCastAssign, assign)
+	}
+	if returnStmt != nil {
+		bodyStmts = append(bodyStmts, returnStmt)
 	}
 
 	funcDecl := &ast.FuncDecl{
@@ -1264,11 +1387,19 @@ func buildUnboundMethod(f *Func) *ast.FuncDecl {
 
 	params := Fields(sig.Params())
 
-	assign, returnStmt, results := buildFuncResults(sig, functionCall)
-	if returnStmt == nil {
-		funcDecl.Body.List = append(funcDecl.Body.List, assign)
+	var returnStmt *ast.ReturnStmt
+	var results *ast.FieldList
+	if HasOpaqueParams(sig) {
+		var callStmts []ast.Stmt
+		callStmts, returnStmt, results = buildOpaqueCall(sig, f.AliasedGoName(), paramNames(sig.Params()))
+		funcDecl.Body.List = append(funcDecl.Body.List, callStmts...)
 	} else {
-		funcDecl.Body.List = append(funcDecl.Body.List, assign, returnStmt)
+		var assign ast.Stmt
+		assign, returnStmt, results = buildFuncResults(sig, functionCall)
+		funcDecl.Body.List = append(funcDecl.Body.List, assign)
+	}
+	if returnStmt != nil {
+		funcDecl.Body.List = append(funcDecl.Body.List, returnStmt)
 	}
 
 	funcDecl.Type = &ast.FuncType{
@@ -1307,17 +1438,20 @@ func buildFuncResults(sig *types.Signature, functionCall ast.Expr) (ast.Stmt, *a
 	}
 }
 
+// CastBasicArg converts a Go basic value to its C type. int, uint and uintptr keep their 64 bit width.
 func CastBasicArg(kind types.BasicKind, name ast.Expr) ast.Expr {
 	switch kind {
 	case types.String:
 		return ToCString(name)
-	case types.Int8, types.Uint8:
-		return ToCChar(name)
+	case types.Int8:
+		return ToCSChar(name)
+	case types.Uint8:
+		return ToCUChar(name)
 	case types.Int16:
 		return ToCShort(name)
 	case types.Uint16:
 		return ToCUShort(name)
-	case types.Int32, types.Int:
+	case types.Int32:
 		return ToCInt(name)
 	case types.Uint32:
 		return ToCUInt(name)
@@ -1325,9 +1459,9 @@ func CastBasicArg(kind types.BasicKind, name ast.Expr) ast.Expr {
 		return ToCFloat(name)
 	case types.Float64:
 		return ToCDouble(name)
-	case types.Int64:
+	case types.Int64, types.Int:
 		return ToCLongLong(name)
-	case types.Uint64:
+	case types.Uint64, types.Uint, types.Uintptr:
 		return ToCULongLong(name)
 	default:
 		return name
@@ -1336,6 +1470,13 @@ func CastBasicArg(kind types.BasicKind, name ast.Expr) ast.Expr {
 }
 
 func CastOut(t types.Type, name ast.Expr) ast.Expr {
+	if IsPullable(t) {
+		return PullCall(name)
+	}
+	if IsOpaque(t) {
+		return OpaqueHandleCall(name)
+	}
+
 	switch typ := t.(type) {
 	case *types.Basic:
 		if typ.Kind() == types.String {
@@ -1344,9 +1485,14 @@ func CastOut(t types.Type, name ast.Expr) ast.Expr {
 			return name
 		}
 	case *types.Pointer:
-		// already have a pointer, so just count the reference
-		return UuidToCBytes(IncrementRefCall(name))
+		// already have a pointer, so just count the reference unless it is nil
+		return HandleCall(ToUnsafePointer(name), IsNilExpr(name))
 	default:
+		if isInterface(typ) {
+			return IfaceHandleCall(ToUnsafePointer(Ref(name)), name)
+		} else if isTypeNilable(typ) {
+			return HandleCall(ToUnsafePointer(Ref(name)), IsNilExpr(name))
+		}
 		return UuidToCBytes(IncrementRefCall(Ref(name)))
 	}
 }
@@ -1365,6 +1511,14 @@ func ParamIdents(funcParams *types.Tuple) []ast.Expr {
 	return args
 }
 
+func paramNames(funcParams *types.Tuple) []string {
+	names := make([]string, funcParams.Len())
+	for i := 0; i < funcParams.Len(); i++ {
+		names[i] = funcParams.At(i).Name()
+	}
+	return names
+}
+
 func ParamExpr(param *types.Var, t types.Type) ast.Expr {
 	return CastExpr(t, NewIdent(param.Name()))
 }
@@ -1376,9 +1530,8 @@ func CastExpr(t types.Type, ident ast.Expr) ast.Expr {
 	case *types.Named:
 		path := PkgPathAliasFromString(t.Obj().Pkg().Path())
 		if _, ok := t.Underlying().(*types.Interface); ok {
-			typ := NewIdent(path + "_" + t.Obj().Name() + "_helper")
-			castExpr := DeRef(CastUnsafePtrOfTypeUuid(DeRef(typ), ident))
-			return castExpr
+			// interface handles hold either a host language helper or a Go value as the interface type
+			return NilSafeDeRef(TypeExpression(t), TypeExpression(t), ident)
 		} else {
 			typeName := t.Obj().Name()
 			castExpr := DeRef(CastUnsafePtrOfTypeUuid(
@@ -1392,8 +1545,7 @@ func CastExpr(t types.Type, ident ast.Expr) ast.Expr {
 	case *types.Slice:
 		slice := NewSlice(t.Elem())
 		goTypeExpr := slice.GoTypeExpr()
-		castExpr := DeRef(CastUnsafePtrOfTypeUuid(DeRef(goTypeExpr), ident))
-		return castExpr
+		return NilSafeDeRef(goTypeExpr, goTypeExpr, ident)
 	case *types.Basic:
 		if t.Kind() == types.String {
 			return ToGoString(ident)
@@ -1508,7 +1660,7 @@ func ShouldGenerateField(v *types.Var) bool {
 	if !v.Exported() {
 		return false
 	}
-	return shouldGenerate(v, v.Type())
+	return shouldGenerate(v, v.Type()) && UnsupportedReason(v.Type()) == ""
 }
 
 func ShouldGenerate(v *types.Var) bool {
@@ -1516,23 +1668,7 @@ func ShouldGenerate(v *types.Var) bool {
 }
 
 func shouldGenerate(v *types.Var, t types.Type) bool {
-	if strings.Contains(t.String(), "/vendor/") {
-		return false
-	}
-
-	supportedType := true
-	switch typ := t.(type) {
-	case *types.Chan, *types.Map, *types.Signature:
-		supportedType = false
-	case *types.Pointer:
-		return shouldGenerate(v, typ.Elem())
-	case *types.Named:
-		if _, ok := typ.Underlying().(*types.Interface); ok {
-			return NewInterface(typ).IsExportable()
-		}
-		return shouldGenerate(v, typ.Underlying())
-	}
-	return supportedType
+	return !IsOpaque(t)
 }
 
 type hasMethods interface {
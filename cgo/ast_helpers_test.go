@@ -0,0 +1,16 @@
+package cgo
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCastBasicArgKeepsWidth(t *testing.T) {
+	assert.Equal(t, "C.longlong(x)", exprString(CastBasicArg(types.Int, NewIdent("x"))))
+	assert.Equal(t, "C.ulonglong(x)", exprString(CastBasicArg(types.Uint, NewIdent("x"))))
+	assert.Equal(t, "C.ulonglong(x)", exprString(CastBasicArg(types.Uintptr, NewIdent("x"))))
+	assert.Equal(t, "C.schar(x)", exprString(CastBasicArg(types.Int8, NewIdent("x"))))
+	assert.Equal(t, "C.uchar(x)", exprString(CastBasicArg(types.Uint8, NewIdent("x"))))
+}
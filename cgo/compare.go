@@ -0,0 +1,296 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+const (
+	HASH_FUNC_NAME     = "cgo_hash"
+	HASH_KEY_FUNC_NAME = "cgo_hash_key"
+)
+
+/*
+Comparable structs are compared with Go == so host languages can use them as keys in sets and maps. Their hash
+is taken from a key formatting their fields, which is the same for any two values which are ==.
+*/
+
+// IsComparable returns true if values of t can be compared with Go == without panicking, which rules out types
+// holding interfaces since their dynamic values may not be comparable
+func IsComparable(t types.Type) bool {
+	if !types.Comparable(t) {
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		return false
+	case *types.Array:
+		return IsComparable(u.Elem())
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if !IsComparable(u.Field(i).Type()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsComparable returns true if the struct can be compared with Go ==
+func (s Struct) IsComparable() bool {
+	return IsComparable(s.Named.Named)
+}
+
+// EqualMethodName returns the name of the function comparing two handles to the struct
+func (s Struct) EqualMethodName() string {
+	return s.CName() + "_equal"
+}
+
+// HashMethodName returns the name of the function hashing a handle to the struct
+func (s Struct) HashMethodName() string {
+	return s.CName() + "_hash"
+}
+
+// EqualAst produces a function which compares the struct values behind two handles with Go ==
+//
+//	func veil_pkg_World_equal(self unsafe.Pointer, other unsafe.Pointer) bool {
+//		return *(*pkg.World)(cgo_get_ref(cgo_get_uuid_from_ptr(self))) ==
+//			*(*pkg.World)(cgo_get_ref(cgo_get_uuid_from_ptr(other)))
+//	}
+func (s Struct) EqualAst() ast.Decl {
+	functionName := s.EqualMethodName()
+	value := func(name string) ast.Expr {
+		return DeRef(CastUnsafePtrOfTypeUuid(DeRef(s.CTypeName()), NewIdent(name)))
+	}
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(&ast.Field{
+				Names: []*ast.Ident{NewIdent("other")},
+				Type:  unsafePointer,
+			}),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: NewIdent("bool")}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				Return(&ast.BinaryExpr{X: value("self"), Op: token.EQL, Y: value("other")}),
+			},
+		},
+	}
+}
+
+// HashAst produces a function which hashes the struct value behind a handle
+//
+//	func veil_pkg_World_hash(self unsafe.Pointer) uint64 {
+//		return cgo_hash(*(*pkg.World)(cgo_get_ref(cgo_get_uuid_from_ptr(self))))
+//	}
+func (s Struct) HashAst() ast.Decl {
+	functionName := s.HashMethodName()
+	value := DeRef(CastUnsafePtrOfTypeUuid(DeRef(s.CTypeName()), NewIdent("self")))
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: NewIdent("uint64")}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				Return(&ast.CallExpr{Fun: NewIdent(HASH_FUNC_NAME), Args: []ast.Expr{value}}),
+			},
+		},
+	}
+}
+
+// HashFunc produces the function which hashes a comparable value with FNV-1a over its hash key
+//
+//	func cgo_hash(v interface{}) uint64 {
+//		hash := uint64(14695981039346656037)
+//		for _, b := range []byte(cgo_hash_key(reflect.ValueOf(v))) {
+//			hash ^= uint64(b)
+//			hash *= 1099511628211
+//		}
+//		return hash
+//	}
+func HashFunc() ast.Decl {
+	v := NewIdent("v")
+	hash := NewIdent("hash")
+	b := NewIdent("b")
+	uint64Ident := NewIdent("uint64")
+
+	return &ast.FuncDecl{
+		Name: NewIdent(HASH_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{{Names: []*ast.Ident{v}, Type: &ast.InterfaceType{Methods: &ast.FieldList{}}}},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: uint64Ident}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{hash},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun:  uint64Ident,
+						Args: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "14695981039346656037"}},
+					}},
+				},
+				&ast.RangeStmt{
+					Key:   NewIdent("_"),
+					Value: b,
+					Tok:   token.DEFINE,
+					X: &ast.CallExpr{
+						Fun: &ast.ArrayType{Elt: NewIdent("byte")},
+						Args: []ast.Expr{&ast.CallExpr{
+							Fun:  NewIdent(HASH_KEY_FUNC_NAME),
+							Args: []ast.Expr{reflectValueOf(v)},
+						}},
+					},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.AssignStmt{
+								Lhs: []ast.Expr{hash},
+								Tok: token.XOR_ASSIGN,
+								Rhs: []ast.Expr{&ast.CallExpr{Fun: uint64Ident, Args: []ast.Expr{b}}},
+							},
+							&ast.AssignStmt{
+								Lhs: []ast.Expr{hash},
+								Tok: token.MUL_ASSIGN,
+								Rhs: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "1099511628211"}},
+							},
+						},
+					},
+				},
+				Return(hash),
+			},
+		},
+	}
+}
+
+/*
+HashKeyFunc produces the function which formats a comparable value so that values which are == format alike.
+Floats are formatted by value plus zero, which turns -0.0 into 0.0, since the two are == but their Go syntax
+differs. Everything else is formatted as Go syntax, which is the same for values which are ==.
+
+	func cgo_hash_key(v reflect.Value) string {
+		switch v.Kind() {
+		case reflect.Struct:
+			key := ""
+			for i := 0; i < v.NumField(); i++ {
+				key += cgo_hash_key(v.Field(i)) + ";"
+			}
+			return key
+		case reflect.Array:
+			key := ""
+			for i := 0; i < v.Len(); i++ {
+				key += cgo_hash_key(v.Index(i)) + ";"
+			}
+			return key
+		case reflect.Float32, reflect.Float64:
+			return fmt.Sprint(v.Float() + 0)
+		case reflect.Complex64, reflect.Complex128:
+			return fmt.Sprint(v.Complex() + 0)
+		}
+		return fmt.Sprintf("%#v", v)
+	}
+*/
+func HashKeyFunc() ast.Decl {
+	v := NewIdent("v")
+	key := NewIdent("key")
+	i := NewIdent("i")
+	zero := &ast.BasicLit{Kind: token.INT, Value: "0"}
+	sprint := func(arg ast.Expr) ast.Expr {
+		return &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: NewIdent("fmt"), Sel: NewIdent("Sprint")},
+			Args: []ast.Expr{arg},
+		}
+	}
+	// joinKeys builds the key of a struct or array from the keys of its elements, which elem gets from v
+	joinKeys := func(length string, elem string) []ast.Stmt {
+		return []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{key},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: `""`}},
+			},
+			&ast.ForStmt{
+				Init: &ast.AssignStmt{Lhs: []ast.Expr{i}, Tok: token.DEFINE, Rhs: []ast.Expr{zero}},
+				Cond: &ast.BinaryExpr{X: i, Op: token.LSS, Y: methodCall(v, length)},
+				Post: &ast.IncDecStmt{X: i, Tok: token.INC},
+				Body: &ast.BlockStmt{
+					List: []ast.Stmt{
+						&ast.AssignStmt{
+							Lhs: []ast.Expr{key},
+							Tok: token.ADD_ASSIGN,
+							Rhs: []ast.Expr{&ast.BinaryExpr{
+								X:  &ast.CallExpr{Fun: NewIdent(HASH_KEY_FUNC_NAME), Args: []ast.Expr{methodCall(v, elem, i)}},
+								Op: token.ADD,
+								Y:  &ast.BasicLit{Kind: token.STRING, Value: `";"`},
+							}},
+						},
+					},
+				},
+			},
+			Return(key),
+		}
+	}
+
+	return &ast.FuncDecl{
+		Name: NewIdent(HASH_KEY_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{{Names: []*ast.Ident{v}, Type: reflectValue}},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: NewIdent("string")}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.SwitchStmt{
+					Tag: methodCall(v, "Kind"),
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.CaseClause{
+								List: []ast.Expr{reflectKind("Struct")},
+								Body: joinKeys("NumField", "Field"),
+							},
+							&ast.CaseClause{
+								List: []ast.Expr{reflectKind("Array")},
+								Body: joinKeys("Len", "Index"),
+							},
+							&ast.CaseClause{
+								List: []ast.Expr{reflectKind("Float32"), reflectKind("Float64")},
+								Body: []ast.Stmt{
+									Return(sprint(&ast.BinaryExpr{X: methodCall(v, "Float"), Op: token.ADD, Y: zero})),
+								},
+							},
+							&ast.CaseClause{
+								List: []ast.Expr{reflectKind("Complex64"), reflectKind("Complex128")},
+								Body: []ast.Stmt{
+									Return(sprint(&ast.BinaryExpr{X: methodCall(v, "Complex"), Op: token.ADD, Y: zero})),
+								},
+							},
+						},
+					},
+				},
+				Return(FormatSprintf("%#v", v)),
+			},
+		},
+	}
+}
@@ -0,0 +1,359 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+const (
+	DEEP_COPY_FUNC_NAME = "cgo_deep_copy"
+)
+
+// HasValueSemantics returns true for types which are copied on assignment, but which can also be referenced
+// in place as an element of a slice or a field of a struct. Structs and slices are the only such types which
+// Veil wraps.
+func HasValueSemantics(t types.Type) bool {
+	switch typ := t.(type) {
+	case *types.Slice:
+		return true
+	case *types.Named:
+		_, isStruct := typ.Underlying().(*types.Struct)
+		return isStruct && !ImplementsError(typ)
+	default:
+		return false
+	}
+}
+
+// CopyAst produces a function which copies the value behind self and returns a handle to the copy.
+// copyExpr receives the dereferenced value and returns the expression used to copy it.
+//
+//	func veil_pkg_Hello_copy(self unsafe.Pointer) unsafe.Pointer {
+//		c := *(*veil_pkg.Hello)(cgo_get_ref(cgo_get_uuid_from_ptr(self)))
+//		return C.CBytes(cgo_incref(unsafe.Pointer(&c)).Bytes())
+//	}
+func CopyAst(functionName string, goType ast.Expr, copyExpr func(ast.Expr) ast.Expr) ast.Decl {
+	selfIdent := NewIdent("self")
+	copyIdent := NewIdent("c")
+	value := DeRef(CastUnsafePtrOfTypeUuid(DeRef(goType), selfIdent))
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{copyIdent},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{copyExpr(value)},
+				},
+				Return(UuidToCBytes(IncrementRefCall(Ref(copyIdent)))),
+			},
+		},
+	}
+}
+
+// ValueCopy copies a value by assignment, which is a shallow Go value copy
+func ValueCopy(value ast.Expr) ast.Expr {
+	return value
+}
+
+// SliceCopy copies the elements of a slice into a new backing array: append([]T(nil), value...)
+func SliceCopy(goType ast.Expr) func(ast.Expr) ast.Expr {
+	return func(value ast.Expr) ast.Expr {
+		return &ast.CallExpr{
+			Fun: NewIdent("append"),
+			Args: []ast.Expr{
+				CastUnsafePtr(goType, NewIdent("nil")),
+				value,
+			},
+			Ellipsis: token.Pos(1),
+		}
+	}
+}
+
+// DeepCopy copies a value and everything it references:
+// cgo_deep_copy(reflect.ValueOf(value), map[interface{}]reflect.Value{}).Interface().(T)
+func DeepCopy(goType ast.Expr) func(ast.Expr) ast.Expr {
+	return func(value ast.Expr) ast.Expr {
+		return &ast.TypeAssertExpr{
+			X: methodCall(
+				&ast.CallExpr{
+					Fun: NewIdent(DEEP_COPY_FUNC_NAME),
+					Args: []ast.Expr{
+						reflectValueOf(value),
+						&ast.CompositeLit{
+							Type: &ast.MapType{
+								Key:   NewIdent("interface{}"),
+								Value: reflectValue,
+							},
+						},
+					},
+				},
+				"Interface"),
+			Type: goType,
+		}
+	}
+}
+
+/*
+DeepCopyFunc produces the runtime function used to deep copy values for the host language. Pointers, slices,
+arrays, maps and exported struct fields are copied recursively. Unexported fields, interfaces, channels and
+functions are copied by value. Pointers which have already been copied are reused, so cyclic values such as
+a tree whose nodes point back to their parent are copied with the same shape.
+
+	func cgo_deep_copy(src reflect.Value, copied map[interface{}]reflect.Value) reflect.Value {
+		dst := reflect.New(src.Type()).Elem()
+		switch src.Kind() {
+		case reflect.Ptr:
+			if !src.IsNil() {
+				if c, ok := copied[src.Interface()]; ok {
+					dst.Set(c)
+				} else {
+					dst.Set(reflect.New(src.Type().Elem()))
+					copied[src.Interface()] = dst
+					dst.Elem().Set(cgo_deep_copy(src.Elem(), copied))
+				}
+			}
+		case reflect.Slice:
+			if !src.IsNil() {
+				dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+				for i := 0; i < src.Len(); i++ {
+					dst.Index(i).Set(cgo_deep_copy(src.Index(i), copied))
+				}
+			}
+		case reflect.Array:
+			for i := 0; i < src.Len(); i++ {
+				dst.Index(i).Set(cgo_deep_copy(src.Index(i), copied))
+			}
+		case reflect.Map:
+			if !src.IsNil() {
+				dst.Set(reflect.MakeMap(src.Type()))
+				for _, key := range src.MapKeys() {
+					dst.SetMapIndex(key, cgo_deep_copy(src.MapIndex(key), copied))
+				}
+			}
+		case reflect.Struct:
+			dst.Set(src)
+			for i := 0; i < src.NumField(); i++ {
+				if dst.Field(i).CanSet() {
+					dst.Field(i).Set(cgo_deep_copy(src.Field(i), copied))
+				}
+			}
+		default:
+			dst.Set(src)
+		}
+		return dst
+	}
+*/
+func DeepCopyFunc() ast.Decl {
+	src := NewIdent("src")
+	dst := NewIdent("dst")
+	i := NewIdent("i")
+	key := NewIdent("key")
+	reflectKind := func(kind string) ast.Expr {
+		return &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent(kind)}
+	}
+	reflectFunc := func(name string, args ...ast.Expr) ast.Expr {
+		return &ast.CallExpr{Fun: reflectKind(name), Args: args}
+	}
+	copied := NewIdent("copied")
+	c := NewIdent("c")
+	ok := NewIdent("ok")
+	deepCopy := func(value ast.Expr) ast.Expr {
+		return &ast.CallExpr{Fun: NewIdent(DEEP_COPY_FUNC_NAME), Args: []ast.Expr{value, copied}}
+	}
+	copiedPtr := &ast.IndexExpr{X: copied, Index: methodCall(src, "Interface")}
+	set := func(target, value ast.Expr) ast.Stmt {
+		return &ast.ExprStmt{X: methodCall(target, "Set", value)}
+	}
+	ifNotNil := func(body ...ast.Stmt) ast.Stmt {
+		return &ast.IfStmt{
+			Cond: &ast.UnaryExpr{Op: token.NOT, X: methodCall(src, "IsNil")},
+			Body: &ast.BlockStmt{List: body},
+		}
+	}
+	forEachIndex := func(body ...ast.Stmt) ast.Stmt {
+		return &ast.ForStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{i},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "0"}},
+			},
+			Cond: &ast.BinaryExpr{X: i, Op: token.LSS, Y: methodCall(src, "Len")},
+			Post: &ast.IncDecStmt{X: i, Tok: token.INC},
+			Body: &ast.BlockStmt{List: body},
+		}
+	}
+	copyIndexes := forEachIndex(
+		set(methodCall(dst, "Index", i), deepCopy(methodCall(src, "Index", i))))
+
+	clauses := []ast.Stmt{
+		&ast.CaseClause{
+			List: []ast.Expr{reflectKind("Ptr")},
+			Body: []ast.Stmt{
+				ifNotNil(
+					&ast.IfStmt{
+						Init: &ast.AssignStmt{
+							Lhs: []ast.Expr{c, ok},
+							Tok: token.DEFINE,
+							Rhs: []ast.Expr{copiedPtr},
+						},
+						Cond: ok,
+						Body: &ast.BlockStmt{List: []ast.Stmt{set(dst, c)}},
+						Else: &ast.BlockStmt{
+							List: []ast.Stmt{
+								set(dst, reflectFunc("New", methodCall(methodCall(src, "Type"), "Elem"))),
+								&ast.AssignStmt{
+									Lhs: []ast.Expr{copiedPtr},
+									Tok: token.ASSIGN,
+									Rhs: []ast.Expr{dst},
+								},
+								set(methodCall(dst, "Elem"), deepCopy(methodCall(src, "Elem"))),
+							},
+						},
+					}),
+			},
+		},
+		&ast.CaseClause{
+			List: []ast.Expr{reflectKind("Slice")},
+			Body: []ast.Stmt{
+				ifNotNil(
+					set(dst, reflectFunc("MakeSlice", methodCall(src, "Type"), methodCall(src, "Len"), methodCall(src, "Len"))),
+					copyIndexes),
+			},
+		},
+		&ast.CaseClause{
+			List: []ast.Expr{reflectKind("Array")},
+			Body: []ast.Stmt{copyIndexes},
+		},
+		&ast.CaseClause{
+			List: []ast.Expr{reflectKind("Map")},
+			Body: []ast.Stmt{
+				ifNotNil(
+					set(dst, reflectFunc("MakeMap", methodCall(src, "Type"))),
+					&ast.RangeStmt{
+						Key:   NewIdent("_"),
+						Value: key,
+						Tok:   token.DEFINE,
+						X:     methodCall(src, "MapKeys"),
+						Body: &ast.BlockStmt{
+							List: []ast.Stmt{
+								&ast.ExprStmt{
+									X: methodCall(dst, "SetMapIndex", key, deepCopy(methodCall(src, "MapIndex", key))),
+								},
+							},
+						},
+					}),
+			},
+		},
+		&ast.CaseClause{
+			List: []ast.Expr{reflectKind("Struct")},
+			Body: []ast.Stmt{
+				set(dst, src),
+				&ast.ForStmt{
+					Init: &ast.AssignStmt{
+						Lhs: []ast.Expr{i},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "0"}},
+					},
+					Cond: &ast.BinaryExpr{X: i, Op: token.LSS, Y: methodCall(src, "NumField")},
+					Post: &ast.IncDecStmt{X: i, Tok: token.INC},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.IfStmt{
+								Cond: methodCall(methodCall(dst, "Field", i), "CanSet"),
+								Body: &ast.BlockStmt{
+									List: []ast.Stmt{
+										set(methodCall(dst, "Field", i), deepCopy(methodCall(src, "Field", i))),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		&ast.CaseClause{
+			Body: []ast.Stmt{set(dst, src)},
+		},
+	}
+
+	return &ast.FuncDecl{
+		Name: NewIdent(DEEP_COPY_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{src},
+						Type:  reflectValue,
+					},
+					{
+						Names: []*ast.Ident{copied},
+						Type: &ast.MapType{
+							Key:   NewIdent("interface{}"),
+							Value: reflectValue,
+						},
+					},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: reflectValue}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				// dst := reflect.New(src.Type()).Elem()
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{dst},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{methodCall(reflectFunc("New", methodCall(src, "Type")), "Elem")},
+				},
+				&ast.SwitchStmt{
+					Tag:  methodCall(src, "Kind"),
+					Body: &ast.BlockStmt{List: clauses},
+				},
+				Return(dst),
+			},
+		},
+	}
+}
+
+// RefAst produces a function which returns a live handle to a value held inside of self, such as a struct
+// field or a slice element, rather than a copy of the value
+func RefAst(functionName string, params *ast.FieldList, setup []ast.Stmt, target ast.Expr) ast.Decl {
+	body := append(setup, Return(UuidToCBytes(IncrementRefCall(Ref(target)))))
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: params,
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: body,
+		},
+	}
+}
+
+func methodCall(x ast.Expr, name string, args ...ast.Expr) *ast.CallExpr {
+	return &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   x,
+			Sel: NewIdent(name),
+		},
+		Args: args,
+	}
+}
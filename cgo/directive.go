@@ -0,0 +1,323 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"strings"
+
+	"github.com/devigned/veil/core"
+)
+
+const (
+	DIRECTIVE_PREFIX  = "//veil:"
+	DIRECTIVE_EXCLUDE = "exclude"
+	DIRECTIVE_NAME    = "name"
+	DIRECTIVE_OUT     = "out"
+)
+
+/*
+Directives are comments on the declarations of a package which say how they are bound:
+
+	//veil:exclude
+	func Debug() {}
+
+	//veil:name greet
+	func Hello(name string) string
+
+	//veil:out result
+	func Parse(s string, result *Point) error
+
+	type Shape struct {
+		Points []Point //veil:name vertices
+	}
+
+They are written on funcs, types, and the methods and fields of structs. Symbols are named like Hello, Shape,
+Shape.Area or Shape.Points, which is also how the Config of a package refers to them.
+*/
+
+// Directive says how a symbol is bound
+type Directive struct {
+	// Exclude leaves the symbol out of the bindings
+	Exclude bool
+	// Name is the name bindings give the symbol, rather than one derived from its Go name
+	Name string
+	// Out are the params of a func or method which are out-params. They are pointers to structs, which bindings
+	// create and return along with the results rather than taking them as arguments.
+	Out []string
+}
+
+// Config selects and names the symbols of a package which are bound, from the config file of the project and the
+// command line. It takes precedence over the //veil: directives in the source of the package.
+type Config struct {
+	// Include are patterns matched by path.Match of the funcs and types to bind. Everything is bound if empty.
+	Include []string
+	// Exclude are patterns of the symbols not to bind, including fields and methods such as Shape.Internal
+	Exclude []string
+	// Rename maps symbols to the names bindings give them
+	Rename map[string]string
+	// Out maps funcs and methods to their out-params
+	Out map[string][]string
+}
+
+// Directive returns the directive of symbol, merged with the Config of the package
+func (p Package) Directive(symbol string) Directive {
+	directive := Directive{}
+	if d, ok := p.directives[symbol]; ok {
+		directive = *d
+	}
+	if p.config == nil {
+		return directive
+	}
+
+	if name, ok := p.config.Rename[symbol]; ok {
+		directive.Name = name
+	}
+	if out, ok := p.config.Out[symbol]; ok {
+		directive.Out = out
+	}
+	if !strings.Contains(symbol, ".") && len(p.config.Include) > 0 && !matchAny(p.config.Include, symbol) {
+		directive.Exclude = true
+	}
+	if matchAny(p.config.Exclude, symbol) {
+		directive.Exclude = true
+	}
+	return directive
+}
+
+// IsExcluded returns true if symbol or the type it belongs to is excluded
+func (p Package) IsExcluded(symbol string) bool {
+	if parent := strings.Split(symbol, ".")[0]; parent != symbol && p.Directive(parent).Exclude {
+		return true
+	}
+	return p.Directive(symbol).Exclude
+}
+
+// Configure applies config to the package. Symbols in config which the package doesn't have, or out-params which
+// can't be created by bindings, are user errors.
+func (p *Package) Configure(config *Config) error {
+	for _, pattern := range append(append([]string{}, config.Include...), config.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return core.NewUserErrorF("Invalid symbol pattern %q: %v", pattern, err)
+		}
+	}
+	for symbol, name := range config.Rename {
+		if !p.hasSymbol(symbol) {
+			return core.NewUserErrorF("Can't rename %s to %s, since [%s] has no such symbol", symbol, name,
+				p.Path())
+		}
+	}
+	for symbol, out := range config.Out {
+		if err := p.checkOut(symbol, out); err != nil {
+			return err
+		}
+	}
+	p.config = config
+	return nil
+}
+
+func matchAny(patterns []string, symbol string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, symbol); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSymbol returns true if symbol names an exported func or type of the package, or a field or method of one
+func (p Package) hasSymbol(symbol string) bool {
+	parts := strings.SplitN(symbol, ".", 2)
+	obj := p.pkg.Scope().Lookup(parts[0])
+	if obj == nil || !obj.Exported() {
+		return false
+	}
+	if len(parts) == 1 {
+		return true
+	}
+	member, _, _ := types.LookupFieldOrMethod(obj.Type(), true, p.pkg, parts[1])
+	return member != nil && member.Exported()
+}
+
+// checkOut checks that the out-params of symbol are pointers to structs among the params of a func or method
+func (p Package) checkOut(symbol string, out []string) error {
+	parts := strings.SplitN(symbol, ".", 2)
+	obj := p.pkg.Scope().Lookup(parts[0])
+	if obj != nil && len(parts) == 2 {
+		if _, ok := obj.Type().Underlying().(*types.Struct); ok {
+			obj, _, _ = types.LookupFieldOrMethod(obj.Type(), true, p.pkg, parts[1])
+		} else {
+			obj = nil
+		}
+	}
+	fun, ok := obj.(*types.Func)
+	if !ok || !fun.Exported() {
+		return core.NewUserErrorF("Out-params can only be set on the funcs and struct methods of [%s], not %s",
+			p.Path(), symbol)
+	}
+	if p.IsConstructor(NewFunc(fun)) {
+		return core.NewUserErrorF("Out-params can't be set on %s, since constructors return the struct they build",
+			symbol)
+	}
+
+	params := fun.Type().(*types.Signature).Params()
+	for _, name := range out {
+		found := false
+		for i := 0; i < params.Len(); i++ {
+			param := params.At(i)
+			if param.Name() != name {
+				continue
+			}
+			found = true
+			ptr, ok := param.Type().(*types.Pointer)
+			if !ok || !isStruct(ptr.Elem()) {
+				return core.NewUserErrorF("Out-param %s of %s must be a pointer to a struct, not %s", name, symbol,
+					typeString(param.Type()))
+			}
+		}
+		if !found {
+			return core.NewUserErrorF("%s has no param %s to be an out-param", symbol, name)
+		}
+	}
+	return nil
+}
+
+func isStruct(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	_, ok = named.Underlying().(*types.Struct)
+	return ok
+}
+
+// parseDirectives reads the //veil: directives of the declarations in files
+func (p *Package) parseDirectives(fset *token.FileSet, files []*ast.File) error {
+	p.directives = map[string]*Directive{}
+	add := func(symbol string, groups ...*ast.CommentGroup) error {
+		for _, group := range groups {
+			if group == nil {
+				continue
+			}
+			for _, comment := range group.List {
+				if err := p.addDirective(fset, symbol, comment); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				symbol := decl.Name.Name
+				if decl.Recv != nil && len(decl.Recv.List) > 0 {
+					symbol = receiverName(decl.Recv.List[0].Type) + "." + symbol
+				}
+				if err := add(symbol, decl.Doc); err != nil {
+					return err
+				}
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					doc := typeSpec.Doc
+					if doc == nil && len(decl.Specs) == 1 {
+						doc = decl.Doc
+					}
+					if err := add(typeSpec.Name.Name, doc); err != nil {
+						return err
+					}
+
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range structType.Fields.List {
+						for _, name := range fieldNames(field) {
+							if err := add(typeSpec.Name.Name+"."+name, field.Doc, field.Comment); err != nil {
+								return err
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addDirective adds comment to the directive of symbol if it is a //veil: directive
+func (p *Package) addDirective(fset *token.FileSet, symbol string, comment *ast.Comment) error {
+	if !strings.HasPrefix(comment.Text, DIRECTIVE_PREFIX) {
+		return nil
+	}
+	args := strings.Fields(strings.TrimPrefix(comment.Text, DIRECTIVE_PREFIX))
+	if len(args) == 0 {
+		return core.NewUserErrorF("%s: empty directive", fset.Position(comment.Pos()))
+	}
+
+	directive, ok := p.directives[symbol]
+	if !ok {
+		directive = &Directive{}
+		p.directives[symbol] = directive
+	}
+	switch args[0] {
+	case DIRECTIVE_EXCLUDE:
+		directive.Exclude = true
+	case DIRECTIVE_NAME:
+		if len(args) != 2 {
+			return core.NewUserErrorF("%s: %s%s takes a single name", fset.Position(comment.Pos()),
+				DIRECTIVE_PREFIX, DIRECTIVE_NAME)
+		}
+		directive.Name = args[1]
+	case DIRECTIVE_OUT:
+		if len(args) < 2 {
+			return core.NewUserErrorF("%s: %s%s takes the names of params", fset.Position(comment.Pos()),
+				DIRECTIVE_PREFIX, DIRECTIVE_OUT)
+		}
+		if err := p.checkOut(symbol, args[1:]); err != nil {
+			return core.NewUserErrorF("%s: %v", fset.Position(comment.Pos()), err)
+		}
+		directive.Out = append(directive.Out, args[1:]...)
+	default:
+		return core.NewUserErrorF("%s: unknown directive %s%s", fset.Position(comment.Pos()), DIRECTIVE_PREFIX,
+			args[0])
+	}
+	return nil
+}
+
+// receiverName returns the name of the type of a method receiver such as *Shape
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// fieldNames returns the names of a struct field, which is the name of its type when it is embedded
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) > 0 {
+		names := make([]string, len(field.Names))
+		for i, name := range field.Names {
+			names[i] = name.Name
+		}
+		return names
+	}
+	if sel, ok := field.Type.(*ast.SelectorExpr); ok {
+		return []string{sel.Sel.Name}
+	}
+	return []string{receiverName(field.Type)}
+}
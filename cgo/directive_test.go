@@ -0,0 +1,76 @@
+package cgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectives(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type Point struct {
+	X, Y int
+	Label string //veil:name tag
+	// Secret isn't bound
+	//veil:exclude
+	Secret string
+}
+
+//veil:name Vertex
+type Node struct{ P Point }
+
+//veil:exclude
+func (n *Node) Debug() {}
+
+//veil:out result
+func Parse(s string, result *Point) error { return nil }
+
+func Hello(name string) string { return name }
+`)
+	assert.Equal(t, Directive{Name: "Vertex"}, pkg.Directive("Node"))
+	assert.Equal(t, Directive{Name: "tag"}, pkg.Directive("Point.Label"))
+	assert.True(t, pkg.IsExcluded("Point.Secret"))
+	assert.True(t, pkg.IsExcluded("Node.Debug"))
+	assert.Equal(t, []string{"result"}, pkg.Directive("Parse").Out)
+
+	err := pkg.Configure(&Config{
+		Include: []string{"P*", "Node"},
+		Exclude: []string{"Point.X"},
+		Rename:  map[string]string{"Node": "Tree", "Hello": "greet"},
+	})
+	if assert.NoError(t, err) {
+		assert.True(t, pkg.IsExcluded("Hello"), "Hello isn't included")
+		assert.False(t, pkg.IsExcluded("Parse"))
+		assert.True(t, pkg.IsExcluded("Point.X"))
+		assert.False(t, pkg.IsExcluded("Point.Y"), "include patterns only select funcs and types")
+		assert.Equal(t, "Tree", pkg.Directive("Node").Name, "the config takes precedence")
+	}
+
+	for config, msg := range map[*Config]string{
+		{Exclude: []string{"["}}:                         "Invalid symbol pattern",
+		{Rename: map[string]string{"Missing": "x"}}:      "no such symbol",
+		{Out: map[string][]string{"Hello": {"name"}}}:    "must be a pointer to a struct",
+		{Out: map[string][]string{"Parse": {"missing"}}}: "has no param missing",
+		{Out: map[string][]string{"Point.X": {"x"}}}:     "Out-params can only be set",
+	} {
+		if err := pkg.Configure(config); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), msg)
+		}
+	}
+}
+
+func TestDirectiveErrors(t *testing.T) {
+	for src, msg := range map[string]string{
+		"//veil:rename x\nfunc Hello() {}":                                               "unknown directive //veil:rename",
+		"//veil:name\nfunc Hello() {}":                                                   "takes a single name",
+		"//veil:out s\nfunc Hello(s string) {}":                                          "must be a pointer to a struct",
+		"type Point struct{}\n//veil:out p\nfunc NewPoint(p *Point) *Point { return p }": "constructors",
+	} {
+		_, err := NewPackageFromSources("github.com/foo/recursive",
+			map[string]string{"src.go": "package recursive\n" + src})
+		if assert.Error(t, err, src) {
+			assert.Contains(t, err.Error(), msg)
+			assert.Contains(t, err.Error(), "src.go:", "errors point at the directive")
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/doc"
+	"strings"
+)
+
+const DEPRECATED_PREFIX = "Deprecated: "
+
+// Doc returns the doc comment of symbol, such as Hello, Shape, Shape.Points or Shape.Area, or "" if it has none.
+// Directives such as //veil:exclude aren't part of it.
+func (p Package) Doc(symbol string) string {
+	return p.docs[symbol]
+}
+
+// Deprecation returns the notice of a deprecated symbol from its doc comment, which is the paragraph starting with
+// "Deprecated: ", or "" if the symbol isn't deprecated
+func Deprecation(docText string) string {
+	for _, paragraph := range strings.Split(docText, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if strings.HasPrefix(paragraph, DEPRECATED_PREFIX) {
+			return strings.Join(strings.Fields(strings.TrimPrefix(paragraph, DEPRECATED_PREFIX)), " ")
+		}
+	}
+	return ""
+}
+
+// indexDocs keys the doc comments of the funcs, types, methods and fields of the package by symbol. go/doc
+// associates funcs returning a type, such as constructors, with the type, and doesn't read the docs of fields or
+// interface methods, which are read from the AST.
+func (p *Package) indexDocs() {
+	p.docs = map[string]string{}
+	add := func(symbol, text string) {
+		if text = strings.TrimSpace(text); text != "" {
+			p.docs[symbol] = text
+		}
+	}
+
+	for _, f := range p.doc.Funcs {
+		add(f.Name, f.Doc)
+	}
+	for _, t := range p.doc.Types {
+		add(t.Name, t.Doc)
+		for _, f := range t.Funcs {
+			add(f.Name, f.Doc)
+		}
+		for _, meth := range t.Methods {
+			add(t.Name+"."+meth.Name, meth.Doc)
+		}
+		p.indexMemberDocs(t)
+	}
+}
+
+// indexMemberDocs indexes the doc comments of the fields of a struct or the methods of an interface
+func (p *Package) indexMemberDocs(t *doc.Type) {
+	for _, spec := range t.Decl.Specs {
+		typeSpec, ok := spec.(*ast.TypeSpec)
+		if !ok || typeSpec.Name.Name != t.Name {
+			continue
+		}
+		var members *ast.FieldList
+		switch typ := typeSpec.Type.(type) {
+		case *ast.StructType:
+			members = typ.Fields
+		case *ast.InterfaceType:
+			members = typ.Methods
+		default:
+			continue
+		}
+		for _, member := range members.List {
+			text := member.Doc.Text()
+			if text == "" {
+				// a trailing comment documents the member when it has no doc comment
+				text = member.Comment.Text()
+			}
+			for _, name := range fieldNames(member) {
+				if text = strings.TrimSpace(text); text != "" {
+					p.docs[t.Name+"."+name] = text
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocs(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+// Node is a node of a tree
+//
+//veil:name Vertex
+type Node struct {
+	// Value is the value of the node
+	Value string
+	Next  *Node // Next is the sibling of the node
+}
+
+// NewNode returns a node of value
+func NewNode(value string) *Node { return nil }
+
+// Walk visits the node
+//
+// Deprecated: use Visit
+// instead.
+func (n *Node) Walk() {}
+
+// Visitor visits nodes
+type Visitor interface {
+	// Visit visits n
+	Visit(n *Node)
+}
+
+func Undocumented() {}
+`)
+	assert.Equal(t, "Node is a node of a tree", pkg.Doc("Node"), "directives aren't part of the doc")
+	assert.Equal(t, "Value is the value of the node", pkg.Doc("Node.Value"))
+	assert.Equal(t, "Next is the sibling of the node", pkg.Doc("Node.Next"))
+	assert.Equal(t, "NewNode returns a node of value", pkg.Doc("NewNode"))
+	assert.Equal(t, "Visit visits n", pkg.Doc("Visitor.Visit"))
+	assert.Empty(t, pkg.Doc("Undocumented"))
+
+	assert.Equal(t, "use Visit instead.", Deprecation(pkg.Doc("Node.Walk")))
+	assert.Empty(t, Deprecation(pkg.Doc("Node")))
+}
@@ -1,6 +1,7 @@
 package cgo
 
 import (
+	"fmt"
 	"go/ast"
 	"go/types"
 	"strings"
@@ -31,16 +32,38 @@ func (f Func) Signature() *types.Signature {
 }
 
 func (f Func) IsExportable() bool {
-	if !f.Exported() {
-		return false
+	return f.Exported() && f.SkipReason() == ""
+}
+
+// SkipReason returns why the func can't be bound, such as "param b: arrays such as [4]byte are not supported",
+// or "" if it can
+func (f Func) SkipReason() string {
+	sig := f.Signature()
+	if sig.TypeParams().Len() > 0 {
+		return "generic funcs are not supported"
 	}
 
-	for _, v := range allVars(&f) {
-		if !ShouldGenerate(v) {
-			return false
+	options := f.Options()
+	for i, v := range allVars(&f) {
+		if options != nil && i == sig.Params().Len()-1 {
+			// functional options are built from the params of their helpers
+			continue
+		}
+		if !ShouldGenerate(v) && !(IsOpaque(v.Type()) && f.AcceptsOpaque()) {
+			return fmt.Sprintf("%s: opaque values such as %s can't be passed by interfaces implemented in the "+
+				"host language", varLabel(sig, i), typeString(v.Type()))
+		}
+		if reason := UnsupportedReason(v.Type()); reason != "" {
+			return varLabel(sig, i) + ": " + reason
 		}
 	}
-	return true
+	return ""
+}
+
+// AcceptsOpaque returns true if the func can pass opaque values as handles. Interface methods can't, since
+// implementing them requires naming every type in their signature.
+func (f Func) AcceptsOpaque() bool {
+	return f.BoundRecv == nil || !isInterface(f.BoundRecv.Named)
 }
 
 // Underlying returns the string representation of the type (types.Type)
@@ -60,9 +83,22 @@ func MainFunc() *ast.FuncDecl {
 
 // ToAst returns the go/ast representation of the CGo wrapper of the Func type
 func (f Func) ToAst() []ast.Decl {
-	return []ast.Decl{
+	decls := []ast.Decl{
 		FuncAst(&f),
 	}
+	if f.Options() == nil && HasOpaqueParams(f.Signature()) {
+		decls = append(decls, opaqueParamsInit(&f))
+	}
+	return decls
+}
+
+// OpaqueParamKey returns the key under which the type of the param at index i is registered if it is opaque, or
+// "" if it isn't. Bindings pass the key to cgo_opaque_check before handing an opaque value to the wrapper.
+func (f Func) OpaqueParamKey(i int) string {
+	if f.Options() != nil || !IsOpaque(f.Signature().Params().At(i).Type()) {
+		return ""
+	}
+	return OpaqueParamKey(f.CName(), i)
 }
 
 func (f Func) ExportName() string {
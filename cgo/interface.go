@@ -42,17 +42,30 @@ func (iface Interface) ExportedMethods() []*Func {
 }
 
 func (iface Interface) IsExportable() bool {
+	// an interface is exportable unless a method exposes an argument that is not exportable
+	return !hasOpaqueMethods(iface.Interface(), map[*types.Named]bool{iface.named.Named: true})
+}
+
+// SkipReason returns why the interface can't be implemented in the host language, such as "method Done: result
+// 0: opaque values such as chan bool can't be passed by interfaces implemented in the host language", or "" if it
+// can
+func (iface Interface) SkipReason() string {
 	underlyingIface := iface.Interface()
-	numMethods := underlyingIface.NumMethods()
-	for i := 0; i < numMethods; i++ {
+	for i := 0; i < underlyingIface.NumMethods(); i++ {
 		meth := underlyingIface.Method(i)
-		fun := NewBoundFunc(meth, iface.named)
-		if !fun.IsExportable() && fun.Exported() {
-			// func exposes an argument that is not exportable
-			return false
+		if !meth.Exported() {
+			continue
+		}
+		if reason := NewBoundFunc(meth, iface.named).SkipReason(); reason != "" {
+			return fmt.Sprintf("method %s: %s", meth.Name(), reason)
 		}
 	}
-	return true
+	return ""
+}
+
+// Named returns the named type of the interface
+func (iface Interface) Named() *types.Named {
+	return iface.named.Named
 }
 
 func (iface Interface) Interface() *types.Interface {
@@ -63,11 +76,21 @@ func (iface Interface) Interface() *types.Interface {
 func (iface Interface) ToAst() []ast.Decl {
 	decls := []ast.Decl{
 		iface.HelperStructAst(),
+		iface.HelperAssertionAst(),
 		iface.NewAst(),
 		iface.StringAst(),
 		iface.HelperCallbackRegistrationAst(),
+		iface.PinAst(),
+		iface.PyHandleAst(),
 	}
 	decls = append(decls, iface.MethodAsts()...)
+	for _, meth := range iface.ExportedMethods() {
+		// Go backed values are called through the interface rather than through host language callbacks
+		decls = append(decls, meth.ToAst()...)
+	}
+	if iface.IsStream() {
+		decls = append(decls, iface.StreamAsts()...)
+	}
 	return decls
 }
 
@@ -87,6 +110,16 @@ func (iface Interface) CName() string {
 	return iface.named.CName()
 }
 
+// Path returns the package path of the interface so it can be imported by the CGo wrapper
+func (iface Interface) Path() string {
+	return iface.named.Path()
+}
+
+// Alias returns the import alias for the package of the interface
+func (iface Interface) Alias() string {
+	return iface.named.Alias()
+}
+
 func (iface Interface) CDefs() (retTypes []string, funcPtrs []string, calls []string) {
 	retTypes = []string{}
 	funcPtrs = []string{}
@@ -201,54 +234,227 @@ func argNames(prefix string, length int) []string {
 	return results
 }
 
-// NewAst produces the []ast.Decl to construct a named type and increment it's reference count
+// NewAst produces the []ast.Decl to construct a helper for a host language implementation of the interface.
+// Every interface handle refers to a value of the interface type, whether it holds a helper or a Go value.
+//
+//	func veil_io_Reader_new(handle unsafe.Pointer) unsafe.Pointer {
+//		var o veil_io.Reader
+//		o = &veil_io_Reader_helper{handle: handle, callbacks: map[string]unsafe.Pointer{}}
+//		return C.CBytes(cgo_incref(unsafe.Pointer(&o)).Bytes())
+//	}
 func (iface Interface) NewAst() ast.Decl {
 	functionName := iface.named.NewMethodName()
 	handleIdent := NewIdent("handle")
 	structInitialization := func(localVar *ast.Ident) []ast.Stmt {
 		return []ast.Stmt{
 			&ast.AssignStmt{
-				Lhs: []ast.Expr{
-					&ast.SelectorExpr{
-						X:   localVar,
-						Sel: handleIdent,
-					},
-				},
+				Lhs: []ast.Expr{localVar},
 				Tok: token.ASSIGN,
-				Rhs: []ast.Expr{
-					handleIdent,
-				},
+				Rhs: []ast.Expr{iface.newHelper(handleIdent, &ast.CompositeLit{Type: strCFuncPtrMapType})},
+			},
+		}
+	}
+
+	params := []*ast.Field{
+		{
+			Names: []*ast.Ident{handleIdent},
+			Type:  unsafePointer,
+		},
+	}
+	return NewAstWithInitialization(functionName, TypeExpression(iface.named.Named), params, structInitialization)
+}
+
+// StringAst produces the []ast.Decl to provide a string representation of the named type
+func (iface Interface) StringAst() ast.Decl {
+	functionName := iface.named.ToStringMethodName()
+	return StringAst(functionName, TypeExpression(iface.named.Named))
+}
+
+/*
+PinAst produces a function which wraps the helper of a host language implementation in a new helper with a
+finalizer. The host language pins its implementation for each pinned helper and is called to release the pin
+once Go no longer references the helper, so implementations stored in Go structs and slices stay alive.
+
+	func veil_io_Reader_pin(self unsafe.Pointer) unsafe.Pointer {
+		helper := (*(*veil_io.Reader)(cgo_get_ref(cgo_get_uuid_from_ptr(self)))).(*veil_io_Reader_helper)
+		var o veil_io.Reader
+		pinned := &veil_io_Reader_helper{handle: helper.handle, callbacks: helper.callbacks}
+		runtime.SetFinalizer(pinned, func(h *veil_io_Reader_helper) {
+			cgo_release(h.handle)
+		})
+		o = pinned
+		return C.CBytes(cgo_incref(unsafe.Pointer(&o)).Bytes())
+	}
+*/
+func (iface Interface) PinAst() ast.Decl {
+	functionName := iface.PinMethodName()
+	helperIdent := NewIdent("helper")
+	pinnedIdent := NewIdent("pinned")
+	hIdent := NewIdent("h")
+
+	inits := func(localVar *ast.Ident) []ast.Stmt {
+		return []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{helperIdent},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{iface.helperAssertion(NewIdent("self"))},
 			},
 			&ast.AssignStmt{
-				Lhs: []ast.Expr{
-					&ast.SelectorExpr{
-						X:   localVar,
-						Sel: NewIdent("callbacks"),
+				Lhs: []ast.Expr{pinnedIdent},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{iface.newHelper(
+					&ast.SelectorExpr{X: helperIdent, Sel: NewIdent("handle")},
+					&ast.SelectorExpr{X: helperIdent, Sel: NewIdent("callbacks")})},
+			},
+			&ast.ExprStmt{
+				X: &ast.CallExpr{
+					Fun: &ast.SelectorExpr{X: NewIdent("runtime"), Sel: NewIdent("SetFinalizer")},
+					Args: []ast.Expr{
+						pinnedIdent,
+						&ast.FuncLit{
+							Type: &ast.FuncType{
+								Params: &ast.FieldList{
+									List: []*ast.Field{
+										{
+											Names: []*ast.Ident{hIdent},
+											Type:  DeRef(iface.helperStructName()),
+										},
+									},
+								},
+							},
+							Body: &ast.BlockStmt{
+								List: []ast.Stmt{
+									&ast.ExprStmt{
+										X: &ast.CallExpr{
+											Fun:  NewIdent(RELEASE_FUNC_NAME),
+											Args: []ast.Expr{&ast.SelectorExpr{X: hIdent, Sel: NewIdent("handle")}},
+										},
+									},
+								},
+							},
+						},
 					},
 				},
+			},
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{localVar},
 				Tok: token.ASSIGN,
-				Rhs: []ast.Expr{
-					&ast.CompositeLit{
-						Type: strCFuncPtrMapType,
-					},
-				},
+				Rhs: []ast.Expr{pinnedIdent},
 			},
 		}
 	}
 
 	params := []*ast.Field{
 		{
-			Names: []*ast.Ident{handleIdent},
+			Names: []*ast.Ident{NewIdent("self")},
 			Type:  unsafePointer,
 		},
 	}
-	return NewAstWithInitialization(functionName, iface.helperStructName(), params, structInitialization)
+	return NewAstWithInitialization(functionName, TypeExpression(iface.named.Named), params, inits)
 }
 
-// StringAst produces the []ast.Decl to provide a string representation of the named type
-func (iface Interface) StringAst() ast.Decl {
-	functionName := iface.named.ToStringMethodName()
-	return StringAst(functionName, iface.helperStructName())
+// PyHandleAst produces a function which returns the host language handle of an implementation of the
+// interface, or nil if the interface holds a Go value, so the host language can return its own object
+//
+//	func veil_io_Reader_py_handle(self unsafe.Pointer) unsafe.Pointer {
+//		if helper, ok := (*(*veil_io.Reader)(cgo_get_ref(cgo_get_uuid_from_ptr(self)))).(*veil_io_Reader_helper); ok {
+//			return helper.handle
+//		}
+//		return nil
+//	}
+func (iface Interface) PyHandleAst() ast.Decl {
+	functionName := iface.PyHandleMethodName()
+	helperIdent := NewIdent("helper")
+	okIdent := NewIdent("ok")
+	assertion := iface.helperAssertion(NewIdent("self"))
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.IfStmt{
+					Init: &ast.AssignStmt{
+						Lhs: []ast.Expr{helperIdent, okIdent},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{assertion},
+					},
+					Cond: okIdent,
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							Return(&ast.SelectorExpr{X: helperIdent, Sel: NewIdent("handle")}),
+						},
+					},
+				},
+				Return(NewIdent("nil")),
+			},
+		},
+	}
+}
+
+// helperAssertion asserts the interface value behind a handle holds a helper:
+// (*(*veil_io.Reader)(cgo_get_ref(cgo_get_uuid_from_ptr(self)))).(*veil_io_Reader_helper)
+func (iface Interface) helperAssertion(self ast.Expr) ast.Expr {
+	return &ast.TypeAssertExpr{
+		X:    &ast.ParenExpr{X: DeRef(CastUnsafePtrOfTypeUuid(DeRef(TypeExpression(iface.named.Named)), self))},
+		Type: DeRef(iface.helperStructName()),
+	}
+}
+
+// newHelper allocates a helper: &veil_io_Reader_helper{handle: handle, callbacks: callbacks}
+func (iface Interface) newHelper(handle, callbacks ast.Expr) ast.Expr {
+	return Ref(&ast.CompositeLit{
+		Type: iface.helperStructName(),
+		Elts: []ast.Expr{
+			&ast.KeyValueExpr{Key: NewIdent("handle"), Value: handle},
+			&ast.KeyValueExpr{Key: NewIdent("callbacks"), Value: callbacks},
+		},
+	})
+}
+
+// PinMethodName returns the name of the function pinning host language implementations of the interface
+func (iface Interface) PinMethodName() string {
+	return iface.named.CName() + "_pin"
+}
+
+// PyHandleMethodName returns the name of the function returning the host language object behind an interface
+func (iface Interface) PyHandleMethodName() string {
+	return iface.named.CName() + "_py_handle"
+}
+
+// RegisterCallbackMethodName returns the name of the function registering the callbacks of host language
+// implementations of the interface
+func (iface Interface) RegisterCallbackMethodName() string {
+	return iface.named.CName() + "_register_callback"
+}
+
+// Symbols returns the names of the functions wrapping the interface, other than its methods, keyed by what
+// they do
+func (iface Interface) Symbols() map[string]string {
+	symbols := map[string]string{
+		"new":               iface.named.NewMethodName(),
+		"str":               iface.named.ToStringMethodName(),
+		"pin":               iface.PinMethodName(),
+		"py_handle":         iface.PyHandleMethodName(),
+		"register_callback": iface.RegisterCallbackMethodName(),
+	}
+	if iface.IsStream() {
+		symbols["from_stream"] = iface.FromStreamMethodName()
+		for _, name := range []string{STREAM_READ_METHOD_NAME, STREAM_WRITE_METHOD_NAME} {
+			if iface.HasStreamMethod(name) {
+				symbols["stream_"+strings.ToLower(name)] = iface.StreamMethodName(name)
+			}
+		}
+	}
+	return symbols
 }
 
 // CTypeName returns the selector expression for the Named aliased package and type
@@ -590,7 +796,7 @@ func buildResultNilCheckAndCast(t types.Type, result *ast.Field, resIdxIdent *as
 func isTypeNilable(t types.Type) bool {
 	nilable := func(typ types.Type) bool {
 		switch typ.(type) {
-		case *types.Pointer, *types.Interface:
+		case *types.Pointer, *types.Interface, *types.Slice, *types.Map, *types.Chan, *types.Signature:
 			return true
 		default:
 			return false
@@ -640,20 +846,39 @@ func (iface Interface) HelperStructAst() ast.Decl {
 	}
 }
 
+// HelperAssertionAst produces a compile time assertion that the helper struct implements the interface,
+// which also ensures the interface's package import is used by the CGo wrapper
+//
+//	var _ veil_io.Reader = veil_io_Reader_helper{}
+func (iface Interface) HelperAssertionAst() ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names: []*ast.Ident{NewIdent("_")},
+				Type:  TypeExpression(iface.named.Named),
+				Values: []ast.Expr{
+					&ast.CompositeLit{
+						Type: iface.helperStructName(),
+					},
+				},
+			},
+		},
+	}
+}
+
 func (iface Interface) HelperCallbackRegistrationAst() ast.Decl {
-	funcName := iface.named.CName() + "_register_callback"
+	funcName := iface.RegisterCallbackMethodName()
 	selfIdent := NewIdent("self")
 	helperIdent := NewIdent("helper")
 	methodNameIdent := NewIdent("methodName")
 	funcPtrIdent := NewIdent("funcPtr")
 	strIdent := NewIdent("strMethodName")
 
-	castExpression := CastUnsafePtrOfTypeUuid(DeRef(iface.helperStructName()), selfIdent)
-
 	selfCastAssign := &ast.AssignStmt{
 		Lhs: []ast.Expr{helperIdent},
 		Tok: token.DEFINE,
-		Rhs: []ast.Expr{castExpression},
+		Rhs: []ast.Expr{iface.helperAssertion(selfIdent)},
 	}
 
 	//func veil_reader_helper_register_callback(self unsafe.Pointer, methodName *C.char, cfn unsafe.Pointer) {
@@ -682,7 +907,7 @@ func (iface Interface) HelperCallbackRegistrationAst() ast.Decl {
 		},
 		Body: &ast.BlockStmt{
 			List: []ast.Stmt{
-				// helper := (*readerHelper)(cgo_get_ref(cgo_get_uuid_from_ptr(self)))
+				// helper := (*(*veil_io.Reader)(cgo_get_ref(cgo_get_uuid_from_ptr(self)))).(*veil_io_Reader_helper)
 				selfCastAssign,
 				// strMethodName := C.GoString(methodName)
 				&ast.AssignStmt{
@@ -0,0 +1,184 @@
+package cgo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+const (
+	JSON_PKG_PATH = "encoding/json"
+)
+
+/*
+Structs are encoded and decoded with encoding/json, so host languages can convert a value to plain data in a
+single call which respects the json tags of its fields.
+*/
+
+// ToJsonMethodName returns the name of the function encoding the struct behind a handle as JSON
+func (s Struct) ToJsonMethodName() string {
+	return s.CName() + "_to_json"
+}
+
+// FromJsonMethodName returns the name of the function decoding JSON into a new struct
+func (s Struct) FromJsonMethodName() string {
+	return s.CName() + "_from_json"
+}
+
+// JsonAsts produces the functions encoding and decoding the struct as JSON
+func (s Struct) JsonAsts() []ast.Decl {
+	return []ast.Decl{s.ToJsonAst(), s.FromJsonAst()}
+}
+
+// UndecodableReason returns why the JSON encoding of the struct can't be decoded back into the value it encodes,
+// such as "field Referee: interfaces such as helloworld.Scorer can't be decoded", or "" if it can. Fields tagged
+// json:"-" are left out on purpose, so they don't count.
+func (s Struct) UndecodableReason() string {
+	return undecodableReason(s.Named.Named, map[*types.Named]bool{})
+}
+
+// undecodableReason tracks the named types being checked, so recursive types such as trees terminate
+func undecodableReason(t types.Type, visiting map[*types.Named]bool) string {
+	switch typ := t.(type) {
+	case *types.Named:
+		if visiting[typ] || hasMethod(types.NewPointer(typ), "UnmarshalJSON") {
+			return ""
+		}
+		visiting[typ] = true
+		if _, ok := typ.Underlying().(*types.Interface); ok {
+			return fmt.Sprintf("interfaces such as %s can't be decoded", typeString(typ))
+		}
+		return undecodableReason(typ.Underlying(), visiting)
+	case *types.Basic:
+		if typ.Info()&types.IsComplex != 0 || typ.Kind() == types.UnsafePointer {
+			return fmt.Sprintf("values such as %s can't be encoded", typeString(typ))
+		}
+	case *types.Pointer:
+		return undecodableReason(typ.Elem(), visiting)
+	case *types.Slice:
+		return undecodableReason(typ.Elem(), visiting)
+	case *types.Array:
+		return undecodableReason(typ.Elem(), visiting)
+	case *types.Map:
+		key, ok := typ.Key().Underlying().(*types.Basic)
+		textKey := hasMethod(types.NewPointer(typ.Key()), "UnmarshalText")
+		if (!ok || key.Info()&(types.IsString|types.IsInteger) == 0) && !textKey {
+			return fmt.Sprintf("maps with keys such as %s can't be decoded", typeString(typ.Key()))
+		}
+		return undecodableReason(typ.Elem(), visiting)
+	case *types.Struct:
+		for i := 0; i < typ.NumFields(); i++ {
+			field := typ.Field(i)
+			if reflect.StructTag(typ.Tag(i)).Get("json") == "-" {
+				continue
+			}
+			if !field.Exported() && !field.Embedded() {
+				return fmt.Sprintf("field %s: unexported fields aren't encoded", field.Name())
+			}
+			if reason := undecodableReason(field.Type(), visiting); reason != "" {
+				return fmt.Sprintf("field %s: %s", field.Name(), reason)
+			}
+		}
+	case *types.Interface:
+		return fmt.Sprintf("interfaces such as %s can't be decoded", typeString(typ))
+	case *types.Chan, *types.Signature:
+		return fmt.Sprintf("values such as %s can't be encoded", typeString(typ))
+	}
+	return ""
+}
+
+// hasMethod returns true if the method set of t has the method named name
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+func errorType() types.Type {
+	return types.Universe.Lookup("error").Type()
+}
+
+func jsonCall(name string, args ...ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: NewIdent("json"), Sel: NewIdent(name)},
+		Args: args,
+	}
+}
+
+// ToJsonAst produces a function which encodes the struct behind a handle as JSON
+//
+//	func veil_pkg_World_to_json(self unsafe.Pointer) (*C.char, unsafe.Pointer) {
+//		data, err := json.Marshal((*pkg.World)(cgo_get_ref(cgo_get_uuid_from_ptr(self))))
+//		return C.CString(string(data)), cgo_iface_handle(unsafe.Pointer(&err), err)
+//	}
+func (s Struct) ToJsonAst() ast.Decl {
+	functionName := s.ToJsonMethodName()
+	data, err := NewIdent("data"), NewIdent("err")
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: charStarType}, {Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{data, err},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{jsonCall("Marshal", CastUnsafePtrOfTypeUuid(DeRef(s.CTypeName()), NewIdent("self")))},
+				},
+				Return(
+					ToCString(&ast.CallExpr{Fun: NewIdent("string"), Args: []ast.Expr{data}}),
+					CastOut(errorType(), err)),
+			},
+		},
+	}
+}
+
+// FromJsonAst produces a function which decodes JSON into a new struct
+//
+//	func veil_pkg_World_from_json(data *C.char) (unsafe.Pointer, unsafe.Pointer) {
+//		var o pkg.World
+//		err := json.Unmarshal([]byte(C.GoString(data)), &o)
+//		return C.CBytes(cgo_incref(unsafe.Pointer(&o)).Bytes()), cgo_iface_handle(unsafe.Pointer(&err), err)
+//	}
+func (s Struct) FromJsonAst() ast.Decl {
+	functionName := s.FromJsonMethodName()
+	data, err, o := NewIdent("data"), NewIdent("err"), NewIdent("o")
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{{Names: []*ast.Ident{data}, Type: charStarType}},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: unsafePointer}, {Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				DeclareVar(o, s.CTypeName()),
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{err},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{jsonCall("Unmarshal",
+						&ast.CallExpr{Fun: &ast.ArrayType{Elt: NewIdent("byte")}, Args: []ast.Expr{ToGoString(data)}},
+						Ref(o))},
+				},
+				Return(UuidToCBytes(IncrementRefCall(Ref(o))), CastOut(errorType(), err)),
+			},
+		},
+	}
+}
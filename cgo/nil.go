@@ -0,0 +1,351 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Handle produces the cgo_handle function, which returns a C UUID handle for a pointer or nil if the
+// value being handed to the host language is nil
+//
+//	func cgo_handle(ptr unsafe.Pointer, isNil bool) unsafe.Pointer {
+//		if isNil {
+//			return nil
+//		}
+//		return C.CBytes(cgo_incref(ptr).Bytes())
+//	}
+func Handle() ast.Decl {
+	ptr := NewIdent("ptr")
+	isNil := NewIdent("isNil")
+
+	return &ast.FuncDecl{
+		Name: NewIdent(HANDLE_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{ptr},
+						Type:  unsafePointer,
+					},
+					{
+						Names: []*ast.Ident{isNil},
+						Type:  NewIdent("bool"),
+					},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.IfStmt{
+					Cond: isNil,
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{Return(NewIdent("nil"))},
+					},
+				},
+				Return(UuidToCBytes(&ast.CallExpr{
+					Fun:  NewIdent(INCREMENT_REF_FUNC_NAME),
+					Args: []ast.Expr{ptr},
+				})),
+			},
+		},
+	}
+}
+
+// IfaceHandle produces the cgo_iface_handle function, which returns a C UUID handle for a pointer to an
+// interface value. A nil interface produces a nil handle. An interface holding a typed nil, such as a nil
+// pointer, map, slice or func, is tracked so the host language can report it rather than calling methods on a
+// nil receiver.
+//
+//	func cgo_iface_handle(ptr unsafe.Pointer, value interface{}) unsafe.Pointer {
+//		if value == nil {
+//			return nil
+//		}
+//		uid := cgo_incref(ptr)
+//		switch v := reflect.ValueOf(value); v.Kind() {
+//		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+//			if v.IsNil() {
+//				refs.Lock()
+//				refs.typedNils[uid] = fmt.Sprintf("%T", value)
+//				refs.Unlock()
+//			}
+//		}
+//		return C.CBytes(uid.Bytes())
+//	}
+func IfaceHandle() ast.Decl {
+	ptr := NewIdent("ptr")
+	value := NewIdent("value")
+	uid := NewIdent("uid")
+	v := NewIdent("v")
+	refsVar := NewIdent(REFS_VAR_NAME)
+
+	return &ast.FuncDecl{
+		Name: NewIdent(IFACE_HANDLE_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{ptr},
+						Type:  unsafePointer,
+					},
+					{
+						Names: []*ast.Ident{value},
+						Type:  NewIdent("interface{}"),
+					},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.IfStmt{
+					Cond: IsNilExpr(value),
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{Return(NewIdent("nil"))},
+					},
+				},
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{uid},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{
+						&ast.CallExpr{
+							Fun:  NewIdent(INCREMENT_REF_FUNC_NAME),
+							Args: []ast.Expr{ptr},
+						},
+					},
+				},
+				&ast.SwitchStmt{
+					Init: &ast.AssignStmt{
+						Lhs: []ast.Expr{v},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{reflectValueOf(value)},
+					},
+					Tag: &ast.CallExpr{Fun: &ast.SelectorExpr{X: v, Sel: NewIdent("Kind")}},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.CaseClause{
+								List: []ast.Expr{
+									reflectKind("Ptr"),
+									reflectKind("Map"),
+									reflectKind("Slice"),
+									reflectKind("Func"),
+									reflectKind("Chan"),
+									reflectKind("Interface"),
+								},
+								Body: []ast.Stmt{
+									&ast.IfStmt{
+										Cond: &ast.CallExpr{Fun: &ast.SelectorExpr{X: v, Sel: NewIdent("IsNil")}},
+										Body: &ast.BlockStmt{
+											List: []ast.Stmt{
+												&ast.ExprStmt{
+													X: &ast.CallExpr{
+														Fun: &ast.SelectorExpr{X: refsVar, Sel: NewIdent("Lock")},
+													},
+												},
+												&ast.AssignStmt{
+													Lhs: []ast.Expr{
+														&ast.IndexExpr{
+															X: &ast.SelectorExpr{
+																X:   refsVar,
+																Sel: NewIdent(TYPED_NILS_FIELD_NAME),
+															},
+															Index: uid,
+														},
+													},
+													Tok: token.ASSIGN,
+													Rhs: []ast.Expr{FormatSprintf("%T", value)},
+												},
+												&ast.ExprStmt{
+													X: &ast.CallExpr{
+														Fun: &ast.SelectorExpr{X: refsVar, Sel: NewIdent("Unlock")},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Return(UuidToCBytes(uid)),
+			},
+		},
+	}
+}
+
+// TypedNil produces the exported cgo_typed_nil function, which returns the Go type name of a typed nil
+// held by the interface behind a handle, or nil if the handle does not refer to one
+//
+//	func cgo_typed_nil(self unsafe.Pointer) *C.char {
+//		if self == nil {
+//			return nil
+//		}
+//		refs.Lock()
+//		defer refs.Unlock()
+//		if name, ok := refs.typedNils[*cgo_get_uuid_from_ptr(self)]; ok {
+//			return C.CString(name)
+//		}
+//		return nil
+//	}
+func TypedNil() ast.Decl {
+	self := NewIdent("self")
+	name := NewIdent("name")
+	ok := NewIdent("ok")
+
+	statements := []ast.Stmt{
+		&ast.IfStmt{
+			Cond: IsNilExpr(self),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{Return(NewIdent("nil"))},
+			},
+		},
+	}
+	statements = append(statements, refLockUnlockDefer()...)
+	statements = append(statements,
+		&ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{name, ok},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{
+					&ast.IndexExpr{
+						X: &ast.SelectorExpr{
+							X:   NewIdent(REFS_VAR_NAME),
+							Sel: NewIdent(TYPED_NILS_FIELD_NAME),
+						},
+						Index: DeRef(&ast.CallExpr{
+							Fun:  NewIdent(GET_UUID_FROM_PTR_NAME),
+							Args: []ast.Expr{self},
+						}),
+					},
+				},
+			},
+			Cond: ok,
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{Return(ToCString(name))},
+			},
+		},
+		Return(NewIdent("nil")))
+
+	return &ast.FuncDecl{
+		Doc:  &ast.CommentGroup{List: ExportComments(TYPED_NIL_FUNC_NAME)},
+		Name: NewIdent(TYPED_NIL_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: charStarType}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: statements,
+		},
+	}
+}
+
+// HandleCall returns a call to cgo_handle for the pointer expression, which produces nil when isNil is true
+func HandleCall(ptr, isNil ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  NewIdent(HANDLE_FUNC_NAME),
+		Args: []ast.Expr{ptr, isNil},
+	}
+}
+
+// IfaceHandleCall returns a call to cgo_iface_handle for a pointer to an interface and the interface value
+func IfaceHandleCall(ptr, value ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  NewIdent(IFACE_HANDLE_FUNC_NAME),
+		Args: []ast.Expr{ptr, value},
+	}
+}
+
+// IsNilExpr returns the expression `expr == nil`
+func IsNilExpr(expr ast.Expr) ast.Expr {
+	return &ast.BinaryExpr{
+		X:  expr,
+		Op: token.EQL,
+		Y:  NewIdent("nil"),
+	}
+}
+
+/*
+NilSafeDeRef dereferences the value behind a handle, but produces nil for a nil handle rather than
+dereferencing a nil pointer. It is used for types which can hold nil without being pointers, such as
+slices and interfaces.
+
+	func() []string {
+		if ptr := cgo_get_ref(cgo_get_uuid_from_ptr(ident)); ptr != nil {
+			return *(*[]string)(ptr)
+		}
+		return nil
+	}()
+*/
+func NilSafeDeRef(resultType, castType, ident ast.Expr) ast.Expr {
+	ptr := NewIdent("ptr")
+	return &ast.CallExpr{
+		Fun: &ast.FuncLit{
+			Type: &ast.FuncType{
+				Params: &ast.FieldList{},
+				Results: &ast.FieldList{
+					List: []*ast.Field{{Type: resultType}},
+				},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.IfStmt{
+						Init: &ast.AssignStmt{
+							Lhs: []ast.Expr{ptr},
+							Tok: token.DEFINE,
+							Rhs: []ast.Expr{
+								&ast.CallExpr{
+									Fun: NewIdent(GET_REF_FUNC_NAME),
+									Args: []ast.Expr{
+										&ast.CallExpr{
+											Fun:  NewIdent(GET_UUID_FROM_PTR_NAME),
+											Args: []ast.Expr{ident},
+										},
+									},
+								},
+							},
+						},
+						Cond: &ast.BinaryExpr{
+							X:  ptr,
+							Op: token.NEQ,
+							Y:  NewIdent("nil"),
+						},
+						Body: &ast.BlockStmt{
+							List: []ast.Stmt{
+								Return(DeRef(CastUnsafePtr(DeRef(castType), ptr))),
+							},
+						},
+					},
+					Return(NewIdent("nil")),
+				},
+			},
+		},
+	}
+}
+
+// reflectKind returns the reflect.Kind constant named kind, such as reflect.Ptr
+func reflectKind(kind string) ast.Expr {
+	return &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent(kind)}
+}
+
+func reflectValueOf(expr ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   NewIdent("reflect"),
+			Sel: NewIdent("ValueOf"),
+		},
+		Args: []ast.Expr{expr},
+	}
+}
+
+func isInterface(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Interface)
+	return ok
+}
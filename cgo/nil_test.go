@@ -0,0 +1,51 @@
+package cgo
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+func TestCastOutBasic(t *testing.T) {
+	subject := CastOut(types.Typ[types.Int], NewIdent("x"))
+	assert.Equal(t, "x", exprString(subject))
+}
+
+func TestCastOutNilPointer(t *testing.T) {
+	subject := CastOut(types.NewPointer(types.Typ[types.Int]), NewIdent("x"))
+	assert.Equal(t, "cgo_handle(unsafe.Pointer(x), x == nil)", exprString(subject))
+}
+
+func TestCastOutNilSlice(t *testing.T) {
+	subject := CastOut(types.NewSlice(types.Typ[types.String]), NewIdent("x"))
+	assert.Equal(t, "cgo_handle(unsafe.Pointer(&x), x == nil)", exprString(subject))
+}
+
+func TestCastOutInterface(t *testing.T) {
+	subject := CastOut(types.Universe.Lookup("error").Type(), NewIdent("x"))
+	assert.Equal(t, "cgo_iface_handle(unsafe.Pointer(&x), x)", exprString(subject))
+}
+
+func TestIfaceHandleTracksTypedNils(t *testing.T) {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), IfaceHandle())
+	assert.Contains(t, buf.String(), "case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:")
+}
+
+func TestCastExprNilSafeSlice(t *testing.T) {
+	subject := exprString(CastExpr(types.NewSlice(types.Typ[types.String]), NewIdent("x")))
+	assert.Contains(t, subject, "func() []string {")
+	assert.Contains(t, subject, "return *(*[]string)(ptr)")
+	assert.Contains(t, subject, "return nil")
+}
@@ -0,0 +1,560 @@
+package cgo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	OPAQUE_HANDLE_FUNC_NAME = "cgo_opaque_handle"
+	OPAQUE_ARG_FUNC_NAME    = "cgo_opaque_arg"
+	OPAQUE_TYPE_FUNC_NAME   = "cgo_opaque_type"
+	OPAQUE_CHECK_FUNC_NAME  = "cgo_opaque_check"
+	OPAQUE_PARAMS_VAR_NAME  = "cgo_opaque_params"
+)
+
+// IsOpaque returns true if a type can't be named or represented by the generated wrapper, such as an unexported
+// or vendored type, a map, a channel or a func. Opaque values are passed to the host language as handles to a
+// boxed interface{}, which can be stored and handed back to Go, but not inspected.
+func IsOpaque(t types.Type) bool {
+	return isOpaque(t, map[*types.Named]bool{})
+}
+
+// isOpaque tracks the named types already being checked, so recursive types such as an interface whose methods
+// return the interface terminate. A type which refers back to itself is representable unless something else
+// makes it opaque.
+func isOpaque(t types.Type, visiting map[*types.Named]bool) bool {
+	if strings.Contains(t.String(), "/vendor/") {
+		return true
+	}
+
+	switch typ := t.(type) {
+	case *types.Chan, *types.Map, *types.Signature:
+		return true
+	case *types.Pointer:
+		return isOpaque(typ.Elem(), visiting)
+	case *types.Slice:
+		return isOpaque(typ.Elem(), visiting)
+	case *types.Named:
+		if visiting[typ] {
+			return false
+		}
+		visiting[typ] = true
+
+		if typ.Obj().Pkg() != nil && !typ.Obj().Exported() {
+			return true
+		}
+		if iface, ok := typ.Underlying().(*types.Interface); ok {
+			return !ImplementsError(typ) && hasOpaqueMethods(iface, visiting)
+		}
+		return isOpaque(typ.Underlying(), visiting)
+	default:
+		return false
+	}
+}
+
+// hasOpaqueMethods returns true if any exported method of the interface mentions an opaque or unsupported type.
+// Implementing an interface in the host language requires naming every type in its method signatures.
+func hasOpaqueMethods(iface *types.Interface, visiting map[*types.Named]bool) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		meth := iface.Method(i)
+		if !meth.Exported() {
+			continue
+		}
+		sig := meth.Type().(*types.Signature)
+		for _, tuple := range []*types.Tuple{sig.Params(), sig.Results()} {
+			for j := 0; j < tuple.Len(); j++ {
+				if isOpaque(tuple.At(j).Type(), visiting) || UnsupportedReason(tuple.At(j).Type()) != "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// HasOpaqueParams returns true if any of the params of the signature are opaque
+func HasOpaqueParams(sig *types.Signature) bool {
+	for i := 0; i < sig.Params().Len(); i++ {
+		if IsOpaque(sig.Params().At(i).Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpaqueHandle produces the cgo_opaque_handle function, which boxes a value in an interface{} and returns a
+// handle to the box. Nil values produce a nil handle.
+//
+//	func cgo_opaque_handle(value interface{}) unsafe.Pointer {
+//		if value == nil {
+//			return nil
+//		}
+//		switch v := reflect.ValueOf(value); v.Kind() {
+//		case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice:
+//			if v.IsNil() {
+//				return nil
+//			}
+//		}
+//		return C.CBytes(cgo_incref(unsafe.Pointer(&value)).Bytes())
+//	}
+func OpaqueHandle() ast.Decl {
+	value := NewIdent("value")
+	v := NewIdent("v")
+	returnNil := &ast.BlockStmt{
+		List: []ast.Stmt{Return(NewIdent("nil"))},
+	}
+
+	return &ast.FuncDecl{
+		Name: NewIdent(OPAQUE_HANDLE_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{value},
+						Type:  NewIdent("interface{}"),
+					},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: unsafePointer}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.IfStmt{
+					Cond: IsNilExpr(value),
+					Body: returnNil,
+				},
+				&ast.SwitchStmt{
+					Init: &ast.AssignStmt{
+						Lhs: []ast.Expr{v},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{reflectValueOf(value)},
+					},
+					Tag: methodCall(v, "Kind"),
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.CaseClause{
+								List: []ast.Expr{
+									reflectKind("Ptr"),
+									reflectKind("Map"),
+									reflectKind("Chan"),
+									reflectKind("Func"),
+									reflectKind("Slice"),
+								},
+								Body: []ast.Stmt{
+									&ast.IfStmt{
+										Cond: methodCall(v, "IsNil"),
+										Body: returnNil,
+									},
+								},
+							},
+						},
+					},
+				},
+				Return(UuidToCBytes(IncrementRefCall(Ref(value)))),
+			},
+		},
+	}
+}
+
+/*
+OpaqueArg produces the cgo_opaque_arg function, which unboxes the value behind an opaque handle as a
+reflect.Value of the type the receiving Go func expects. A nil handle produces the zero value of the type.
+
+	func cgo_opaque_arg(self unsafe.Pointer, t reflect.Type) reflect.Value {
+		if self != nil {
+			if value := *(*interface{})(cgo_get_ref(cgo_get_uuid_from_ptr(self))); value != nil {
+				v := reflect.ValueOf(value)
+				if !v.Type().AssignableTo(t) {
+					panic(fmt.Sprintf("opaque value of type %s can not be used as %s", v.Type(), t))
+				}
+				return v
+			}
+		}
+		return reflect.Zero(t)
+	}
+*/
+func OpaqueArg() ast.Decl {
+	self := NewIdent("self")
+	t := NewIdent("t")
+	value := NewIdent("value")
+	v := NewIdent("v")
+
+	return &ast.FuncDecl{
+		Name: NewIdent(OPAQUE_ARG_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{self},
+						Type:  unsafePointer,
+					},
+					{
+						Names: []*ast.Ident{t},
+						Type:  reflectType,
+					},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: reflectValue}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{X: self, Op: token.NEQ, Y: NewIdent("nil")},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.IfStmt{
+								Init: &ast.AssignStmt{
+									Lhs: []ast.Expr{value},
+									Tok: token.DEFINE,
+									Rhs: []ast.Expr{DeRef(CastUnsafePtrOfTypeUuid(DeRef(NewIdent("interface{}")), self))},
+								},
+								Cond: &ast.BinaryExpr{X: value, Op: token.NEQ, Y: NewIdent("nil")},
+								Body: &ast.BlockStmt{
+									List: []ast.Stmt{
+										&ast.AssignStmt{
+											Lhs: []ast.Expr{v},
+											Tok: token.DEFINE,
+											Rhs: []ast.Expr{reflectValueOf(value)},
+										},
+										&ast.IfStmt{
+											Cond: &ast.UnaryExpr{
+												Op: token.NOT,
+												X:  methodCall(methodCall(v, "Type"), "AssignableTo", t),
+											},
+											Body: &ast.BlockStmt{
+												List: []ast.Stmt{
+													&ast.ExprStmt{
+														X: &ast.CallExpr{
+															Fun: NewIdent("panic"),
+															Args: []ast.Expr{
+																&ast.CallExpr{
+																	Fun: &ast.SelectorExpr{X: NewIdent("fmt"), Sel: NewIdent("Sprintf")},
+																	Args: []ast.Expr{
+																		&ast.BasicLit{
+																			Kind:  token.STRING,
+																			Value: "\"opaque value of type %s can not be used as %s\"",
+																		},
+																		methodCall(v, "Type"),
+																		t,
+																	},
+																},
+															},
+														},
+													},
+												},
+											},
+										},
+										Return(v),
+									},
+								},
+							},
+						},
+					},
+				},
+				Return(&ast.CallExpr{
+					Fun:  &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("Zero")},
+					Args: []ast.Expr{t},
+				}),
+			},
+		},
+	}
+}
+
+// OpaqueType produces the exported cgo_opaque_type function, which returns the Go type name of the value behind
+// an opaque handle so the host language can describe it
+//
+//	func cgo_opaque_type(self unsafe.Pointer) *C.char {
+//		return C.CString(fmt.Sprintf("%T", *(*interface{})(cgo_get_ref(cgo_get_uuid_from_ptr(self)))))
+//	}
+func OpaqueType() ast.Decl {
+	self := NewIdent("self")
+	value := DeRef(CastUnsafePtrOfTypeUuid(DeRef(NewIdent("interface{}")), self))
+
+	return &ast.FuncDecl{
+		Doc:  &ast.CommentGroup{List: ExportComments(OPAQUE_TYPE_FUNC_NAME)},
+		Name: NewIdent(OPAQUE_TYPE_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: charStarType}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				Return(ToCString(FormatSprintf("%T", value))),
+			},
+		},
+	}
+}
+
+// OpaqueParamKey returns the key under which the type of the opaque param at index i of the wrapper named cName is
+// registered, such as "helloworld_Increment:0"
+func OpaqueParamKey(cName string, i int) string {
+	return fmt.Sprintf("%s:%d", cName, i)
+}
+
+// OpaqueParams produces the registry of the types expected by opaque params, which cgo_opaque_check looks up
+//
+//	var cgo_opaque_params = map[string]reflect.Type{}
+func OpaqueParams() ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names: []*ast.Ident{NewIdent(OPAQUE_PARAMS_VAR_NAME)},
+				Values: []ast.Expr{
+					&ast.CompositeLit{
+						Type: &ast.MapType{Key: NewIdent("string"), Value: reflectType},
+					},
+				},
+			},
+		},
+	}
+}
+
+/*
+OpaqueCheck produces the exported cgo_opaque_check function, which returns why the value behind an opaque handle
+can't be passed as the opaque param registered under key, or nil if it can. The host language raises the error
+rather than letting cgo_opaque_arg panic.
+
+	func cgo_opaque_check(self unsafe.Pointer, key *C.char) *C.char {
+		t, ok := cgo_opaque_params[C.GoString(key)]
+		if !ok || self == nil {
+			return nil
+		}
+		value := *(*interface{})(cgo_get_ref(cgo_get_uuid_from_ptr(self)))
+		if value == nil || reflect.TypeOf(value).AssignableTo(t) {
+			return nil
+		}
+		return C.CString(fmt.Sprintf("opaque value of type %T can not be used as %s", value, t))
+	}
+*/
+func OpaqueCheck() ast.Decl {
+	self := NewIdent("self")
+	key := NewIdent("key")
+	t := NewIdent("t")
+	ok := NewIdent("ok")
+	value := NewIdent("value")
+	nilIdent := NewIdent("nil")
+	returnNil := &ast.BlockStmt{
+		List: []ast.Stmt{Return(nilIdent)},
+	}
+	valueType := &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("TypeOf")},
+		Args: []ast.Expr{value},
+	}
+
+	return &ast.FuncDecl{
+		Doc:  &ast.CommentGroup{List: ExportComments(OPAQUE_CHECK_FUNC_NAME)},
+		Name: NewIdent(OPAQUE_CHECK_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(&ast.Field{
+				Names: []*ast.Ident{key},
+				Type:  charStarType,
+			}),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: charStarType}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{t, ok},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{
+						&ast.IndexExpr{
+							X: NewIdent(OPAQUE_PARAMS_VAR_NAME),
+							Index: &ast.CallExpr{
+								Fun:  &ast.SelectorExpr{X: NewIdent("C"), Sel: NewIdent("GoString")},
+								Args: []ast.Expr{key},
+							},
+						},
+					},
+				},
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{
+						X:  &ast.UnaryExpr{Op: token.NOT, X: ok},
+						Op: token.LOR,
+						Y:  &ast.BinaryExpr{X: self, Op: token.EQL, Y: nilIdent},
+					},
+					Body: returnNil,
+				},
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{value},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{DeRef(CastUnsafePtrOfTypeUuid(DeRef(NewIdent("interface{}")), self))},
+				},
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{
+						X:  &ast.BinaryExpr{X: value, Op: token.EQL, Y: nilIdent},
+						Op: token.LOR,
+						Y:  methodCall(valueType, "AssignableTo", t),
+					},
+					Body: returnNil,
+				},
+				Return(ToCString(FormatSprintf("opaque value of type %T can not be used as %s", value, t))),
+			},
+		},
+	}
+}
+
+/*
+opaqueParamsInit registers the types expected by the opaque params of the func, so cgo_opaque_check can reject
+values of other types before cgo_opaque_arg unboxes them. Methods are described by their method expression,
+whose first param is the receiver.
+
+	func init() {
+		cgo_opaque_params["veil_pkg_Use:0"] = reflect.TypeOf(veil_pkg.Use).In(0)
+	}
+*/
+func opaqueParamsInit(f *Func) ast.Decl {
+	sig := f.Signature()
+	fun := f.AliasedGoName()
+	offset := 0
+	if f.BoundRecv != nil {
+		fun = &ast.SelectorExpr{X: &ast.ParenExpr{X: DeRef(f.BoundRecv.CTypeName())}, Sel: NewIdent(f.Name())}
+		offset = 1
+	}
+	funType := &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("TypeOf")},
+		Args: []ast.Expr{fun},
+	}
+
+	stmts := []ast.Stmt{}
+	for i := 0; i < sig.Params().Len(); i++ {
+		if !IsOpaque(sig.Params().At(i).Type()) {
+			continue
+		}
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: []ast.Expr{
+				&ast.IndexExpr{
+					X:     NewIdent(OPAQUE_PARAMS_VAR_NAME),
+					Index: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(OpaqueParamKey(f.CName(), i))},
+				},
+			},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{
+				methodCall(funType, "In", &ast.BasicLit{Kind: token.INT, Value: fmt.Sprint(i + offset)}),
+			},
+		})
+	}
+
+	return &ast.FuncDecl{
+		Name: NewIdent("init"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: stmts},
+	}
+}
+
+// OpaqueHandleCall returns a call to cgo_opaque_handle for the value
+func OpaqueHandleCall(value ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  NewIdent(OPAQUE_HANDLE_FUNC_NAME),
+		Args: []ast.Expr{value},
+	}
+}
+
+/*
+buildOpaqueCall calls a func which takes opaque params through reflection, since the wrapper can't name their
+types. Opaque params are unboxed into the type the func expects and all other params are cast as usual.
+
+	castArg1 := C.GoString(name)
+	out := reflect.ValueOf(veil_pkg.Use).Call([]reflect.Value{
+		cgo_opaque_arg(state, reflect.TypeOf(veil_pkg.Use).In(0)),
+		reflect.ValueOf(&castArg1).Elem(),
+	})
+	var r0 string
+	reflect.ValueOf(&r0).Elem().Set(out[0])
+	r1 := out[1].Interface()
+	return C.CString(r0), cgo_opaque_handle(r1)
+*/
+func buildOpaqueCall(sig *types.Signature, fun ast.Expr, paramNames []string) ([]ast.Stmt, *ast.ReturnStmt, *ast.FieldList) {
+	out := NewIdent("out")
+	funType := &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("TypeOf")},
+		Args: []ast.Expr{fun},
+	}
+
+	stmts := []ast.Stmt{}
+	args := make([]ast.Expr, sig.Params().Len())
+	for i := 0; i < sig.Params().Len(); i++ {
+		param := sig.Params().At(i)
+		ident := NewIdent(paramNames[i])
+		if IsOpaque(param.Type()) {
+			args[i] = &ast.CallExpr{
+				Fun: NewIdent(OPAQUE_ARG_FUNC_NAME),
+				Args: []ast.Expr{
+					ident,
+					methodCall(funType, "In", &ast.BasicLit{Kind: token.INT, Value: fmt.Sprint(i)}),
+				},
+			}
+		} else {
+			castIdent := NewIdent(fmt.Sprintf("castArg%d", i+1))
+			stmts = append(stmts, &ast.AssignStmt{
+				Lhs: []ast.Expr{castIdent},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{CastExpr(param.Type(), ident)},
+			})
+			args[i] = methodCall(reflectValueOf(Ref(castIdent)), "Elem")
+		}
+	}
+
+	call := "Call"
+	if sig.Variadic() {
+		call = "CallSlice"
+	}
+	functionCall := methodCall(reflectValueOf(fun), call, &ast.CompositeLit{
+		Type: &ast.ArrayType{Elt: reflectValue},
+		Elts: args,
+	})
+
+	if sig.Results().Len() == 0 {
+		return append(stmts, &ast.ExprStmt{X: functionCall}), nil, nil
+	}
+
+	stmts = append(stmts, &ast.AssignStmt{
+		Lhs: []ast.Expr{out},
+		Tok: token.DEFINE,
+		Rhs: []ast.Expr{functionCall},
+	})
+
+	resultExprs := make([]ast.Expr, sig.Results().Len())
+	for i := 0; i < sig.Results().Len(); i++ {
+		result := sig.Results().At(i)
+		resultIdent := NewIdent(fmt.Sprintf("r%d", i))
+		outValue := &ast.IndexExpr{
+			X:     out,
+			Index: &ast.BasicLit{Kind: token.INT, Value: fmt.Sprint(i)},
+		}
+		if IsOpaque(result.Type()) {
+			stmts = append(stmts, &ast.AssignStmt{
+				Lhs: []ast.Expr{resultIdent},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{methodCall(outValue, "Interface")},
+			})
+		} else {
+			stmts = append(stmts,
+				DeclareVar(resultIdent, TypeExpression(result.Type())),
+				&ast.ExprStmt{
+					X: methodCall(methodCall(reflectValueOf(Ref(resultIdent)), "Elem"), "Set", outValue),
+				})
+		}
+		resultExprs[i] = CastOut(result.Type(), resultIdent)
+	}
+
+	return stmts, Return(resultExprs...), Fields(sig.Results())
+}
+
+var (
+	reflectValue = &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("Value")}
+	reflectType  = &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("Type")}
+)
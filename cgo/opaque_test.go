@@ -0,0 +1,51 @@
+package cgo
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func namedStruct(pkg *types.Package, name string) *types.Named {
+	obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+	return types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+}
+
+func TestIsOpaqueUnexported(t *testing.T) {
+	pkg := types.NewPackage("github.com/foo/bar", "bar")
+	assert.True(t, IsOpaque(types.NewPointer(namedStruct(pkg, "state"))))
+	assert.False(t, IsOpaque(types.NewPointer(namedStruct(pkg, "State"))))
+}
+
+func TestIsOpaqueVendored(t *testing.T) {
+	pkg := types.NewPackage("github.com/foo/bar/vendor/github.com/baz/client", "client")
+	assert.True(t, IsOpaque(namedStruct(pkg, "Client")))
+}
+
+func TestIsOpaqueUnsupported(t *testing.T) {
+	assert.True(t, IsOpaque(types.NewMap(types.Typ[types.String], types.Typ[types.Int])))
+	assert.True(t, IsOpaque(types.NewChan(types.SendRecv, types.Typ[types.Int])))
+	assert.False(t, IsOpaque(types.NewSlice(types.Typ[types.String])))
+	assert.False(t, IsOpaque(types.Universe.Lookup("error").Type()))
+}
+
+func TestCastOutOpaque(t *testing.T) {
+	pkg := types.NewPackage("github.com/foo/bar", "bar")
+	subject := CastOut(types.NewPointer(namedStruct(pkg, "state")), NewIdent("x"))
+	assert.Equal(t, "cgo_opaque_handle(x)", exprString(subject))
+}
+
+func TestOpaqueParamKey(t *testing.T) {
+	pkg := types.NewPackage("github.com/foo/bar", "bar")
+	params := types.NewTuple(
+		types.NewVar(token.NoPos, pkg, "s", types.NewPointer(namedStruct(pkg, "state"))),
+		types.NewVar(token.NoPos, pkg, "name", types.Typ[types.String]),
+	)
+	f := NewFunc(types.NewFunc(token.NoPos, pkg, "Use", types.NewSignatureType(nil, nil, nil, params, nil, false)))
+
+	assert.Equal(t, f.CName()+":0", f.OpaqueParamKey(0))
+	assert.Equal(t, "", f.OpaqueParamKey(1), "only opaque params are checked")
+	assert.Len(t, f.ToAst(), 2, "the types of opaque params are registered by an init func")
+}
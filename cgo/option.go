@@ -0,0 +1,228 @@
+package cgo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+
+	"github.com/devigned/veil/core"
+)
+
+const (
+	OPTION_HELPER_PREFIX = "With"
+	OPTIONS_MASK_NAME    = "options_mask"
+	MAX_OPTION_HELPERS   = 64
+)
+
+/*
+Functional options such as NewClient(addr string, opts ...Option) are bound through the With* helpers of the
+option type, like WithTimeout(seconds int) Option. The wrapper takes a mask of the helpers the host language
+called followed by the params of every helper, and builds the options on the Go side.
+
+	//export veil_pkg_NewClient
+	func veil_pkg_NewClient(addr *C.char, options_mask uint64, retries_0 int, timeout_0 int) unsafe.Pointer {
+		opts := []veil_pkg.Option{}
+		if options_mask&(1<<0) != 0 {
+			opts = append(opts, veil_pkg.WithRetries(retries_0))
+		}
+		if options_mask&(1<<1) != 0 {
+			opts = append(opts, veil_pkg.WithTimeout(timeout_0))
+		}
+		r0 := veil_pkg.NewClient(C.GoString(addr), opts...)
+		return cgo_handle(unsafe.Pointer(r0), r0 == nil)
+	}
+*/
+
+// Options are the With* helpers constructing values of a functional option type
+type Options struct {
+	Named   *types.Named
+	Helpers []*Func
+}
+
+// NewOptions returns the options of t if t is an exported named type whose package has With* helpers returning
+// it, or nil otherwise
+func NewOptions(t types.Type) *Options {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || !named.Obj().Exported() {
+		return nil
+	}
+
+	scope := named.Obj().Pkg().Scope()
+	helpers := []*Func{}
+	for _, name := range scope.Names() {
+		fun, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !fun.Exported() || !isOptionHelperName(name) {
+			continue
+		}
+
+		helper := NewFunc(fun)
+		sig := helper.Signature()
+		if sig.Variadic() || sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), named) ||
+			HasOpaqueParams(sig) {
+			continue
+		}
+		helpers = append(helpers, helper)
+	}
+
+	if len(helpers) == 0 || len(helpers) > MAX_OPTION_HELPERS {
+		return nil
+	}
+	return &Options{Named: named, Helpers: helpers}
+}
+
+// isOptionHelperName returns true for names like WithTimeout
+func isOptionHelperName(name string) bool {
+	suffix := strings.TrimPrefix(name, OPTION_HELPER_PREFIX)
+	return suffix != name && suffix != "" && unicode.IsUpper([]rune(suffix)[0])
+}
+
+// OptionName returns the name of the option set by helper, such as Timeout for WithTimeout
+func OptionName(helper *Func) string {
+	return strings.TrimPrefix(helper.Name(), OPTION_HELPER_PREFIX)
+}
+
+// HelperParamName returns the name of the i-th param of helper in the wrapper of a func taking options
+func HelperParamName(helper *Func, i int) string {
+	return fmt.Sprintf("%s_%d", core.ToSnake(OptionName(helper)), i)
+}
+
+// HelperParams returns the params of helper renamed for the wrapper of a func taking options
+func HelperParams(helper *Func) []*types.Var {
+	params := helper.Signature().Params()
+	renamed := make([]*types.Var, params.Len())
+	for i := range renamed {
+		param := params.At(i)
+		renamed[i] = types.NewParam(param.Pos(), param.Pkg(), HelperParamName(helper, i), param.Type())
+	}
+	return renamed
+}
+
+// Options returns the functional options accepted by the variadic last param of the func, or nil if the func
+// doesn't take options. Funcs with other opaque params are called with opaque handles instead.
+func (f Func) Options() *Options {
+	sig := f.Signature()
+	if sig == nil || !sig.Variadic() || !f.AcceptsOpaque() {
+		return nil
+	}
+
+	params := sig.Params()
+	for i := 0; i < params.Len()-1; i++ {
+		if IsOpaque(params.At(i).Type()) {
+			return nil
+		}
+	}
+	return NewOptions(params.At(params.Len() - 1).Type().(*types.Slice).Elem())
+}
+
+// buildOptionsMethod produces the wrapper of a func taking functional options
+func buildOptionsMethod(f *Func, options *Options) *ast.FuncDecl {
+	functionName := f.CName()
+	sig := f.Signature()
+	params := sig.Params()
+	variadic := params.At(params.Len() - 1)
+	opts := NewIdent(variadic.Name())
+	if variadic.Name() == "" || variadic.Name() == "_" {
+		opts = NewIdent("opts")
+	}
+	mask := NewIdent(OPTIONS_MASK_NAME)
+
+	fixed := types.NewTuple(tupleVars(params)[:params.Len()-1]...)
+	fields := Fields(fixed).List
+	callArgs := ParamIdents(fixed)
+	fields = append(fields, &ast.Field{Names: []*ast.Ident{mask}, Type: NewIdent("uint64")})
+
+	body := []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{opts},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CompositeLit{Type: &ast.ArrayType{Elt: TypeExpression(options.Named)}}},
+		},
+	}
+	for i, helper := range options.Helpers {
+		helperTuple := types.NewTuple(HelperParams(helper)...)
+		fields = append(fields, Fields(helperTuple).List...)
+
+		body = append(body, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{
+				X: &ast.BinaryExpr{
+					X:  mask,
+					Op: token.AND,
+					Y: &ast.ParenExpr{X: &ast.BinaryExpr{
+						X:  &ast.BasicLit{Kind: token.INT, Value: "1"},
+						Op: token.SHL,
+						Y:  &ast.BasicLit{Kind: token.INT, Value: fmt.Sprintf("%d", i)},
+					}},
+				},
+				Op: token.NEQ,
+				Y:  &ast.BasicLit{Kind: token.INT, Value: "0"},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{opts},
+						Tok: token.ASSIGN,
+						Rhs: []ast.Expr{&ast.CallExpr{
+							Fun: NewIdent("append"),
+							Args: []ast.Expr{opts, &ast.CallExpr{
+								Fun:  helper.AliasedGoName(),
+								Args: ParamIdents(helperTuple),
+							}},
+						}},
+					},
+				},
+			},
+		})
+	}
+
+	var fun ast.Expr = f.AliasedGoName()
+	var paramList *ast.FieldList
+	if f.BoundRecv != nil {
+		castSelf := NewIdent("castSelf")
+		body = append(body, &ast.AssignStmt{
+			Lhs: []ast.Expr{castSelf},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{CastUnsafePtrOfTypeUuid(DeRef(f.BoundRecv.CTypeName()), NewIdent("self"))},
+		})
+		fun = &ast.SelectorExpr{X: castSelf, Sel: NewIdent(f.Name())}
+		paramList = InstanceMethodParams(fields...)
+	} else {
+		paramList = &ast.FieldList{List: fields}
+	}
+
+	functionCall := &ast.CallExpr{
+		Fun:      fun,
+		Args:     append(callArgs, opts),
+		Ellipsis: token.Pos(1),
+	}
+	assign, returnStmt, results := buildFuncResults(sig, functionCall)
+	body = append(body, assign)
+	if returnStmt != nil {
+		body = append(body, returnStmt)
+	}
+
+	funcDecl := &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: paramList,
+		},
+		Body: &ast.BlockStmt{List: body},
+	}
+	if results != nil {
+		funcDecl.Type.Results = results
+	}
+	return funcDecl
+}
+
+func tupleVars(tuple *types.Tuple) []*types.Var {
+	vars := make([]*types.Var, tuple.Len())
+	for i := range vars {
+		vars[i] = tuple.At(i)
+	}
+	return vars
+}
@@ -0,0 +1,44 @@
+package cgo
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions(t *testing.T) {
+	pkg := buildTestPackage(t, `package options
+
+type Client struct {
+	Addr string
+}
+
+type Option func(*Client)
+
+func WithTimeout(seconds int) Option { return nil }
+func WithAgent(name, version string) Option { return nil }
+func WithHook(hook func()) Option { return nil }
+func Without() Option { return nil }
+
+func NewClient(addr string, opts ...Option) *Client { return nil }
+func Join(parts ...string) string { return "" }
+`)
+	lookup := func(name string) *Func {
+		return NewFunc(pkg.pkg.Scope().Lookup(name).(*types.Func))
+	}
+
+	newClient := lookup("NewClient")
+	options := newClient.Options()
+	if assert.NotNil(t, options) {
+		names := []string{}
+		for _, helper := range options.Helpers {
+			names = append(names, OptionName(helper))
+		}
+		assert.Equal(t, []string{"Agent", "Timeout"}, names)
+		assert.Equal(t, "agent_1", HelperParamName(options.Helpers[0], 1))
+	}
+	assert.True(t, newClient.IsExportable())
+	assert.Nil(t, lookup("Join").Options())
+	assert.Contains(t, symbolNames(pkg), newClient.CName())
+}
@@ -1,14 +1,13 @@
 package cgo
 
 import (
-	"go/build"
+	"fmt"
 	"go/doc"
 	"go/importer"
 	"go/parser"
 	"go/token"
 	"go/types"
-	"os"
-	"os/exec"
+	"sort"
 
 	"github.com/devigned/veil/core"
 	"github.com/emirpasic/gods/maps"
@@ -16,6 +15,7 @@ import (
 	"github.com/emirpasic/gods/sets/treeset"
 	"github.com/marstr/collection"
 	"go/ast"
+	"golang.org/x/tools/go/packages"
 	"strings"
 )
 
@@ -25,42 +25,118 @@ type Package struct {
 	doc            *doc.Package
 	symbols        *treemap.Map
 	packageAliases *treemap.Map
+	directives     map[string]*Directive
+	docs           map[string]string
+	config         *Config
+	module         *Module
 }
 
-// NewPackage constructs a Package from pkgPath using the specified working directory
-func NewPackage(pkgPath string, workDir string) (*Package, error) {
-	cmd := exec.Command("go", "install", pkgPath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Dir = workDir
+// Module is the Go module a package was loaded from
+type Module struct {
+	Path string
+	// Version is empty for the main module, which is the module being worked on rather than a dependency
+	Version string
+	Dir     string
+	Main    bool
+}
+
+// LOAD_MODE is what NewPackage needs go/packages to load: the type checked syntax of the package and its module.
+// Dependencies are type checked from source too, rather than read from export data, whose format changes between
+// Go releases.
+const LOAD_MODE = packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes |
+	packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps | packages.NeedModule
+
+// NewPackage constructs a Package from pkgPath using the specified working directory. Packages are loaded with
+// go/packages, so module mode, replace directives and GOFLAGS from the environment are honored, and only the
+// files matching the build constraints and buildFlags, such as -tags, are bound. Test files are never bound.
+func NewPackage(pkgPath string, workDir string, buildFlags ...string) (*Package, error) {
+	return NewPackageWithOverlay(pkgPath, workDir, nil, buildFlags...)
+}
 
-	if err := cmd.Run(); err != nil {
-		return nil, core.NewSystemErrorF("error installing [%s]: %v\n", pkgPath, err)
+// NewPackageWithOverlay constructs a Package like NewPackage, but reads the files in overlay from memory instead
+// of disk. Overlay is keyed by absolute file path, so unsaved edits can be previewed and files can be added to the
+// package without writing them.
+func NewPackageWithOverlay(pkgPath string, workDir string, overlay map[string][]byte,
+	buildFlags ...string) (*Package, error) {
+	cfg := &packages.Config{
+		Mode:       LOAD_MODE,
+		Dir:        workDir,
+		BuildFlags: buildFlags,
+		Overlay:    overlay,
 	}
 
-	buildPkg, err := build.Import(pkgPath, workDir, 0)
+	pkgs, err := packages.Load(cfg, pkgPath)
 	if err != nil {
-		return nil, core.NewSystemErrorF("error resolving import path [%s]: %v\n", pkgPath, err)
+		return nil, core.NewSystemErrorF("error loading package [%s]: %v\n", pkgPath, err)
 	}
 
-	typesPkg, err := importer.Default().Import(buildPkg.ImportPath)
-	if err != nil {
-		return nil, core.NewSystemErrorF("error importing package [%v]: %v\n", buildPkg.ImportPath, err)
+	if len(pkgs) != 1 {
+		return nil, core.NewUserErrorF("expected [%s] to match a single package, but it matched %d\n", pkgPath,
+			len(pkgs))
 	}
 
-	fset := token.NewFileSet()
-	astPkgs, err := parser.ParseDir(fset, buildPkg.Dir, nil, parser.ParseComments)
+	loaded := pkgs[0]
+	if len(loaded.Errors) > 0 {
+		msgs := make([]string, len(loaded.Errors))
+		for i, loadErr := range loaded.Errors {
+			msgs[i] = loadErr.Error()
+		}
+		return nil, core.NewUserErrorF("error loading package [%s]:\n%s\n", pkgPath, strings.Join(msgs, "\n"))
+	}
+
+	pkg, err := newPackage(loaded.Fset, loaded.Types, loaded.Syntax)
 	if err != nil {
 		return nil, err
 	}
+	if loaded.Module != nil {
+		pkg.module = &Module{
+			Path:    loaded.Module.Path,
+			Version: loaded.Module.Version,
+			Dir:     loaded.Module.Dir,
+			Main:    loaded.Module.Main,
+		}
+	}
+	return pkg, nil
+}
+
+// NewPackageFromSources constructs a Package for pkgPath from the contents of its files keyed by file name, which
+// are type checked with go/types. Nothing needs to be on disk, although imports are resolved from the compiled
+// standard library and GOPATH. Test files are skipped like they are by NewPackage.
+func NewPackageFromSources(pkgPath string, sources map[string]string) (*Package, error) {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		if !strings.HasSuffix(name, "_test.go") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fset := token.NewFileSet()
+	files := make([]*ast.File, len(names))
+	for i, name := range names {
+		file, err := parser.ParseFile(fset, name, sources[name], parser.ParseComments)
+		if err != nil {
+			return nil, core.NewUserErrorF("error parsing [%s]: %v\n", name, err)
+		}
+		files[i] = file
+	}
 
-	astPkg, ok := astPkgs[typesPkg.Name()]
-	if !ok {
-		return nil, core.NewSystemErrorF("could not find AST for package %q", typesPkg.Name())
+	conf := types.Config{Importer: importer.Default()}
+	typesPkg, err := conf.Check(pkgPath, fset, files, nil)
+	if err != nil {
+		return nil, core.NewUserErrorF("error type checking package [%s]: %v\n", pkgPath, err)
 	}
 
-	docPkg := doc.New(astPkg, buildPkg.ImportPath, 0)
+	return newPackage(fset, typesPkg, files)
+}
+
+// newPackage discovers the exported objects of a type checked package, documented by files
+func newPackage(fset *token.FileSet, typesPkg *types.Package, files []*ast.File) (*Package, error) {
+	// the AST is preserved, since directives are read from the doc comments go/doc would remove
+	docPkg, err := doc.NewFromFiles(fset, files, typesPkg.Path(), doc.PreserveAST)
+	if err != nil {
+		return nil, core.NewSystemErrorF("error reading docs of package [%s]: %v\n", typesPkg.Path(), err)
+	}
 
 	veilPkg := &Package{
 		pkg:            typesPkg,
@@ -69,13 +145,23 @@ func NewPackage(pkgPath string, workDir string) (*Package, error) {
 		symbols:        treemap.NewWithStringComparator(),
 	}
 
+	veilPkg.indexDocs()
 	if err = veilPkg.build(); err != nil {
 		return nil, err
 	}
+	if err = veilPkg.parseDirectives(fset, files); err != nil {
+		return nil, err
+	}
 
 	return veilPkg, nil
 }
 
+// Module returns the Go module the package was loaded from, or nil if it wasn't loaded from a module, such as
+// packages constructed from sources
+func (p Package) Module() *Module {
+	return p.module
+}
+
 func (p Package) AstTransformers() []AstTransformer {
 	v := make([]AstTransformer, p.symbols.Size())
 	for idx, item := range p.symbols.Values() {
@@ -120,6 +206,30 @@ func (p Package) Interfaces() []*Interface {
 	return v
 }
 
+// Seqs returns the iter.Seq and iter.Seq2 instantiations returned by the package
+func (p Package) Seqs() []*Seq {
+	keysValues := p.symbols.Select(func(key, value interface{}) bool {
+		_, ok := value.(*Seq)
+		return ok
+	})
+	v := make([]*Seq, keysValues.Size())
+	for idx, item := range keysValues.Values() {
+		v[idx] = item.(*Seq)
+	}
+	return v
+}
+
+// Streams returns the interfaces of the package which host language file objects can implement
+func (p Package) Streams() []*Interface {
+	streams := []*Interface{}
+	for _, iface := range p.Interfaces() {
+		if iface.IsStream() {
+			streams = append(streams, iface)
+		}
+	}
+	return streams
+}
+
 func (p Package) ExportedTypes() []types.Type {
 	values := p.AstTransformers()
 	output := make([]types.Type, len(values))
@@ -133,6 +243,24 @@ func (p Package) Name() string {
 	return p.pkg.Name()
 }
 
+// Path returns the import path of the package
+func (p Package) Path() string {
+	return p.pkg.Path()
+}
+
+// Slices returns the wrappers of the slice types used by the package
+func (p Package) Slices() []*Slice {
+	slices := []*Slice{}
+	for _, exp := range p.ExportedTypes() {
+		if slice, ok := exp.(Slice); ok {
+			slices = append(slices, &slice)
+		}
+	}
+	return slices
+}
+
+// build discovers every exported object and every type reachable from them. Discovery works through a queue
+// and a visited set rather than recursion, so self-referential and mutually recursive types are visited once.
 func (p *Package) build() error {
 	scope := p.pkg.Scope()
 	exportedObjects := collection.AsEnumerable(scope.Names()).Enumerate(nil).
@@ -143,10 +271,27 @@ func (p *Package) build() error {
 			return scope.Lookup(name.(string))
 		})
 
+	var queue []interface{}
 	for obj := range exportedObjects {
-		if err := p.addExportedObject(obj); err != nil {
+		queue = append(queue, obj)
+	}
+
+	visited := map[string]bool{}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+
+		key := visitKey(obj)
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+
+		reachable, err := p.addExportedObject(obj)
+		if err != nil {
 			return err
 		}
+		queue = append(queue, reachable...)
 	}
 
 	for _, aster := range p.AstTransformers() {
@@ -158,7 +303,24 @@ func (p *Package) build() error {
 	return nil
 }
 
-func (p Package) addExportedObject(obj interface{}) error {
+// visitKey identifies an object or type during discovery. Types are keyed by their type string, since
+// identical composite types such as []*Node are distinct values wherever they appear.
+func visitKey(obj interface{}) string {
+	switch t := obj.(type) {
+	case types.Type:
+		return "type:" + types.TypeString(t, nil)
+	case *types.Func:
+		return "func:" + t.FullName()
+	case types.Object:
+		return "object:" + t.String()
+	default:
+		return fmt.Sprintf("%T:%v", obj, obj)
+	}
+}
+
+// addExportedObject registers the wrapper for obj and returns the objects and types reachable from it, which
+// still need to be discovered
+func (p Package) addExportedObject(obj interface{}) ([]interface{}, error) {
 	addExport := func(item AstTransformer) bool {
 		exportName := item.ExportName()
 		if _, ok := p.symbols.Get(exportName); ok || !item.IsExportable() {
@@ -170,25 +332,33 @@ func (p Package) addExportedObject(obj interface{}) error {
 		}
 	}
 
-	handleNamed := func(named *types.Named) error {
+	varTypes := func(vars []*types.Var) []interface{} {
+		reachable := make([]interface{}, len(vars))
+		for i, v := range vars {
+			reachable[i] = v.Type()
+		}
+		return reachable
+	}
+
+	handleNamed := func(named *types.Named) ([]interface{}, error) {
+		if UnsupportedReason(named) != "" {
+			// unsupported types such as generics and named arrays are skipped, and the reason is reported by Report
+			return nil, nil
+		}
+
+		var reachable []interface{}
 		switch named.Underlying().(type) {
 		case *types.Struct:
 			structWapper := NewStruct(named)
 			if addExport(structWapper) {
 				for _, method := range structWapper.ExportedMethods() {
-					for _, v := range allVars(method) {
-						if err := p.addExportedObject(v.Type()); err != nil {
-							return err
-						}
-					}
+					reachable = append(reachable, varTypes(allVars(method))...)
 				}
 
 				for i := 0; i < structWapper.Struct().NumFields(); i++ {
 					field := structWapper.Struct().Field(i)
 					if field.Exported() {
-						if err := p.addExportedObject(field.Type()); err != nil {
-							return err
-						}
+						reachable = append(reachable, field.Type())
 					}
 				}
 			}
@@ -201,54 +371,55 @@ func (p Package) addExportedObject(obj interface{}) error {
 				iface := NewInterface(named)
 				if addExport(iface) {
 					for _, method := range iface.ExportedMethods() {
-						for _, v := range allVars(method) {
-							if err := p.addExportedObject(v.Type()); err != nil {
-								return err
-							}
-						}
+						reachable = append(reachable, varTypes(allVars(method))...)
 					}
 				}
 			}
 		case *types.Slice:
 			addExport(NewNamed(named))
-		default:
-			return core.NewSystemError("I don't know how to handle named types like: ", obj)
+		case *types.Signature:
+			// func types are passed as opaque handles, or built from their helpers when they are options
+		}
+		return reachable, nil
+	}
+
+	if t, ok := obj.(types.Type); ok && IsPullable(t) {
+		seq := NewSeq(t.(*types.Named))
+		if addExport(seq) {
+			reachable := []interface{}{}
+			for _, elem := range seq.Elems() {
+				reachable = append(reachable, elem)
+			}
+			return reachable, nil
 		}
-		return nil
+		return nil, nil
+	}
+
+	if t, ok := obj.(types.Type); ok && IsOpaque(t) {
+		// opaque values are passed as handles, so there is nothing to wrap
+		return nil, nil
 	}
 
 	switch t := obj.(type) {
 	case *types.Func:
 		funcWrapper := NewFunc(t)
 		if addExport(funcWrapper) {
-			for _, v := range allVars(funcWrapper) {
-				if err := p.addExportedObject(v.Type()); err != nil {
-					return err
-				}
-			}
+			return varTypes(allVars(funcWrapper)), nil
 		}
 	case *types.Slice:
 		addExport(NewSlice(t.Elem()))
-		if err := p.addExportedObject(t.Elem()); err != nil {
-			return err
-		}
+		return []interface{}{t.Elem()}, nil
 	case *types.TypeName:
-		named := t.Type().(*types.Named)
-		if t.Exported() {
-			if err := handleNamed(named); err != nil {
-				return err
-			}
+		// aliases are reported rather than bound, since the types they name are bound under their own names
+		if t.Exported() && !t.IsAlias() {
+			return handleNamed(t.Type().(*types.Named))
 		}
 	case *types.Named:
-		if err := handleNamed(t); err != nil {
-			return err
-		}
+		return handleNamed(t)
 	case *types.Pointer:
-		if err := p.addExportedObject(t.Elem()); err != nil {
-			return err
-		}
+		return []interface{}{t.Elem()}, nil
 	}
-	return nil
+	return nil, nil
 }
 
 func (p Package) ImportAliases() maps.Map {
@@ -277,6 +448,14 @@ func (p Package) CDefinitions() []string {
 		funcPtrs = append(funcPtrs, f...)
 		calls = append(calls, c...)
 	}
+	releaseFuncPtr, releaseCall := ReleaseCDefs()
+	funcPtrs = append(funcPtrs, releaseFuncPtr)
+	calls = append(calls, releaseCall)
+	if len(p.Streams()) > 0 {
+		streamFuncPtr, streamCall := StreamCDefs()
+		funcPtrs = append(funcPtrs, streamFuncPtr)
+		calls = append(calls, streamCall)
+	}
 	retTypes = uniqStrings(retTypes...)
 	funcPtrs = uniqStrings(funcPtrs...)
 	calls = uniqStrings(calls...)
@@ -0,0 +1,288 @@
+package cgo
+
+import (
+	"go/ast"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildTestPackage(t *testing.T, src string) *Package {
+	veilPkg, err := NewPackageFromSources("github.com/foo/recursive", map[string]string{"src.go": src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return veilPkg
+}
+
+func symbolNames(pkg *Package) []string {
+	var names []string
+	for _, key := range pkg.symbols.Keys() {
+		names = append(names, key.(string))
+	}
+	return names
+}
+
+func TestBuildTree(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type Node struct {
+	Value    string
+	Parent   *Node
+	Children []*Node
+}
+
+func NewTree(value string) *Node { return &Node{Value: value} }
+func (n *Node) Add(child *Node) *Node { return n }
+`)
+	names := symbolNames(pkg)
+	assert.Contains(t, names, "veil_github_com_foo_recursive_Node")
+	assert.Contains(t, names, "veil_github_com_foo_recursive_NewTree")
+	assert.Contains(t, names, "slice_of_pointer_to_veil_github_com_foo_recursive_Node")
+	assert.Len(t, pkg.Structs(), 1)
+	assert.Len(t, pkg.Structs()[0].ExportedMethods(), 1)
+}
+
+func TestBuildGraph(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type Graph struct {
+	Vertices []Vertex
+}
+
+type Vertex struct {
+	Name  string
+	Edges []Edge
+}
+
+type Edge struct {
+	From *Vertex
+	To   *Vertex
+}
+`)
+	names := symbolNames(pkg)
+	for _, name := range []string{"Graph", "Vertex", "Edge"} {
+		assert.Contains(t, names, "veil_github_com_foo_recursive_"+name)
+	}
+	assert.Contains(t, names, "slice_of_veil_github_com_foo_recursive_Vertex")
+	assert.Contains(t, names, "slice_of_veil_github_com_foo_recursive_Edge")
+}
+
+func TestBuildMutuallyRecursive(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type Employee struct {
+	Name string
+	Team *Team
+}
+
+type Team struct {
+	Lead    *Employee
+	Members []*Employee
+}
+
+type Visitor interface {
+	Visit(v Visitor) Visitor
+	Employee() *Employee
+}
+
+func Walk(v Visitor) {}
+`)
+	names := symbolNames(pkg)
+	assert.Contains(t, names, "veil_github_com_foo_recursive_Employee")
+	assert.Contains(t, names, "veil_github_com_foo_recursive_Team")
+	assert.Contains(t, names, "veil_github_com_foo_recursive_Walk")
+	assert.Len(t, pkg.Interfaces(), 1)
+	assert.True(t, pkg.Interfaces()[0].IsExportable())
+}
+
+func TestBuildRecursiveInterfaceWithOpaqueMethod(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type Visitor interface {
+	Visit(v Visitor) Visitor
+	Done() chan bool
+}
+
+func Walk(v Visitor) {}
+`)
+	assert.Empty(t, pkg.Interfaces())
+	assert.True(t, IsOpaque(pkg.pkg.Scope().Lookup("Visitor").Type()))
+	assert.Len(t, pkg.Funcs(), 1)
+}
+
+func writeTestModule(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestNewPackageModuleMode(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": "module example.com/tagged\n\ngo 1.21\n",
+		"tagged.go": `package tagged
+// Hello greets
+func Hello() string { return "hello" }
+`,
+		"extra.go": `//go:build extra
+
+package tagged
+import "strings"
+func Extra() string { return strings.ToUpper("extra") }
+`,
+		"tagged_test.go": `package tagged
+func TestOnly() string { return "test" }
+`,
+	})
+
+	pkg, err := NewPackage("example.com/tagged", dir)
+	assert.NoError(t, err)
+	names := symbolNames(pkg)
+	assert.Contains(t, names, "veil_example_com_tagged_Hello")
+	assert.NotContains(t, names, "veil_example_com_tagged_Extra")
+	assert.NotContains(t, names, "veil_example_com_tagged_TestOnly")
+	assert.Len(t, pkg.doc.Funcs, 1)
+
+	pkg, err = NewPackage("example.com/tagged", dir, "-tags=extra")
+	assert.NoError(t, err)
+	assert.Contains(t, symbolNames(pkg), "veil_example_com_tagged_Extra")
+}
+
+func TestNewPackageErrors(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":    "module example.com/broken\n\ngo 1.21\n",
+		"broken.go": "package broken\nfunc Broken() int { return \"nope\" }\n",
+	})
+
+	_, err := NewPackage("example.com/broken", dir)
+	assert.Error(t, err)
+}
+
+func TestNewPackageWithOverlay(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":   "module example.com/overlay\n\ngo 1.21\n",
+		"saved.go": "package overlay\nfunc Saved() string { return \"saved\" }\n",
+	})
+
+	pkg, err := NewPackageWithOverlay("example.com/overlay", dir, map[string][]byte{
+		filepath.Join(dir, "saved.go"):   []byte("package overlay\nfunc Edited() string { return \"edited\" }\n"),
+		filepath.Join(dir, "unsaved.go"): []byte("package overlay\nfunc Unsaved() int { return 1 }\n"),
+	})
+	assert.NoError(t, err)
+	names := symbolNames(pkg)
+	assert.Contains(t, names, "veil_example_com_overlay_Edited")
+	assert.Contains(t, names, "veil_example_com_overlay_Unsaved")
+	assert.NotContains(t, names, "veil_example_com_overlay_Saved")
+}
+
+func TestNewPackageFromSources(t *testing.T) {
+	cases := []struct {
+		name     string
+		sources  map[string]string
+		symbols  []string
+		excluded []string
+	}{
+		{
+			name:    "func",
+			sources: map[string]string{"a.go": "package snippet\nfunc Add(a, b int) int { return a + b }\n"},
+			symbols: []string{"veil_example_com_snippet_Add"},
+		},
+		{
+			name: "struct across files",
+			sources: map[string]string{
+				"a.go": "package snippet\ntype Point struct{ X, Y int }\n",
+				"b.go": "package snippet\nfunc Origin() *Point { return &Point{} }\n",
+			},
+			symbols: []string{"veil_example_com_snippet_Point", "veil_example_com_snippet_Origin"},
+		},
+		{
+			name: "test files are skipped",
+			sources: map[string]string{
+				"a.go":      "package snippet\nfunc Add(a, b int) int { return a + b }\n",
+				"a_test.go": "package snippet\nfunc Helper() {}\n",
+			},
+			symbols:  []string{"veil_example_com_snippet_Add"},
+			excluded: []string{"veil_example_com_snippet_Helper"},
+		},
+		{
+			name:     "unexported",
+			sources:  map[string]string{"a.go": "package snippet\nfunc add(a, b int) int { return a + b }\n"},
+			excluded: []string{"veil_example_com_snippet_add"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pkg, err := NewPackageFromSources("example.com/snippet", c.sources)
+			assert.NoError(t, err)
+			names := symbolNames(pkg)
+			for _, symbol := range c.symbols {
+				assert.Contains(t, names, symbol)
+			}
+			for _, symbol := range c.excluded {
+				assert.NotContains(t, names, symbol)
+			}
+		})
+	}
+}
+
+func TestNewPackageFromSourcesErrors(t *testing.T) {
+	_, err := NewPackageFromSources("example.com/snippet", map[string]string{"a.go": "package snippet\nfunc {"})
+	assert.Error(t, err)
+
+	_, err = NewPackageFromSources("example.com/snippet", map[string]string{"a.go": "package snippet\nvar X int = \"x\"\n"})
+	assert.Error(t, err)
+}
+
+func TestSymbolsAreDeclared(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+import "io"
+
+type Point struct {
+	X, Y int
+}
+
+type Shape struct {
+	Name   string
+	Points []Point
+}
+
+type Source interface {
+	io.ReadCloser
+}
+
+func Outline(s Shape, src Source) []Point { return nil }
+`)
+	declared := map[string]bool{}
+	for _, decl := range pkg.ToAst() {
+		if fun, ok := decl.(*ast.FuncDecl); ok {
+			declared[fun.Name.Name] = true
+		}
+	}
+
+	symbols := []string{}
+	for _, s := range pkg.Structs() {
+		for _, symbol := range s.Symbols() {
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, iface := range pkg.Interfaces() {
+		for _, symbol := range iface.Symbols() {
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, slice := range pkg.Slices() {
+		for _, symbol := range slice.Symbols() {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	assert.Contains(t, symbols, "veil_github_com_foo_recursive_Point_equal")
+	assert.Contains(t, symbols, "veil_github_com_foo_recursive_Source_stream_read")
+	assert.Contains(t, symbols, "slice_of_veil_github_com_foo_recursive_Point_item_ref")
+	for _, symbol := range symbols {
+		assert.True(t, declared[symbol], "%s is not declared", symbol)
+	}
+}
@@ -0,0 +1,126 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+const (
+	RELEASE_FUNC_VAR_NAME        = "cgo_release_func"
+	RELEASE_FUNC_NAME            = "cgo_release"
+	REGISTER_RELEASE_FUNC_NAME   = "cgo_register_release"
+	RELEASE_C_FUNC_PTR_NAME      = "ReleaseFunc"
+	RELEASE_C_CALLBACK_FUNC_NAME = "CallReleaseFunc"
+)
+
+/*
+Host language implementations of interfaces are pinned by the host language while Go holds a reference to
+them. Each time an implementation crosses into Go it is wrapped in a fresh helper with a finalizer, and when
+Go no longer references the helper the finalizer releases the pin through a callback registered by the host
+language.
+*/
+
+// ReleaseCDefs returns the C function pointer type and call used to release pinned host language objects
+func ReleaseCDefs() (funcPtr string, call string) {
+	funcPtr = "//typedef void " + RELEASE_C_FUNC_PTR_NAME + "(void *handle);"
+	call = "//static inline void " + RELEASE_C_CALLBACK_FUNC_NAME + "(void *handle, " + RELEASE_C_FUNC_PTR_NAME +
+		" *fn){ fn(handle); }"
+	return funcPtr, call
+}
+
+// ReleaseFuncVar produces the variable holding the host language release callback
+//
+//	var cgo_release_func unsafe.Pointer
+func ReleaseFuncVar() ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names: []*ast.Ident{NewIdent(RELEASE_FUNC_VAR_NAME)},
+				Type:  unsafePointer,
+			},
+		},
+	}
+}
+
+// RegisterRelease produces the exported function the host language calls to register its release callback
+//
+//	func cgo_register_release(fn unsafe.Pointer) {
+//		cgo_release_func = fn
+//	}
+func RegisterRelease() ast.Decl {
+	fn := NewIdent("fn")
+	return &ast.FuncDecl{
+		Doc:  &ast.CommentGroup{List: ExportComments(REGISTER_RELEASE_FUNC_NAME)},
+		Name: NewIdent(REGISTER_RELEASE_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{fn},
+						Type:  unsafePointer,
+					},
+				},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{NewIdent(RELEASE_FUNC_VAR_NAME)},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{fn},
+				},
+			},
+		},
+	}
+}
+
+// Release produces the function which releases a pinned host language object by its handle
+//
+//	func cgo_release(handle unsafe.Pointer) {
+//		if cgo_release_func != nil {
+//			C.CallReleaseFunc(handle, (*C.ReleaseFunc)(cgo_release_func))
+//		}
+//	}
+func Release() ast.Decl {
+	handle := NewIdent("handle")
+	releaseFunc := NewIdent(RELEASE_FUNC_VAR_NAME)
+	return &ast.FuncDecl{
+		Name: NewIdent(RELEASE_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{handle},
+						Type:  unsafePointer,
+					},
+				},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{X: releaseFunc, Op: token.NEQ, Y: NewIdent("nil")},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.ExprStmt{
+								X: &ast.CallExpr{
+									Fun: &ast.SelectorExpr{
+										X:   NewIdent("C"),
+										Sel: NewIdent(RELEASE_C_CALLBACK_FUNC_NAME),
+									},
+									Args: []ast.Expr{
+										handle,
+										CastUnsafePtr(
+											DeRef(&ast.SelectorExpr{X: NewIdent("C"), Sel: NewIdent(RELEASE_C_FUNC_PTR_NAME)}),
+											releaseFunc),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
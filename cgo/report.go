@@ -0,0 +1,340 @@
+package cgo
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+const (
+	STATUS_BOUND   = "bound"
+	STATUS_PARTIAL = "partial"
+	STATUS_SKIPPED = "skipped"
+	// STATUS_EXCLUDED symbols are left out by a directive or the Config of the package
+	STATUS_EXCLUDED = "excluded"
+
+	SYMBOL_KIND_FUNC      = "func"
+	SYMBOL_KIND_STRUCT    = "struct"
+	SYMBOL_KIND_INTERFACE = "interface"
+	SYMBOL_KIND_TYPE      = "type"
+)
+
+// reservedTagNames are the names bindings can't give properties, such as the keywords of their languages
+var reservedTagNames = map[string]bool{}
+
+// ReserveTagNames marks names which bindings can't give properties. Bindings named after struct tags add an
+// underscore to such tag names, and Report notes the fields tagged with them.
+func ReserveTagNames(names ...string) {
+	for _, name := range names {
+		reservedTagNames[name] = true
+	}
+}
+
+// Symbol reports how an exported func or type of the package is bound. A partially bound symbol is missing some
+// of its fields or methods, and the reasons say which and why.
+type Symbol struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// IsBound returns true if all of the symbol is bound
+func (s Symbol) IsBound() bool {
+	return s.Status == STATUS_BOUND
+}
+
+// IsUnsupported returns true if some or all of the symbol can't be bound, as opposed to being excluded
+func (s Symbol) IsUnsupported() bool {
+	return s.Status == STATUS_PARTIAL || s.Status == STATUS_SKIPPED
+}
+
+func (s *Symbol) skip(reason string) {
+	s.Status = STATUS_SKIPPED
+	s.Reasons = append(s.Reasons, reason)
+}
+
+func (s *Symbol) omit(reason string) {
+	if s.Status == STATUS_BOUND {
+		s.Status = STATUS_PARTIAL
+	}
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// note adds a reason which leaves the status of the symbol as it is, such as a field bound under another name
+func (s *Symbol) note(reason string) {
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// Report describes how each exported func and type of the package is bound, ordered by name. Constants and
+// variables are never bound, so they aren't reported.
+func (p Package) Report() []*Symbol {
+	excluded := p.excludedTypes()
+	scope := p.pkg.Scope()
+	symbols := []*Symbol{}
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			symbols = append(symbols, p.reportFunc(NewFunc(obj), excluded))
+		case *types.TypeName:
+			if obj.IsAlias() {
+				symbols = append(symbols, p.reportAlias(obj))
+			} else if named, ok := obj.Type().(*types.Named); ok {
+				symbols = append(symbols, p.reportNamed(named, excluded))
+			}
+		}
+	}
+	return symbols
+}
+
+func (p Package) reportFunc(f *Func, excluded map[*types.Named]bool) *Symbol {
+	symbol := &Symbol{Name: f.Name(), Kind: SYMBOL_KIND_FUNC, Status: STATUS_BOUND}
+	if p.IsExcluded(f.Name()) {
+		symbol.Status = STATUS_EXCLUDED
+	} else if named := f.excludedType(excluded); named != nil {
+		symbol.skip(excludedReason(named))
+	} else if reason := f.SkipReason(); reason != "" {
+		symbol.skip(reason)
+	}
+	return symbol
+}
+
+func (p Package) reportNamed(named *types.Named, excluded map[*types.Named]bool) *Symbol {
+	symbol := &Symbol{Name: named.Obj().Name(), Kind: SYMBOL_KIND_TYPE, Status: STATUS_BOUND}
+	switch named.Underlying().(type) {
+	case *types.Struct:
+		symbol.Kind = SYMBOL_KIND_STRUCT
+	case *types.Interface:
+		symbol.Kind = SYMBOL_KIND_INTERFACE
+	}
+
+	if p.IsExcluded(symbol.Name) {
+		symbol.Status = STATUS_EXCLUDED
+		return symbol
+	}
+	if reason := UnsupportedReason(named); reason != "" {
+		symbol.skip(reason)
+		return symbol
+	}
+	if excluded[named] {
+		// interfaces whose methods refer to excluded types can't be implemented
+		for _, meth := range interfaceMethods(named) {
+			if excludedType := NewFunc(meth).excludedType(excluded); excludedType != nil {
+				symbol.skip(fmt.Sprintf("method %s: %s", meth.Name(), excludedReason(excludedType)))
+			}
+		}
+		return symbol
+	}
+
+	switch symbol.Kind {
+	case SYMBOL_KIND_STRUCT:
+		s := NewStruct(named)
+		for i := 0; i < s.Struct().NumFields(); i++ {
+			field := s.Struct().Field(i)
+			if !field.Exported() || p.IsExcluded(symbol.Name+"."+field.Name()) {
+				continue
+			}
+			if ShouldGenerateField(field) {
+				if excludedType := excludedIn(field.Type(), excluded); excludedType != nil {
+					symbol.omit(fmt.Sprintf("field %s: %s", field.Name(), excludedReason(excludedType)))
+				} else if name := reservedTagName(s.Struct().Tag(i)); name != "" {
+					symbol.note(fmt.Sprintf("field %s: its tag name %s is reserved, so properties named after tags "+
+						"call it %s_", field.Name(), name, name))
+				}
+				continue
+			}
+			reason := UnsupportedReason(field.Type())
+			if reason == "" {
+				reason = fmt.Sprintf("opaque values such as %s have no field accessors", typeString(field.Type()))
+			}
+			symbol.omit(fmt.Sprintf("field %s: %s", field.Name(), reason))
+		}
+		p.reportMethods(symbol, s.Named, excluded)
+	case SYMBOL_KIND_INTERFACE:
+		if !ImplementsError(named) {
+			if reason := NewInterface(named).SkipReason(); reason != "" {
+				symbol.omit(reason + ", so values of the interface are passed as opaque handles")
+			}
+		}
+	default:
+		if excludedType := excludedIn(named.Underlying(), excluded); excludedType != nil {
+			symbol.skip(excludedReason(excludedType))
+			break
+		}
+		if _, ok := named.Underlying().(*types.Slice); ok {
+			p.reportMethods(symbol, NewNamed(named), excluded)
+			break
+		}
+		for i := 0; i < named.NumMethods(); i++ {
+			if meth := named.Method(i); meth.Exported() {
+				symbol.omit(fmt.Sprintf("method %s: only the methods of structs and named slices are bound",
+					meth.Name()))
+			}
+		}
+	}
+	return symbol
+}
+
+// reportAlias skips type aliases, which aren't bound. Values of the aliased type are bound under its own name.
+func (p Package) reportAlias(obj *types.TypeName) *Symbol {
+	symbol := &Symbol{Name: obj.Name(), Kind: SYMBOL_KIND_TYPE, Status: STATUS_BOUND}
+	if p.IsExcluded(symbol.Name) {
+		symbol.Status = STATUS_EXCLUDED
+		return symbol
+	}
+	symbol.skip(fmt.Sprintf("type aliases such as %s = %s are not bound", obj.Name(),
+		typeString(types.Unalias(obj.Type()))))
+	return symbol
+}
+
+// reportMethods adds the reasons the exported methods of named which can't be bound are omitted
+func (p Package) reportMethods(symbol *Symbol, named *Named, excluded map[*types.Named]bool) {
+	for i := 0; i < named.NumMethods(); i++ {
+		meth := named.Method(i)
+		if !meth.Exported() || p.IsExcluded(symbol.Name+"."+meth.Name()) {
+			continue
+		}
+		f := NewBoundFunc(meth, named)
+		if excludedType := f.excludedType(excluded); excludedType != nil {
+			symbol.omit(fmt.Sprintf("method %s: %s", meth.Name(), excludedReason(excludedType)))
+		} else if reason := f.SkipReason(); reason != "" {
+			symbol.omit(fmt.Sprintf("method %s: %s", meth.Name(), reason))
+		}
+	}
+}
+
+// excludedTypes returns the structs and interfaces left out of bindings, which are those excluded by a directive
+// or the config along with the interfaces whose methods refer to them. Bindings leave out every func, method and
+// field referring to them as well.
+func (p Package) excludedTypes() map[*types.Named]bool {
+	excluded := map[*types.Named]bool{}
+	interfaces := []*types.Named{}
+	scope := p.pkg.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !obj.Exported() || obj.IsAlias() {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		switch named.Underlying().(type) {
+		case *types.Struct:
+			excluded[named] = p.IsExcluded(name)
+		case *types.Interface:
+			excluded[named] = p.IsExcluded(name)
+			interfaces = append(interfaces, named)
+		}
+	}
+
+	// excluding an interface may in turn leave out other interfaces
+	for changed := true; changed; {
+		changed = false
+		for _, iface := range interfaces {
+			if excluded[iface] {
+				continue
+			}
+			for _, meth := range interfaceMethods(iface) {
+				if NewFunc(meth).excludedType(excluded) != nil {
+					excluded[iface], changed = true, true
+					break
+				}
+			}
+		}
+	}
+	return excluded
+}
+
+// excludedType returns the first excluded type the params, results or option helpers of the func refer to, or
+// nil if they refer to none
+func (f Func) excludedType(excluded map[*types.Named]bool) *types.Named {
+	vars := allVars(&f)
+	if options := f.Options(); options != nil {
+		for _, helper := range options.Helpers {
+			vars = append(vars, HelperParams(helper)...)
+		}
+	}
+	for _, v := range vars {
+		if named := excludedIn(v.Type(), excluded); named != nil {
+			return named
+		}
+	}
+	return nil
+}
+
+// excludedIn returns the excluded type t is or is made of, the way bindings describe it, or nil if there is none
+func excludedIn(t types.Type, excluded map[*types.Named]bool) *types.Named {
+	return excludedInType(t, excluded, map[*types.Named]bool{})
+}
+
+func excludedInType(t types.Type, excluded map[*types.Named]bool, visiting map[*types.Named]bool) *types.Named {
+	if IsPullable(t) {
+		for _, elem := range NewSeq(t.(*types.Named)).Elems() {
+			if named := excludedInType(elem, excluded, visiting); named != nil {
+				return named
+			}
+		}
+		return nil
+	}
+	if IsOpaque(t) {
+		return nil
+	}
+
+	switch t := t.(type) {
+	case *types.Named:
+		if excluded[t] {
+			return t
+		}
+		switch t.Underlying().(type) {
+		case *types.Struct, *types.Interface:
+			return nil
+		}
+		if visiting[t] {
+			return nil
+		}
+		visiting[t] = true
+		return excludedInType(t.Underlying(), excluded, visiting)
+	case *types.Slice:
+		return excludedInType(t.Elem(), excluded, visiting)
+	case *types.Pointer:
+		return excludedInType(t.Elem(), excluded, visiting)
+	}
+	return nil
+}
+
+// interfaceMethods returns the exported methods of the interface named
+func interfaceMethods(named *types.Named) []*types.Func {
+	iface := named.Underlying().(*types.Interface)
+	methods := []*types.Func{}
+	for i := 0; i < iface.NumMethods(); i++ {
+		if meth := iface.Method(i); meth.Exported() {
+			methods = append(methods, meth)
+		}
+	}
+	return methods
+}
+
+// reservedTagName returns the name given by the json or veil key of tag if it is reserved, or an empty string
+func reservedTagName(tag string) string {
+	name := ""
+	for _, key := range []string{"json", "veil"} {
+		// the veil key takes precedence over the json key
+		if tagName := strings.Split(reflect.StructTag(tag).Get(key), ",")[0]; tagName != "" && tagName != "-" {
+			name = tagName
+		}
+	}
+	if reservedTagNames[name] {
+		return name
+	}
+	return ""
+}
+
+func excludedReason(named *types.Named) string {
+	return "refers to excluded type " + named.Obj().Name()
+}
@@ -0,0 +1,143 @@
+package cgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type Digest [4]byte
+
+type Box[T any] struct{ V T }
+
+type Shape struct {
+	Name   string
+	Meta   map[string]string
+	Corner [2]int
+}
+
+func (s *Shape) Area() float64 { return 0 }
+func (s *Shape) Digest() Digest { return Digest{} }
+
+type Summer interface {
+	Sum(b [2]int) int
+}
+
+func Hash(b []byte) Digest { return Digest{} }
+func Ident[T any](v T) T { return v }
+func Add(s Summer) int { return 0 }
+`)
+	names := symbolNames(pkg)
+	assert.NotContains(t, names, "veil_github_com_foo_recursive_Hash")
+	assert.NotContains(t, names, "veil_github_com_foo_recursive_Ident")
+	assert.NotContains(t, names, "veil_github_com_foo_recursive_Box")
+	assert.Contains(t, names, "veil_github_com_foo_recursive_Add", "Summer is passed as an opaque handle")
+	assert.Empty(t, pkg.Interfaces())
+
+	symbols := map[string]*Symbol{}
+	for _, symbol := range pkg.Report() {
+		symbols[symbol.Name] = symbol
+	}
+	assert.Len(t, symbols, 7)
+
+	assert.True(t, symbols["Add"].IsBound())
+	assert.Equal(t, STATUS_SKIPPED, symbols["Digest"].Status)
+	assert.Equal(t, STATUS_SKIPPED, symbols["Box"].Status)
+	assert.Equal(t, STATUS_SKIPPED, symbols["Ident"].Status)
+	assert.Equal(t, []string{"result 0: named arrays such as recursive.Digest are not supported"},
+		symbols["Hash"].Reasons)
+
+	shape := symbols["Shape"]
+	assert.Equal(t, SYMBOL_KIND_STRUCT, shape.Kind)
+	assert.Equal(t, STATUS_PARTIAL, shape.Status)
+	assert.Equal(t, []string{
+		"field Meta: opaque values such as map[string]string have no field accessors",
+		"field Corner: arrays such as [2]int are not supported",
+		"method Digest: result 0: named arrays such as recursive.Digest are not supported",
+	}, shape.Reasons)
+
+	assert.Equal(t, SYMBOL_KIND_INTERFACE, symbols["Summer"].Kind)
+	assert.Equal(t, STATUS_PARTIAL, symbols["Summer"].Status)
+}
+
+func TestReportAliases(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type Shape struct{ Name string }
+type Strings = []string
+type Figure = Shape
+
+func Names() Strings { return nil }
+`)
+	assert.Contains(t, symbolNames(pkg), "veil_github_com_foo_recursive_Names")
+
+	symbols := map[string]*Symbol{}
+	for _, symbol := range pkg.Report() {
+		symbols[symbol.Name] = symbol
+	}
+	assert.True(t, symbols["Names"].IsBound())
+	assert.True(t, symbols["Shape"].IsBound())
+	assert.Equal(t, STATUS_SKIPPED, symbols["Strings"].Status)
+	assert.Equal(t, []string{"type aliases such as Strings = []string are not bound"}, symbols["Strings"].Reasons)
+	assert.Equal(t, []string{"type aliases such as Figure = recursive.Shape are not bound"}, symbols["Figure"].Reasons)
+}
+
+func TestReportExcludedTypes(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+type World struct{ Name string }
+
+type Hello struct {
+	Greeting string
+	World    World
+}
+
+func (h *Hello) Planet() *World { return nil }
+
+type Finder interface {
+	Find(name string) *World
+}
+
+func MaybeWorld(name string) *World { return nil }
+func NewHello() *Hello { return nil }
+func Lookup(f Finder) string { return "" }
+`)
+	if err := pkg.Configure(&Config{Exclude: []string{"World"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	symbols := map[string]*Symbol{}
+	for _, symbol := range pkg.Report() {
+		symbols[symbol.Name] = symbol
+	}
+	assert.Equal(t, STATUS_EXCLUDED, symbols["World"].Status)
+	assert.Equal(t, STATUS_SKIPPED, symbols["MaybeWorld"].Status)
+	assert.Equal(t, []string{"refers to excluded type World"}, symbols["MaybeWorld"].Reasons)
+	assert.Equal(t, []string{"method Find: refers to excluded type World"}, symbols["Finder"].Reasons)
+	assert.Equal(t, []string{"refers to excluded type Finder"}, symbols["Lookup"].Reasons)
+	assert.Equal(t, STATUS_PARTIAL, symbols["Hello"].Status)
+	assert.Equal(t, []string{
+		"field World: refers to excluded type World",
+		"method Planet: refers to excluded type World",
+	}, symbols["Hello"].Reasons)
+	assert.True(t, symbols["NewHello"].IsBound())
+}
+
+func TestReportReservedTagNames(t *testing.T) {
+	ReserveTagNames("class")
+	pkg := buildTestPackage(t, "package recursive\n"+
+		"type Account struct {\n"+
+		"\tID   string `json:\"id\"`\n"+
+		"\tTier string `json:\"class\"`\n"+
+		"\tKind string `json:\"class\" veil:\"kind\"`\n"+
+		"}\n")
+
+	symbols := map[string]*Symbol{}
+	for _, symbol := range pkg.Report() {
+		symbols[symbol.Name] = symbol
+	}
+	assert.True(t, symbols["Account"].IsBound(), "fields with reserved tag names are bound under other names")
+	assert.Equal(t, []string{
+		"field Tier: its tag name class is reserved, so properties named after tags call it class_",
+	}, symbols["Account"].Reasons)
+}
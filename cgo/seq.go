@@ -0,0 +1,415 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	SEQ_STRUCT_NAME     = "cgo_seq"
+	PULL_FUNC_NAME      = "cgo_pull"
+	SEQ_STOP_FUNC_NAME  = "cgo_seq_stop"
+	SEQ_ITER_PKG_PATH   = "iter"
+	SEQ_NEXT_METHOD_SUF = "_next"
+)
+
+/*
+iter.Seq and iter.Seq2 results are pulled lazily by the host language. A returned sequence is converted into a
+pull iterator with iter.Pull behind a handle, and each instantiation of iter.Seq gets a next function which
+returns whether a value was pulled followed by the converted values.
+
+	//export seq_of_int_next
+	func seq_of_int_next(self unsafe.Pointer) (ok bool, r1 int) {
+		var values []interface{}
+		values, ok = (*cgo_seq)(cgo_get_ref(cgo_get_uuid_from_ptr(self))).next()
+		if ok {
+			v0, _ := values[0].(int)
+			r1 = v0
+		}
+		return
+	}
+*/
+
+// Seq wraps an instantiation of iter.Seq or iter.Seq2
+type Seq struct {
+	named *types.Named
+}
+
+// NewSeq wraps an instantiation of iter.Seq or iter.Seq2
+func NewSeq(named *types.Named) *Seq {
+	return &Seq{named: named}
+}
+
+// IsSeq returns true if t is an instantiation of iter.Seq or iter.Seq2
+func IsSeq(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != SEQ_ITER_PKG_PATH {
+		return false
+	}
+	return named.Obj().Name() == "Seq" || named.Obj().Name() == "Seq2"
+}
+
+// IsPullable returns true if t is an iter.Seq or iter.Seq2 whose values can all be represented in the host
+// language. Other sequences are passed as opaque handles.
+func IsPullable(t types.Type) bool {
+	if !IsSeq(t) {
+		return false
+	}
+	for _, elem := range NewSeq(t.(*types.Named)).Elems() {
+		if IsOpaque(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// Elems returns the types of the values yielded by the sequence
+func (s Seq) Elems() []types.Type {
+	args := s.named.TypeArgs()
+	elems := make([]types.Type, args.Len())
+	for i := range elems {
+		elems[i] = args.At(i)
+	}
+	return elems
+}
+
+// Underlying returns the iter.Seq type
+func (s Seq) Underlying() types.Type {
+	return s.named
+}
+
+func (s Seq) String() string {
+	return s.named.String()
+}
+
+// CName returns the name of the sequence, such as seq_of_int or seq2_of_string_and_error
+func (s Seq) CName() string {
+	replacer := strings.NewReplacer("[]", "slice_of_", ".", "_")
+	names := []string{}
+	for _, elem := range s.Elems() {
+		names = append(names, replacer.Replace(elementName(elem)))
+	}
+	return strings.ToLower(s.named.Obj().Name()) + "_of_" + strings.Join(names, "_and_")
+}
+
+// NextMethodName returns the name of the function pulling the next values of the sequence
+func (s Seq) NextMethodName() string {
+	return s.CName() + SEQ_NEXT_METHOD_SUF
+}
+
+func (s Seq) ExportName() string {
+	return s.CName()
+}
+
+func (s Seq) IsExportable() bool {
+	return IsPullable(s.named)
+}
+
+// ToAst returns the go/ast representation of the next function of the sequence
+func (s Seq) ToAst() []ast.Decl {
+	return []ast.Decl{s.NextAst()}
+}
+
+// NextAst produces the function which pulls the next values of the sequence
+func (s Seq) NextAst() ast.Decl {
+	functionName := s.NextMethodName()
+	ok := NewIdent("ok")
+	values := NewIdent("values")
+
+	results := []*ast.Field{{Names: []*ast.Ident{ok}, Type: NewIdent("bool")}}
+	converts := []ast.Stmt{}
+	for i, elem := range s.Elems() {
+		value := NewIdent("v" + strconv.Itoa(i))
+		result := NewIdent("r" + strconv.Itoa(i+1))
+		results = append(results, &ast.Field{Names: []*ast.Ident{result}, Type: TypeToArgumentTypeExpr(elem)})
+		converts = append(converts,
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{value, NewIdent("_")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.TypeAssertExpr{
+					X:    &ast.IndexExpr{X: values, Index: &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(i)}},
+					Type: TypeExpression(elem),
+				}},
+			},
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{result},
+				Tok: token.ASSIGN,
+				Rhs: []ast.Expr{CastOut(elem, value)},
+			})
+	}
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params:  InstanceMethodParams(),
+			Results: &ast.FieldList{List: results},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				DeclareVar(values, &ast.ArrayType{Elt: NewIdent("interface{}")}),
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{values, ok},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: seqFromHandle(NewIdent("self")), Sel: NewIdent("next")},
+					}},
+				},
+				&ast.IfStmt{
+					Cond: ok,
+					Body: &ast.BlockStmt{List: converts},
+				},
+				Return(),
+			},
+		},
+	}
+}
+
+// PullCall converts a sequence into a handle to a pull iterator
+func PullCall(seq ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  NewIdent(PULL_FUNC_NAME),
+		Args: []ast.Expr{seq},
+	}
+}
+
+func seqFromHandle(self ast.Expr) ast.Expr {
+	return CastUnsafePtrOfTypeUuid(DeRef(NewIdent(SEQ_STRUCT_NAME)), self)
+}
+
+// SeqRuntime produces the declarations shared by all sequences. They are only needed by packages returning
+// sequences, since they require the iter package.
+//
+//	type cgo_seq struct {
+//		next func() ([]interface{}, bool)
+//		stop func()
+//	}
+//
+//	func cgo_pull(seq interface{}) unsafe.Pointer {
+//		v := reflect.ValueOf(seq)
+//		if !v.IsValid() || v.IsNil() {
+//			return nil
+//		}
+//		values := func(yield func([]interface{}) bool) {
+//			v.Call([]reflect.Value{reflect.MakeFunc(v.Type().In(0), func(args []reflect.Value) []reflect.Value {
+//				items := make([]interface{}, len(args))
+//				for i, arg := range args {
+//					items[i] = arg.Interface()
+//				}
+//				return []reflect.Value{reflect.ValueOf(yield(items))}
+//			})})
+//		}
+//		next, stop := iter.Pull[[]interface{}](values)
+//		s := &cgo_seq{next: next, stop: stop}
+//		runtime.SetFinalizer(s, func(s *cgo_seq) {
+//			s.stop()
+//		})
+//		return C.CBytes(cgo_incref(unsafe.Pointer(s)).Bytes())
+//	}
+//
+//	//export cgo_seq_stop
+//	func cgo_seq_stop(self unsafe.Pointer) {
+//		(*cgo_seq)(cgo_get_ref(cgo_get_uuid_from_ptr(self))).stop()
+//	}
+func SeqRuntime() []ast.Decl {
+	return []ast.Decl{seqStruct(), pull(), seqStop()}
+}
+
+func seqStruct() ast.Decl {
+	items := &ast.ArrayType{Elt: NewIdent("interface{}")}
+	return &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: NewIdent(SEQ_STRUCT_NAME),
+				Type: &ast.StructType{
+					Fields: &ast.FieldList{
+						List: []*ast.Field{
+							{
+								Names: []*ast.Ident{NewIdent("next")},
+								Type: &ast.FuncType{
+									Params:  &ast.FieldList{},
+									Results: &ast.FieldList{List: []*ast.Field{{Type: items}, {Type: NewIdent("bool")}}},
+								},
+							},
+							{
+								Names: []*ast.Ident{NewIdent("stop")},
+								Type:  &ast.FuncType{Params: &ast.FieldList{}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func pull() ast.Decl {
+	seq, v, values, yield := NewIdent("seq"), NewIdent("v"), NewIdent("values"), NewIdent("yield")
+	args, items, i, arg := NewIdent("args"), NewIdent("items"), NewIdent("i"), NewIdent("arg")
+	next, stop, s := NewIdent("next"), NewIdent("stop"), NewIdent("s")
+	interfaces := &ast.ArrayType{Elt: NewIdent("interface{}")}
+	reflectValues := &ast.ArrayType{Elt: reflectValue}
+	seqPtr := DeRef(NewIdent(SEQ_STRUCT_NAME))
+
+	yieldType := &ast.FuncType{
+		Params:  &ast.FieldList{List: []*ast.Field{{Type: interfaces}}},
+		Results: &ast.FieldList{List: []*ast.Field{{Type: NewIdent("bool")}}},
+	}
+
+	makeFunc := &ast.CallExpr{
+		Fun: &ast.SelectorExpr{X: NewIdent("reflect"), Sel: NewIdent("MakeFunc")},
+		Args: []ast.Expr{
+			&ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: methodCall(v, "Type"), Sel: NewIdent("In")},
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "0"}},
+			},
+			&ast.FuncLit{
+				Type: &ast.FuncType{
+					Params:  &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{args}, Type: reflectValues}}},
+					Results: &ast.FieldList{List: []*ast.Field{{Type: reflectValues}}},
+				},
+				Body: &ast.BlockStmt{
+					List: []ast.Stmt{
+						&ast.AssignStmt{
+							Lhs: []ast.Expr{items},
+							Tok: token.DEFINE,
+							Rhs: []ast.Expr{&ast.CallExpr{
+								Fun:  NewIdent("make"),
+								Args: []ast.Expr{interfaces, &ast.CallExpr{Fun: NewIdent("len"), Args: []ast.Expr{args}}},
+							}},
+						},
+						&ast.RangeStmt{
+							Key:   i,
+							Value: arg,
+							Tok:   token.DEFINE,
+							X:     args,
+							Body: &ast.BlockStmt{
+								List: []ast.Stmt{
+									&ast.AssignStmt{
+										Lhs: []ast.Expr{&ast.IndexExpr{X: items, Index: i}},
+										Tok: token.ASSIGN,
+										Rhs: []ast.Expr{methodCall(arg, "Interface")},
+									},
+								},
+							},
+						},
+						Return(&ast.CompositeLit{
+							Type: reflectValues,
+							Elts: []ast.Expr{reflectValueOf(&ast.CallExpr{Fun: yield, Args: []ast.Expr{items}})},
+						}),
+					},
+				},
+			},
+		},
+	}
+
+	return &ast.FuncDecl{
+		Name: NewIdent(PULL_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{seq}, Type: NewIdent("interface{}")}}},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: unsafePointer}}},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{v},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{reflectValueOf(seq)},
+				},
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{
+						X:  &ast.UnaryExpr{Op: token.NOT, X: methodCall(v, "IsValid")},
+						Op: token.LOR,
+						Y:  methodCall(v, "IsNil"),
+					},
+					Body: &ast.BlockStmt{List: []ast.Stmt{Return(NewIdent("nil"))}},
+				},
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{values},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.FuncLit{
+						Type: &ast.FuncType{
+							Params: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{yield}, Type: yieldType}}},
+						},
+						Body: &ast.BlockStmt{
+							List: []ast.Stmt{
+								&ast.ExprStmt{X: &ast.CallExpr{
+									Fun: &ast.SelectorExpr{X: v, Sel: NewIdent("Call")},
+									Args: []ast.Expr{&ast.CompositeLit{
+										Type: reflectValues,
+										Elts: []ast.Expr{makeFunc},
+									}},
+								}},
+							},
+						},
+					}},
+				},
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{next, stop},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun: &ast.IndexExpr{
+							X:     &ast.SelectorExpr{X: NewIdent("iter"), Sel: NewIdent("Pull")},
+							Index: interfaces,
+						},
+						Args: []ast.Expr{values},
+					}},
+				},
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{s},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.UnaryExpr{
+						Op: token.AND,
+						X: &ast.CompositeLit{
+							Type: NewIdent(SEQ_STRUCT_NAME),
+							Elts: []ast.Expr{
+								&ast.KeyValueExpr{Key: NewIdent("next"), Value: next},
+								&ast.KeyValueExpr{Key: NewIdent("stop"), Value: stop},
+							},
+						},
+					}},
+				},
+				&ast.ExprStmt{X: &ast.CallExpr{
+					Fun: &ast.SelectorExpr{X: NewIdent("runtime"), Sel: NewIdent("SetFinalizer")},
+					Args: []ast.Expr{
+						s,
+						&ast.FuncLit{
+							Type: &ast.FuncType{
+								Params: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{s}, Type: seqPtr}}},
+							},
+							Body: &ast.BlockStmt{
+								List: []ast.Stmt{&ast.ExprStmt{X: methodCall(s, "stop")}},
+							},
+						},
+					},
+				}},
+				Return(UuidToCBytes(IncrementRefCall(ToUnsafePointer(s)))),
+			},
+		},
+	}
+}
+
+func seqStop() ast.Decl {
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(SEQ_STOP_FUNC_NAME),
+		},
+		Name: NewIdent(SEQ_STOP_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ExprStmt{X: &ast.CallExpr{
+					Fun: &ast.SelectorExpr{X: seqFromHandle(NewIdent("self")), Sel: NewIdent("stop")},
+				}},
+			},
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package cgo
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSeqs(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+import "iter"
+
+type Node struct {
+	Name string
+}
+
+func Names() iter.Seq[string] { return nil }
+func Nodes() iter.Seq2[int, *Node] { return nil }
+func Channels() iter.Seq[chan int] { return nil }
+`)
+	names := symbolNames(pkg)
+	assert.Contains(t, names, "seq_of_string")
+	assert.Contains(t, names, "seq2_of_int_and_pointer_to_veil_github_com_foo_recursive_Node")
+	assert.Len(t, pkg.Seqs(), 2)
+	assert.Len(t, pkg.Structs(), 1)
+
+	channels := pkg.pkg.Scope().Lookup("Channels").Type().(*types.Signature).Results().At(0).Type()
+	assert.True(t, IsSeq(channels))
+	assert.False(t, IsPullable(channels))
+	assert.True(t, IsOpaque(channels))
+}
@@ -31,9 +31,11 @@ func (s Slice) String() string {
 
 // ToAst returns the go/ast representation of the CGo wrapper of the Slice type
 func (s Slice) ToAst() []ast.Decl {
-	return []ast.Decl{
+	decls := []ast.Decl{
 		s.NewAst(),
 		s.StringAst(),
+		s.CopyAst(),
+		s.DeepCopyAst(),
 		s.ItemAst(),
 		s.ItemSetAst(),
 		s.ItemAppendAst(),
@@ -41,6 +43,20 @@ func (s Slice) ToAst() []ast.Decl {
 		s.LenAst(),
 		s.ItemInsertAst(),
 	}
+	if HasValueSemantics(s.elem) {
+		decls = append(decls, s.ItemRefAst())
+	}
+	return decls
+}
+
+// CopyAst produces a function which returns a handle to a copy of the slice with its own backing array
+func (s Slice) CopyAst() ast.Decl {
+	return CopyAst(s.CGoName()+"_copy", s.GoTypeExpr(), SliceCopy(s.GoTypeExpr()))
+}
+
+// DeepCopyAst produces a function which returns a handle to a deep copy of the slice and its elements
+func (s Slice) DeepCopyAst() ast.Decl {
+	return CopyAst(s.CGoName()+"_deepcopy", s.GoTypeExpr(), DeepCopy(s.GoTypeExpr()))
 }
 
 func (s Slice) ExportName() string {
@@ -48,7 +64,7 @@ func (s Slice) ExportName() string {
 }
 
 func (s Slice) IsExportable() bool {
-	return true
+	return UnsupportedReason(s.elem) == ""
 }
 
 func (s Slice) MethodName() string {
@@ -102,6 +118,20 @@ func (s Slice) CGoName() string {
 	return "slice_of_" + s.MethodName()
 }
 
+// Symbols returns the names of the functions wrapping the slice keyed by what they do, such as item_set
+func (s Slice) Symbols() map[string]string {
+	ops := []string{"new", "str", "copy", "deepcopy", "item", "item_set", "item_append", "item_del", "len",
+		"item_insert"}
+	if HasValueSemantics(s.elem) {
+		ops = append(ops, "item_ref")
+	}
+	symbols := map[string]string{}
+	for _, op := range ops {
+		symbols[op] = s.CGoName() + "_" + op
+	}
+	return symbols
+}
+
 // NewAst produces the []ast.Decl to construct a slice type and increment it's reference count
 func (s Slice) NewAst() ast.Decl {
 	functionName := s.CGoName() + "_new"
@@ -153,6 +183,8 @@ func (s Slice) LenAst() ast.Decl {
 	return funcDecl
 }
 
+// ItemAst returns a function declaration which returns a copy of an item in the slice. Handles to struct
+// elements never point into the backing array, so they stay valid when the slice is reallocated.
 func (s Slice) ItemAst() ast.Decl {
 	functionName := s.CGoName() + "_item"
 	selfIdent := NewIdent("self")
@@ -160,11 +192,12 @@ func (s Slice) ItemAst() ast.Decl {
 	indexTypeIdent := NewIdent("int")
 	goTypeIdent := s.GoTypeExpr()
 	itemsIdent := NewIdent("items")
+	itemIdent := NewIdent("item")
 
 	castExpression := CastUnsafePtrOfTypeUuid(DeRef(goTypeIdent), selfIdent)
 	itemField := &ast.Field{
 		Type:  TypeToArgumentTypeExpr(s.Elem()),
-		Names: []*ast.Ident{NewIdent("item")},
+		Names: []*ast.Ident{NewIdent("r0")},
 	}
 
 	funcDecl := &ast.FuncDecl{
@@ -197,14 +230,24 @@ func (s Slice) ItemAst() ast.Decl {
 					},
 					Tok: token.DEFINE,
 				},
-				Return(CastOut(s.elem, &ast.IndexExpr{
-					X: &ast.ParenExpr{
-						X: &ast.StarExpr{
-							X: itemsIdent,
+				// item := (*items)[i]
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{
+						itemIdent,
+					},
+					Rhs: []ast.Expr{
+						&ast.IndexExpr{
+							X: &ast.ParenExpr{
+								X: &ast.StarExpr{
+									X: itemsIdent,
+								},
+							},
+							Index: indexIdent,
 						},
 					},
-					Index: indexIdent,
-				})),
+					Tok: token.DEFINE,
+				},
+				Return(CastOut(s.elem, itemIdent)),
 			},
 		},
 	}
@@ -212,6 +255,35 @@ func (s Slice) ItemAst() ast.Decl {
 	return funcDecl
 }
 
+// ItemRefAst returns a function declaration which returns a handle to an item in the current backing array
+// of the slice. The host language resolves the handle on each access so it stays live as the slice grows.
+func (s Slice) ItemRefAst() ast.Decl {
+	functionName := s.CGoName() + "_item_ref"
+	selfIdent := NewIdent("self")
+	indexIdent := NewIdent("i")
+	itemsIdent := NewIdent("items")
+
+	castExpression := CastUnsafePtrOfTypeUuid(DeRef(s.GoTypeExpr()), selfIdent)
+	params := InstanceMethodParams(&ast.Field{
+		Names: []*ast.Ident{indexIdent},
+		Type:  NewIdent("int"),
+	})
+	setup := []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{itemsIdent},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{castExpression},
+		},
+	}
+	target := &ast.IndexExpr{
+		X: &ast.ParenExpr{
+			X: DeRef(itemsIdent),
+		},
+		Index: indexIdent,
+	}
+	return RefAst(functionName, params, setup, target)
+}
+
 func (s Slice) ItemSetAst() ast.Decl {
 	functionName := s.CGoName() + "_item_set"
 	selfIdent := NewIdent("self")
@@ -0,0 +1,545 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+const (
+	STREAM_STRUCT_NAME            = "cgo_stream"
+	NEW_STREAM_FUNC_NAME          = "cgo_new_stream"
+	STREAM_FUNCS_VAR_NAME         = "cgo_stream_funcs"
+	REGISTER_STREAMS_FUNC_NAME    = "cgo_register_streams"
+	STREAM_C_FUNC_PTR_NAME        = "StreamFunc"
+	STREAM_C_CALLBACK_FUNC_NAME   = "CallStreamFunc"
+	STREAM_ERR_BUF_SIZE           = "256"
+	STREAM_READ_METHOD_NAME       = "Read"
+	STREAM_WRITE_METHOD_NAME      = "Write"
+	STREAM_CLOSE_METHOD_NAME      = "Close"
+	STREAM_IO_PKG_PATH            = "io"
+	STREAM_FROM_STREAM_METHOD_SUF = "_from_stream"
+)
+
+/*
+Interfaces made only of the io.Reader, io.Writer and io.Closer methods are streams. Host language file objects
+are adapted to any stream interface by a single Go type which calls back into the host language through three
+callbacks registered at load time, one each for read, write and close. The callbacks share one C signature:
+
+	typedef long long StreamFunc(void *handle, void *buf, long long n, char *err);
+
+They return the number of bytes read or written, or -1 with an error message written to err.
+
+Go values behind stream interfaces are read and written by the host language through a buffer it owns, so
+bytes are copied once rather than converted to a slice_of_byte.
+*/
+
+// streamMethodIndexes are the positions of the host language callbacks in cgo_stream_funcs
+var streamMethodIndexes = map[string]string{
+	STREAM_READ_METHOD_NAME:  "0",
+	STREAM_WRITE_METHOD_NAME: "1",
+	STREAM_CLOSE_METHOD_NAME: "2",
+}
+
+// StreamMethods returns the sorted names of the io.Reader, io.Writer and io.Closer methods of the interface, or
+// nil if the interface has any other method
+func (iface Interface) StreamMethods() []string {
+	underlying := iface.Interface()
+	if underlying.NumMethods() == 0 {
+		return nil
+	}
+
+	names := []string{}
+	for i := 0; i < underlying.NumMethods(); i++ {
+		meth := underlying.Method(i)
+		if !isStreamMethod(meth.Name(), meth.Type().(*types.Signature)) {
+			return nil
+		}
+		names = append(names, meth.Name())
+	}
+	return names
+}
+
+// IsStream returns true if the interface can be implemented by a host language file object
+func (iface Interface) IsStream() bool {
+	return len(iface.StreamMethods()) > 0
+}
+
+// HasStreamMethod returns true if the interface is a stream with the method named name
+func (iface Interface) HasStreamMethod(name string) bool {
+	for _, meth := range iface.StreamMethods() {
+		if meth == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isStreamMethod returns true if sig matches the signature of Read, Write or Close in the io package
+func isStreamMethod(name string, sig *types.Signature) bool {
+	params, results := sig.Params(), sig.Results()
+	switch name {
+	case STREAM_READ_METHOD_NAME, STREAM_WRITE_METHOD_NAME:
+		if params.Len() != 1 || results.Len() != 2 {
+			return false
+		}
+		slice, ok := params.At(0).Type().(*types.Slice)
+		if !ok || !types.Identical(slice.Elem(), types.Typ[types.Byte]) {
+			return false
+		}
+		return types.Identical(results.At(0).Type(), types.Typ[types.Int]) && isErrorType(results.At(1).Type())
+	case STREAM_CLOSE_METHOD_NAME:
+		return params.Len() == 0 && results.Len() == 1 && isErrorType(results.At(0).Type())
+	}
+	return false
+}
+
+func isErrorType(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+// StreamCDefs returns the C function pointer type and call used to read, write and close host language streams
+func StreamCDefs() (funcPtr string, call string) {
+	funcPtr = "//typedef long long " + STREAM_C_FUNC_PTR_NAME + "(void *handle, void *buf, long long n, char *err);"
+	call = "//static inline long long " + STREAM_C_CALLBACK_FUNC_NAME + "(void *handle, void *buf, long long n, " +
+		"char *err, " + STREAM_C_FUNC_PTR_NAME + " *fn){ return fn(handle, buf, n, err); }"
+	return funcPtr, call
+}
+
+// FromStreamMethodName returns the name of the function adapting a host language stream to the interface
+func (iface Interface) FromStreamMethodName() string {
+	return iface.CName() + STREAM_FROM_STREAM_METHOD_SUF
+}
+
+// StreamMethodName returns the name of the function calling the stream method named name through a host
+// language buffer, such as veil_io_Reader_stream_read
+func (iface Interface) StreamMethodName(name string) string {
+	return iface.CName() + "_stream_" + strings.ToLower(name)
+}
+
+// StreamAsts produces the functions adapting host language streams to the interface and reading and writing Go
+// values of the interface through host language buffers
+func (iface Interface) StreamAsts() []ast.Decl {
+	decls := []ast.Decl{iface.FromStreamAst()}
+	for _, name := range []string{STREAM_READ_METHOD_NAME, STREAM_WRITE_METHOD_NAME} {
+		if iface.HasStreamMethod(name) {
+			decls = append(decls, iface.StreamMethodAst(name))
+		}
+	}
+	return decls
+}
+
+// FromStreamAst produces the function which adapts a pinned host language stream to the interface
+//
+//	func veil_io_Reader_from_stream(handle unsafe.Pointer) unsafe.Pointer {
+//		var o veil_io.Reader
+//		o = cgo_new_stream(handle)
+//		return C.CBytes(cgo_incref(unsafe.Pointer(&o)).Bytes())
+//	}
+func (iface Interface) FromStreamAst() ast.Decl {
+	handleIdent := NewIdent("handle")
+	inits := func(localVar *ast.Ident) []ast.Stmt {
+		return []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{localVar},
+				Tok: token.ASSIGN,
+				Rhs: []ast.Expr{&ast.CallExpr{Fun: NewIdent(NEW_STREAM_FUNC_NAME), Args: []ast.Expr{handleIdent}}},
+			},
+		}
+	}
+
+	params := []*ast.Field{
+		{
+			Names: []*ast.Ident{handleIdent},
+			Type:  unsafePointer,
+		},
+	}
+	return NewAstWithInitialization(iface.FromStreamMethodName(), TypeExpression(iface.named.Named), params, inits)
+}
+
+// StreamMethodAst produces the function which calls Read or Write on the Go value behind a handle with a host
+// language buffer. io.EOF is reported as a count of 0 without an error, as host language streams expect.
+//
+//	func veil_io_Reader_stream_read(self unsafe.Pointer, buf unsafe.Pointer, n int) (int, *C.char) {
+//		count, err := (*(*veil_io.Reader)(cgo_get_ref(cgo_get_uuid_from_ptr(self)))).Read(unsafe.Slice((*byte)(buf), n))
+//		if err != nil && err != io.EOF {
+//			return count, C.CString(err.Error())
+//		}
+//		return count, nil
+//	}
+func (iface Interface) StreamMethodAst(name string) ast.Decl {
+	functionName := iface.StreamMethodName(name)
+	buf, n, count, err := NewIdent("buf"), NewIdent("n"), NewIdent("count"), NewIdent("err")
+	value := &ast.ParenExpr{X: DeRef(CastUnsafePtrOfTypeUuid(DeRef(TypeExpression(iface.named.Named)), NewIdent("self")))}
+	bytes := &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: NewIdent("unsafe"), Sel: NewIdent("Slice")},
+		Args: []ast.Expr{CastUnsafePtr(DeRef(NewIdent("byte")), buf), n},
+	}
+
+	return &ast.FuncDecl{
+		Doc: &ast.CommentGroup{
+			List: ExportComments(functionName),
+		},
+		Name: NewIdent(functionName),
+		Type: &ast.FuncType{
+			Params: InstanceMethodParams(
+				&ast.Field{Names: []*ast.Ident{buf}, Type: unsafePointer},
+				&ast.Field{Names: []*ast.Ident{n}, Type: NewIdent("int")},
+			),
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: NewIdent("int")}, {Type: DeRef(cSelector("char"))}},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{count, err},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun:  &ast.SelectorExpr{X: value, Sel: NewIdent(name)},
+						Args: []ast.Expr{bytes},
+					}},
+				},
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{
+						X:  &ast.BinaryExpr{X: err, Op: token.NEQ, Y: NewIdent("nil")},
+						Op: token.LAND,
+						Y:  &ast.BinaryExpr{X: err, Op: token.NEQ, Y: ioEOF()},
+					},
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							Return(count, &ast.CallExpr{
+								Fun:  cSelector("CString"),
+								Args: []ast.Expr{methodCall(err, "Error")},
+							}),
+						},
+					},
+				},
+				Return(count, NewIdent("nil")),
+			},
+		},
+	}
+}
+
+func ioEOF() ast.Expr {
+	return &ast.SelectorExpr{X: NewIdent(STREAM_IO_PKG_PATH), Sel: NewIdent("EOF")}
+}
+
+// StreamRuntime produces the declarations shared by all stream interfaces. They are only needed by packages
+// exposing streams, since they require the io package.
+//
+//	var cgo_stream_funcs [3]unsafe.Pointer
+//
+//	//export cgo_register_streams
+//	func cgo_register_streams(readFn unsafe.Pointer, writeFn unsafe.Pointer, closeFn unsafe.Pointer) {
+//		cgo_stream_funcs = [3]unsafe.Pointer{readFn, writeFn, closeFn}
+//	}
+//
+//	type cgo_stream struct {
+//		handle unsafe.Pointer
+//	}
+//
+//	func cgo_new_stream(handle unsafe.Pointer) *cgo_stream {
+//		s := &cgo_stream{handle: handle}
+//		runtime.SetFinalizer(s, func(s *cgo_stream) {
+//			cgo_release(s.handle)
+//		})
+//		return s
+//	}
+//
+//	func (s *cgo_stream) call(fn int, p []byte) (int, error) {
+//		errBuf := C.calloc(1, 256)
+//		defer C.free(errBuf)
+//		var buf unsafe.Pointer
+//		if len(p) > 0 {
+//			buf = unsafe.Pointer(&p[0])
+//		}
+//		n := int(C.CallStreamFunc(s.handle, buf, C.longlong(len(p)), (*C.char)(errBuf), (*C.StreamFunc)(cgo_stream_funcs[fn])))
+//		if n < 0 {
+//			return 0, fmt.Errorf("%s", C.GoString((*C.char)(errBuf)))
+//		}
+//		return n, nil
+//	}
+//
+//	func (s *cgo_stream) Read(p []byte) (int, error) {
+//		if len(p) == 0 {
+//			return 0, nil
+//		}
+//		n, err := s.call(0, p)
+//		if err == nil && n == 0 {
+//			return 0, io.EOF
+//		}
+//		return n, err
+//	}
+//
+//	func (s *cgo_stream) Write(p []byte) (int, error) {
+//		return s.call(1, p)
+//	}
+//
+//	func (s *cgo_stream) Close() error {
+//		_, err := s.call(2, nil)
+//		return err
+//	}
+func StreamRuntime() []ast.Decl {
+	return []ast.Decl{
+		streamFuncsVar(),
+		registerStreams(),
+		streamStruct(),
+		newStream(),
+		streamCall(),
+		streamRead(),
+		streamWrite(),
+		streamClose(),
+	}
+}
+
+func streamFuncsArray() ast.Expr {
+	return &ast.ArrayType{Len: &ast.BasicLit{Kind: token.INT, Value: "3"}, Elt: unsafePointer}
+}
+
+func streamFuncsVar() ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names: []*ast.Ident{NewIdent(STREAM_FUNCS_VAR_NAME)},
+				Type:  streamFuncsArray(),
+			},
+		},
+	}
+}
+
+func registerStreams() ast.Decl {
+	fns := []ast.Expr{NewIdent("readFn"), NewIdent("writeFn"), NewIdent("closeFn")}
+	params := make([]*ast.Field, len(fns))
+	for i, fn := range fns {
+		params[i] = &ast.Field{Names: []*ast.Ident{fn.(*ast.Ident)}, Type: unsafePointer}
+	}
+
+	return &ast.FuncDecl{
+		Doc:  &ast.CommentGroup{List: ExportComments(REGISTER_STREAMS_FUNC_NAME)},
+		Name: NewIdent(REGISTER_STREAMS_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{List: params},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{NewIdent(STREAM_FUNCS_VAR_NAME)},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{&ast.CompositeLit{Type: streamFuncsArray(), Elts: fns}},
+				},
+			},
+		},
+	}
+}
+
+func streamStruct() ast.Decl {
+	return &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: NewIdent(STREAM_STRUCT_NAME),
+				Type: &ast.StructType{
+					Fields: &ast.FieldList{
+						List: []*ast.Field{{Names: []*ast.Ident{NewIdent("handle")}, Type: unsafePointer}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newStream() ast.Decl {
+	handle, s := NewIdent("handle"), NewIdent("s")
+	streamPtr := DeRef(NewIdent(STREAM_STRUCT_NAME))
+
+	return &ast.FuncDecl{
+		Name: NewIdent(NEW_STREAM_FUNC_NAME),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{handle}, Type: unsafePointer}}},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: streamPtr}}},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{s},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{Ref(&ast.CompositeLit{
+						Type: NewIdent(STREAM_STRUCT_NAME),
+						Elts: []ast.Expr{&ast.KeyValueExpr{Key: handle, Value: handle}},
+					})},
+				},
+				&ast.ExprStmt{X: &ast.CallExpr{
+					Fun: &ast.SelectorExpr{X: NewIdent("runtime"), Sel: NewIdent("SetFinalizer")},
+					Args: []ast.Expr{
+						s,
+						&ast.FuncLit{
+							Type: &ast.FuncType{
+								Params: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{s}, Type: streamPtr}}},
+							},
+							Body: &ast.BlockStmt{
+								List: []ast.Stmt{
+									&ast.ExprStmt{X: &ast.CallExpr{
+										Fun:  NewIdent(RELEASE_FUNC_NAME),
+										Args: []ast.Expr{&ast.SelectorExpr{X: s, Sel: handle}},
+									}},
+								},
+							},
+						},
+					},
+				}},
+				Return(s),
+			},
+		},
+	}
+}
+
+// streamMethod declares a method of cgo_stream with the results (int, error) or error
+func streamMethod(name string, params []*ast.Field, results []*ast.Field, body []ast.Stmt) ast.Decl {
+	return &ast.FuncDecl{
+		Recv: &ast.FieldList{
+			List: []*ast.Field{{Names: []*ast.Ident{NewIdent("s")}, Type: DeRef(NewIdent(STREAM_STRUCT_NAME))}},
+		},
+		Name: NewIdent(name),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{List: params},
+			Results: &ast.FieldList{List: results},
+		},
+		Body: &ast.BlockStmt{List: body},
+	}
+}
+
+func streamCountResults() []*ast.Field {
+	return []*ast.Field{{Type: NewIdent("int")}, {Type: NewIdent("error")}}
+}
+
+func bytesParam(p *ast.Ident) []*ast.Field {
+	return []*ast.Field{{Names: []*ast.Ident{p}, Type: &ast.ArrayType{Elt: NewIdent("byte")}}}
+}
+
+func cSelector(name string) ast.Expr {
+	return &ast.SelectorExpr{X: NewIdent("C"), Sel: NewIdent(name)}
+}
+
+func streamCall() ast.Decl {
+	fn, p, errBuf, buf, n := NewIdent("fn"), NewIdent("p"), NewIdent("errBuf"), NewIdent("buf"), NewIdent("n")
+	zero := &ast.BasicLit{Kind: token.INT, Value: "0"}
+	lenP := &ast.CallExpr{Fun: NewIdent("len"), Args: []ast.Expr{p}}
+	errMessage := CastUnsafePtr(DeRef(cSelector("char")), errBuf)
+
+	params := append([]*ast.Field{{Names: []*ast.Ident{fn}, Type: NewIdent("int")}}, bytesParam(p)...)
+	body := []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{errBuf},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun:  cSelector("calloc"),
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "1"}, &ast.BasicLit{Kind: token.INT, Value: STREAM_ERR_BUF_SIZE}},
+			}},
+		},
+		&ast.DeferStmt{Call: &ast.CallExpr{Fun: cSelector("free"), Args: []ast.Expr{errBuf}}},
+		DeclareVar(buf, unsafePointer),
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: lenP, Op: token.GTR, Y: zero},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{buf},
+						Tok: token.ASSIGN,
+						Rhs: []ast.Expr{ToUnsafePointer(Ref(&ast.IndexExpr{X: p, Index: zero}))},
+					},
+				},
+			},
+		},
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{n},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun: NewIdent("int"),
+				Args: []ast.Expr{&ast.CallExpr{
+					Fun: cSelector(STREAM_C_CALLBACK_FUNC_NAME),
+					Args: []ast.Expr{
+						&ast.SelectorExpr{X: NewIdent("s"), Sel: NewIdent("handle")},
+						buf,
+						&ast.CallExpr{Fun: cSelector("longlong"), Args: []ast.Expr{lenP}},
+						errMessage,
+						CastUnsafePtr(DeRef(cSelector(STREAM_C_FUNC_PTR_NAME)),
+							&ast.IndexExpr{X: NewIdent(STREAM_FUNCS_VAR_NAME), Index: fn}),
+					},
+				}},
+			}},
+		},
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: n, Op: token.LSS, Y: zero},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					Return(zero, &ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: NewIdent("fmt"), Sel: NewIdent("Errorf")},
+						Args: []ast.Expr{
+							&ast.BasicLit{Kind: token.STRING, Value: `"%s"`},
+							&ast.CallExpr{Fun: cSelector("GoString"), Args: []ast.Expr{errMessage}},
+						},
+					}),
+				},
+			},
+		},
+		Return(n, NewIdent("nil")),
+	}
+	return streamMethod("call", params, streamCountResults(), body)
+}
+
+// callStream calls the host language callback at index of cgo_stream_funcs: s.call(index, p)
+func callStream(index string, p ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: NewIdent("s"), Sel: NewIdent("call")},
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: index}, p},
+	}
+}
+
+func streamRead() ast.Decl {
+	p, n, err := NewIdent("p"), NewIdent("n"), NewIdent("err")
+	zero := &ast.BasicLit{Kind: token.INT, Value: "0"}
+	body := []ast.Stmt{
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: &ast.CallExpr{Fun: NewIdent("len"), Args: []ast.Expr{p}}, Op: token.EQL, Y: zero},
+			Body: &ast.BlockStmt{List: []ast.Stmt{Return(zero, NewIdent("nil"))}},
+		},
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{n, err},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{callStream(streamMethodIndexes[STREAM_READ_METHOD_NAME], p)},
+		},
+		// a host language stream reads no bytes at the end of the stream
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{
+				X:  &ast.BinaryExpr{X: err, Op: token.EQL, Y: NewIdent("nil")},
+				Op: token.LAND,
+				Y:  &ast.BinaryExpr{X: n, Op: token.EQL, Y: zero},
+			},
+			Body: &ast.BlockStmt{List: []ast.Stmt{Return(zero, ioEOF())}},
+		},
+		Return(n, err),
+	}
+	return streamMethod(STREAM_READ_METHOD_NAME, bytesParam(p), streamCountResults(), body)
+}
+
+func streamWrite() ast.Decl {
+	p := NewIdent("p")
+	body := []ast.Stmt{Return(callStream(streamMethodIndexes[STREAM_WRITE_METHOD_NAME], p))}
+	return streamMethod(STREAM_WRITE_METHOD_NAME, bytesParam(p), streamCountResults(), body)
+}
+
+func streamClose() ast.Decl {
+	err := NewIdent("err")
+	body := []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{NewIdent("_"), err},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{callStream(streamMethodIndexes[STREAM_CLOSE_METHOD_NAME], NewIdent("nil"))},
+		},
+		Return(err),
+	}
+	return streamMethod(STREAM_CLOSE_METHOD_NAME, nil, []*ast.Field{{Type: NewIdent("error")}}, body)
+}
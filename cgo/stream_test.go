@@ -0,0 +1,38 @@
+package cgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreams(t *testing.T) {
+	pkg := buildTestPackage(t, `package streams
+
+type ReadCloser interface {
+	Read(p []byte) (n int, err error)
+	Close() error
+}
+
+type Sink interface {
+	Write(p []byte) (n int, err error)
+	Flush() error
+}
+
+type Counter interface {
+	Read(p []byte) int
+}
+
+func Open() ReadCloser { return nil }
+func Drain(s Sink) {}
+func Count(c Counter) {}
+`)
+	streams := pkg.Streams()
+	if assert.Len(t, streams, 1) {
+		assert.Equal(t, "ReadCloser", streams[0].Name())
+		assert.Equal(t, []string{"Close", "Read"}, streams[0].StreamMethods())
+		assert.True(t, streams[0].HasStreamMethod(STREAM_CLOSE_METHOD_NAME))
+		assert.False(t, streams[0].HasStreamMethod(STREAM_WRITE_METHOD_NAME))
+		assert.Len(t, streams[0].StreamAsts(), 2)
+	}
+}
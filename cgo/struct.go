@@ -5,13 +5,38 @@ import (
 	"go/token"
 	"go/types"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+
+	"github.com/devigned/veil/core"
+)
+
+const (
+	DEFAULT_CONSTRUCTOR_PATTERN = `^New([A-Z]\w+)`
+	MAX_INIT_FIELDS             = 64
 )
 
 var (
-	constructorName = regexp.MustCompile(`^New([A-Z]\w+)`)
+	constructorName = regexp.MustCompile(DEFAULT_CONSTRUCTOR_PATTERN)
 )
 
+// SetConstructorPattern changes the rule used to match package functions to the structs they construct. The
+// first capture group of the pattern must match the struct name, optionally followed by a capitalized suffix
+// which names an alternative constructor. With the default pattern NewHello constructs Hello and
+// NewHelloFromFile is the alternative constructor NewFromFile of Hello.
+func SetConstructorPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return core.NewUserErrorF("invalid constructor pattern %q: %v", pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return core.NewUserErrorF("constructor pattern %q must capture the struct name", pattern)
+	}
+	constructorName = re
+	return nil
+}
+
 // Struct is a helpful facade over types.Named which is intended to only contain a struct
 type Struct struct {
 	*Named
@@ -31,7 +56,11 @@ func (s Struct) Struct() *types.Struct {
 
 // ToAst returns the go/ast representation of the CGo wrapper of the Array type
 func (s Struct) ToAst() []ast.Decl {
-	decls := []ast.Decl{s.NewAst(), s.StringAst()}
+	decls := []ast.Decl{s.NewAst(), s.NewWithFieldsAst(), s.StringAst(), s.CopyAst(), s.DeepCopyAst()}
+	if s.IsComparable() {
+		decls = append(decls, s.EqualAst(), s.HashAst())
+	}
+	decls = append(decls, s.JsonAsts()...)
 	decls = append(decls, s.FieldAccessorsAst()...)
 	decls = append(decls, s.MethodAsts()...)
 	return decls
@@ -43,14 +72,109 @@ func (s Struct) FieldAccessorsAst() []ast.Decl {
 		field := s.Struct().Field(i)
 		if ShouldGenerateField(field) {
 			accessors = append(accessors, s.Getter(field), s.Setter(field))
+			if HasValueSemantics(field.Type()) {
+				accessors = append(accessors, s.RefGetter(field))
+			}
 		}
 	}
 
 	return accessors
 }
 
+// InitFields returns the fields which can be set while constructing the struct. Fields beyond MAX_INIT_FIELDS
+// can only be set after construction.
+func (s Struct) InitFields() []*types.Var {
+	fields := []*types.Var{}
+	for i := 0; i < s.Struct().NumFields() && len(fields) < MAX_INIT_FIELDS; i++ {
+		field := s.Struct().Field(i)
+		if ShouldGenerateField(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// NewWithFieldsMethodName returns the name of the function constructing the struct from its fields
+func (s Struct) NewWithFieldsMethodName() string {
+	return s.CName() + "_new_with"
+}
+
+/*
+NewWithFieldsAst produces a function which constructs the struct and sets its fields in a single call. Each of
+the InitFields is passed as an argument and is only assigned when its bit is set in the mask, so unset fields
+keep their zero value.
+
+	func veil_pkg_Hello_new_with(mask uint64, Foo int, World unsafe.Pointer) unsafe.Pointer {
+		var o pkg.Hello
+		if mask&(1<<0) != 0 {
+			val := Foo
+			o.Foo = val
+		}
+		if mask&(1<<1) != 0 {
+			val := *(*pkg.World)(cgo_get_ref(cgo_get_uuid_from_ptr(World)))
+			o.World = val
+		}
+		return C.CBytes(cgo_incref(unsafe.Pointer(&o)).Bytes())
+	}
+*/
+func (s Struct) NewWithFieldsAst() ast.Decl {
+	mask := NewIdent("mask")
+	fields := s.InitFields()
+	params := []*ast.Field{{Names: []*ast.Ident{mask}, Type: NewIdent("uint64")}}
+	for _, field := range fields {
+		params = append(params, UnsafePtrOrBasic(field, field.Type()))
+	}
+
+	inits := func(o *ast.Ident) []ast.Stmt {
+		stmts := []ast.Stmt{}
+		for idx, field := range fields {
+			bit := &ast.ParenExpr{
+				X: &ast.BinaryExpr{
+					X:  &ast.BasicLit{Kind: token.INT, Value: "1"},
+					Op: token.SHL,
+					Y:  &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(idx)},
+				},
+			}
+			target := &ast.SelectorExpr{X: o, Sel: NewIdent(field.Name())}
+			stmts = append(stmts, &ast.IfStmt{
+				Cond: &ast.BinaryExpr{
+					X:  &ast.BinaryExpr{X: mask, Op: token.AND, Y: bit},
+					Op: token.NEQ,
+					Y:  &ast.BasicLit{Kind: token.INT, Value: "0"},
+				},
+				Body: &ast.BlockStmt{List: assignField(target, field, NewIdent(field.Name()))},
+			})
+		}
+		return stmts
+	}
+
+	return NewAstWithInitialization(s.NewWithFieldsMethodName(), s.CTypeName(), params, inits)
+}
+
+// CopyAst produces a function which returns a handle to a Go value copy of the struct
+func (s Struct) CopyAst() ast.Decl {
+	return CopyAst(s.CopyMethodName(), s.CTypeName(), ValueCopy)
+}
+
+// DeepCopyAst produces a function which returns a handle to a deep copy of the struct
+func (s Struct) DeepCopyAst() ast.Decl {
+	return CopyAst(s.DeepCopyMethodName(), s.CTypeName(), DeepCopy(s.CTypeName()))
+}
+
+// RefGetter produces a function which returns a live handle to the field, so changes made through the handle
+// are made to the field rather than to a copy
+func (s Struct) RefGetter(field *types.Var) ast.Decl {
+	functionName := s.RefGetterName(field)
+	castExpression := CastUnsafePtrOfTypeUuid(DeRef(s.CTypeName()), NewIdent("self"))
+	target := &ast.SelectorExpr{
+		X:   castExpression,
+		Sel: NewIdent(field.Name()),
+	}
+	return RefAst(functionName, InstanceMethodParams(), []ast.Stmt{}, target)
+}
+
 func (s Struct) Getter(field *types.Var) ast.Decl {
-	functionName := s.FieldName(field) + "_get"
+	functionName := s.GetterName(field)
 	selfIdent := NewIdent("self")
 	localVarIdent := NewIdent("value")
 	fieldIdent := NewIdent(field.Name())
@@ -90,22 +214,17 @@ func (s Struct) Getter(field *types.Var) ast.Decl {
 }
 
 func (s Struct) Setter(field *types.Var) ast.Decl {
-	functionName := s.FieldName(field) + "_set"
+	functionName := s.SetterName(field)
 	selfIdent := NewIdent("self")
 	localVarIdent := NewIdent("value")
-	transformedLocalVarIdent := NewIdent("val")
 	fieldIdent := NewIdent(field.Name())
 	castExpression := CastUnsafePtrOfTypeUuid(DeRef(s.CTypeName()), selfIdent)
 	typedField := UnsafePtrOrBasic(field, field.Type())
 	typedField.Names = []*ast.Ident{localVarIdent}
 	params := InstanceMethodParams(typedField)
-	firstAssignmentCastRhs := CastExpr(field.Type(), localVarIdent)
-	secondAssignment := ast.Expr(transformedLocalVarIdent)
-
-	if isStringPointer(field.Type()) {
-		strPtrCast := CastExpr(field.Type(), localVarIdent).(*ast.UnaryExpr)
-		firstAssignmentCastRhs = strPtrCast.X
-		secondAssignment = Ref(transformedLocalVarIdent)
+	target := &ast.SelectorExpr{
+		X:   castExpression,
+		Sel: fieldIdent,
 	}
 
 	funcDecl := &ast.FuncDecl{
@@ -117,43 +236,133 @@ func (s Struct) Setter(field *types.Var) ast.Decl {
 			Params: params,
 		},
 		Body: &ast.BlockStmt{
-			List: []ast.Stmt{
-				&ast.AssignStmt{
-					Lhs: []ast.Expr{transformedLocalVarIdent},
-					Tok: token.DEFINE,
-					Rhs: []ast.Expr{firstAssignmentCastRhs},
-				},
-				&ast.AssignStmt{
-					Lhs: []ast.Expr{
-						&ast.SelectorExpr{
-							X:   castExpression,
-							Sel: fieldIdent,
-						},
-					},
-					Tok: token.ASSIGN,
-					Rhs: []ast.Expr{secondAssignment},
-				},
-			},
+			List: assignField(target, field, localVarIdent),
 		},
 	}
 
 	return funcDecl
 }
 
+// assignField produces the statements which convert value from its C representation and assign it to target
+func assignField(target ast.Expr, field *types.Var, value *ast.Ident) []ast.Stmt {
+	transformedLocalVarIdent := NewIdent("val")
+	firstAssignmentCastRhs := CastExpr(field.Type(), value)
+	secondAssignment := ast.Expr(transformedLocalVarIdent)
+
+	if isStringPointer(field.Type()) {
+		strPtrCast := CastExpr(field.Type(), value).(*ast.UnaryExpr)
+		firstAssignmentCastRhs = strPtrCast.X
+		secondAssignment = Ref(transformedLocalVarIdent)
+	}
+
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{transformedLocalVarIdent},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{firstAssignmentCastRhs},
+		},
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{target},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{secondAssignment},
+		},
+	}
+}
+
 func (s Struct) FieldName(field *types.Var) string {
 	return s.CName() + "_" + field.Name()
 }
 
+// GetterName returns the name of the function getting the field
+func (s Struct) GetterName(field *types.Var) string {
+	return s.FieldName(field) + "_get"
+}
+
+// SetterName returns the name of the function setting the field
+func (s Struct) SetterName(field *types.Var) string {
+	return s.FieldName(field) + "_set"
+}
+
+// RefGetterName returns the name of the function returning a live handle to the field
+func (s Struct) RefGetterName(field *types.Var) string {
+	return s.FieldName(field) + "_ref"
+}
+
+// CopyMethodName returns the name of the function copying the struct
+func (s Struct) CopyMethodName() string {
+	return s.CName() + "_copy"
+}
+
+// DeepCopyMethodName returns the name of the function deep copying the struct
+func (s Struct) DeepCopyMethodName() string {
+	return s.CName() + "_deepcopy"
+}
+
+// Symbols returns the names of the functions wrapping the struct, other than its field accessors and methods,
+// keyed by what they do
+func (s Struct) Symbols() map[string]string {
+	symbols := map[string]string{
+		"new":       s.NewMethodName(),
+		"new_with":  s.NewWithFieldsMethodName(),
+		"str":       s.ToStringMethodName(),
+		"copy":      s.CopyMethodName(),
+		"deepcopy":  s.DeepCopyMethodName(),
+		"to_json":   s.ToJsonMethodName(),
+		"from_json": s.FromJsonMethodName(),
+	}
+	if s.IsComparable() {
+		symbols["equal"] = s.EqualMethodName()
+		symbols["hash"] = s.HashMethodName()
+	}
+	return symbols
+}
+
+// IsConstructor returns true if f is matched by the constructor pattern and returns the struct, or a pointer to
+// it, optionally followed by an error
 func (s Struct) IsConstructor(f *Func) bool {
-	matches := constructorName.FindStringSubmatch(f.Name())
-	if len(matches) > 1 && strings.HasPrefix(matches[1], s.Obj().Name()) {
-		return true
+	if f.BoundRecv != nil {
+		return false
 	}
-	return false
+	if _, ok := s.constructorSuffix(f); !ok {
+		return false
+	}
+
+	results := f.Signature().Results()
+	switch results.Len() {
+	case 1:
+	case 2:
+		if !ImplementsError(results.At(1).Type()) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	t := results.At(0).Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj() == s.Obj()
 }
 
+// ConstructorName returns the name of the constructor, which is "New" for the primary constructor followed by the
+// suffix after the struct name for alternative constructors, such as NewPtr for NewHelloPtr
 func (s Struct) ConstructorName(f *Func) string {
-	return strings.Replace(f.Name(), s.Obj().Name(), "", 1)
+	suffix, _ := s.constructorSuffix(f)
+	return "New" + suffix
+}
+
+func (s Struct) constructorSuffix(f *Func) (string, bool) {
+	matches := constructorName.FindStringSubmatch(f.Name())
+	if len(matches) < 2 || !strings.HasPrefix(matches[1], s.Obj().Name()) {
+		return "", false
+	}
+	suffix := strings.TrimPrefix(matches[1], s.Obj().Name())
+	if suffix != "" && !unicode.IsUpper([]rune(suffix)[0]) {
+		return "", false
+	}
+	return suffix, true
 }
 
 func isStringPointer(t types.Type) bool {
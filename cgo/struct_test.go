@@ -0,0 +1,98 @@
+package cgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const constructorsSrc = `package recursive
+type Hello struct {
+	Foo int
+}
+
+type HelloWorld struct {
+	Bar string
+}
+
+func NewHello(foo int) Hello { return Hello{Foo: foo} }
+func NewHelloFromString(s string) (*Hello, error) { return nil, nil }
+func NewHelloWorld() *HelloWorld { return nil }
+func NewHellos() []Hello { return nil }
+func MakeHello() *Hello { return nil }
+`
+
+func funcNamed(pkg *Package, name string) *Func {
+	for _, f := range pkg.Funcs() {
+		if f.Name() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func structNamed(pkg *Package, name string) *Struct {
+	for _, s := range pkg.Structs() {
+		if s.Obj().Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestIsConstructor(t *testing.T) {
+	pkg := buildTestPackage(t, constructorsSrc)
+	hello := structNamed(pkg, "Hello")
+	helloWorld := structNamed(pkg, "HelloWorld")
+
+	assert.True(t, hello.IsConstructor(funcNamed(pkg, "NewHello")))
+	assert.True(t, hello.IsConstructor(funcNamed(pkg, "NewHelloFromString")))
+	assert.False(t, hello.IsConstructor(funcNamed(pkg, "NewHelloWorld")))
+	assert.True(t, helloWorld.IsConstructor(funcNamed(pkg, "NewHelloWorld")))
+	assert.False(t, hello.IsConstructor(funcNamed(pkg, "NewHellos")))
+	assert.False(t, hello.IsConstructor(funcNamed(pkg, "MakeHello")))
+
+	assert.Equal(t, "New", hello.ConstructorName(funcNamed(pkg, "NewHello")))
+	assert.Equal(t, "NewFromString", hello.ConstructorName(funcNamed(pkg, "NewHelloFromString")))
+}
+
+func TestSetConstructorPattern(t *testing.T) {
+	defer SetConstructorPattern(DEFAULT_CONSTRUCTOR_PATTERN)
+	pkg := buildTestPackage(t, constructorsSrc)
+	hello := structNamed(pkg, "Hello")
+
+	assert.Error(t, SetConstructorPattern(`^Make`))
+	assert.Error(t, SetConstructorPattern(`^Make(`))
+	assert.NoError(t, SetConstructorPattern(`^Make(\w+)`))
+	assert.True(t, hello.IsConstructor(funcNamed(pkg, "MakeHello")))
+	assert.False(t, hello.IsConstructor(funcNamed(pkg, "NewHello")))
+}
+
+func TestUndecodableReason(t *testing.T) {
+	pkg := buildTestPackage(t, `package recursive
+import "time"
+
+type Scorer interface{ Score() int }
+
+type Node struct {
+	Name     string
+	Parent   *Node
+	Children []*Node
+	Seen     time.Time
+	Cache    map[string]int `+"`json:\"-\"`"+`
+	secret   string `+"`json:\"-\"`"+`
+}
+
+type Game struct {
+	Referee Scorer
+}
+
+type Greeter struct {
+	name string
+}
+`)
+	assert.Equal(t, "", structNamed(pkg, "Node").UndecodableReason())
+	assert.Equal(t, "field Referee: interfaces such as recursive.Scorer can't be decoded",
+		structNamed(pkg, "Game").UndecodableReason())
+	assert.Equal(t, "field name: unexported fields aren't encoded", structNamed(pkg, "Greeter").UndecodableReason())
+}
@@ -0,0 +1,74 @@
+package cgo
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// UnsupportedReason returns why values of t can't be passed across the bridge, or "" if they can. Types which are
+// passed as opaque handles, such as maps, channels and funcs, are supported, since the handle doesn't need to
+// represent the value. Interfaces and structs are checked through their own methods and fields.
+func UnsupportedReason(t types.Type) string {
+	return unsupportedReason(t, map[*types.Named]bool{})
+}
+
+func unsupportedReason(t types.Type, visiting map[*types.Named]bool) string {
+	if strings.Contains(t.String(), "/vendor/") || IsPullable(t) {
+		return ""
+	}
+
+	switch typ := t.(type) {
+	case *types.Array:
+		return fmt.Sprintf("arrays such as %s are not supported", typeString(typ))
+	case *types.Struct:
+		return fmt.Sprintf("anonymous structs such as %s are not supported", typeString(typ))
+	case *types.TypeParam:
+		return fmt.Sprintf("type parameters such as %s are not supported", typeString(typ))
+	case *types.Pointer:
+		return unsupportedReason(typ.Elem(), visiting)
+	case *types.Slice:
+		return unsupportedReason(typ.Elem(), visiting)
+	case *types.Named:
+		if visiting[typ] || (typ.Obj().Pkg() != nil && !typ.Obj().Exported()) {
+			return ""
+		}
+		visiting[typ] = true
+
+		switch typ.Underlying().(type) {
+		case *types.Chan, *types.Map, *types.Signature:
+			// passed as opaque handles, even when they are instantiated from generic types such as iter.Seq
+			return ""
+		}
+		if typ.TypeParams().Len() > 0 || typ.TypeArgs().Len() > 0 {
+			return fmt.Sprintf("generic types such as %s are not supported", typeString(typ))
+		}
+		switch underlying := typ.Underlying().(type) {
+		case *types.Array:
+			return fmt.Sprintf("named arrays such as %s are not supported", typeString(typ))
+		case *types.Pointer:
+			return fmt.Sprintf("named pointers such as %s are not supported", typeString(typ))
+		case *types.Slice:
+			return unsupportedReason(underlying.Elem(), visiting)
+		}
+	}
+	return ""
+}
+
+// typeString returns t qualified by package names rather than paths, as it would be written in Go
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+}
+
+// varLabel names the i-th var of a signature in a reason, such as "param b" or "result 0"
+func varLabel(sig *types.Signature, i int) string {
+	if i < sig.Params().Len() {
+		if name := sig.Params().At(i).Name(); name != "" && name != "_" {
+			return "param " + name
+		}
+		return fmt.Sprintf("param %d", i)
+	}
+	return fmt.Sprintf("result %d", i-sig.Params().Len())
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/devigned/veil/cgo"
+	"github.com/devigned/veil/core"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	CONFIG_INCLUDE = "include"
+	CONFIG_EXCLUDE = "exclude"
+	CONFIG_RENAME  = "rename"
+	CONFIG_OUT     = "out"
+)
+
+var (
+	includeSymbols []string
+	excludeSymbols []string
+	renameSymbols  []string
+	outParams      []string
+)
+
+// addSymbolFlags adds the flags selecting, renaming and shaping the symbols which are bound to cmd
+func addSymbolFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(
+		&includeSymbols,
+		CONFIG_INCLUDE,
+		[]string{},
+		"Patterns of the funcs and types to bind, such as Shape* (default is every symbol), replacing the include "+
+			"list of the config file")
+
+	cmd.Flags().StringSliceVar(
+		&excludeSymbols,
+		CONFIG_EXCLUDE,
+		[]string{},
+		"Patterns of the symbols not to bind, such as Debug* or Shape.Internal, replacing the exclude list of "+
+			"the config file")
+
+	cmd.Flags().StringSliceVar(
+		&renameSymbols,
+		CONFIG_RENAME,
+		[]string{},
+		"Names of symbols in the bindings as Symbol=name, such as --rename Hello=greet or "+
+			"--rename Shape.Points=vertices")
+
+	cmd.Flags().StringSliceVar(
+		&outParams,
+		CONFIG_OUT,
+		[]string{},
+		"Out-params as Func=param, such as --out Parse=result, which bindings create and return rather than "+
+			"take as arguments")
+}
+
+// applyConfig sets the flags of cmd named by names which aren't on the command line from the keys of the config
+// file with the same names, so the command line takes precedence
+func applyConfig(cmd *cobra.Command, names ...string) error {
+	for _, name := range names {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed || !viper.IsSet(name) {
+			continue
+		}
+		value := fmt.Sprint(viper.Get(name))
+		if list, ok := viper.Get(name).([]interface{}); ok {
+			values := make([]string, len(list))
+			for i, v := range list {
+				values[i] = fmt.Sprint(v)
+			}
+			value = strings.Join(values, ",")
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return core.NewUserErrorF("Invalid %s %q in the config file: %v", name, value, err)
+		}
+	}
+	return nil
+}
+
+// symbolConfig builds the Config of the symbols to bind from the config file and the command line. --include and
+// --exclude replace the lists of the config file, while --rename and --out replace the entries of the same symbols.
+func symbolConfig(cmd *cobra.Command) (*cgo.Config, error) {
+	config := &cgo.Config{
+		Include: viper.GetStringSlice(CONFIG_INCLUDE),
+		Exclude: viper.GetStringSlice(CONFIG_EXCLUDE),
+		Rename:  map[string]string{},
+		Out:     map[string][]string{},
+	}
+	if cmd.Flags().Changed(CONFIG_INCLUDE) {
+		config.Include = includeSymbols
+	}
+	if cmd.Flags().Changed(CONFIG_EXCLUDE) {
+		config.Exclude = excludeSymbols
+	}
+
+	for _, source := range [][]string{viper.GetStringSlice(CONFIG_RENAME), renameSymbols} {
+		for _, rename := range source {
+			symbol, name, err := splitSymbolValue(CONFIG_RENAME, rename)
+			if err != nil {
+				return nil, err
+			}
+			config.Rename[symbol] = name
+		}
+	}
+
+	for _, source := range [][]string{viper.GetStringSlice(CONFIG_OUT), outParams} {
+		// out-params of the same func accumulate within the config file or the command line
+		out := map[string][]string{}
+		for _, outParam := range source {
+			symbol, param, err := splitSymbolValue(CONFIG_OUT, outParam)
+			if err != nil {
+				return nil, err
+			}
+			out[symbol] = append(out[symbol], param)
+		}
+		for symbol, params := range out {
+			config.Out[symbol] = params
+		}
+	}
+	return config, nil
+}
+
+// splitSymbolValue splits a setting of a symbol such as Hello=greet
+func splitSymbolValue(name, setting string) (string, string, error) {
+	parts := strings.SplitN(setting, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", core.NewUserErrorF("%s %q must be formatted as Symbol=value", name, setting)
+	}
+	return parts[0], parts[1], nil
+}
@@ -4,13 +4,19 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 
+	"github.com/devigned/veil/bind"
+	"github.com/devigned/veil/bind/python"
+	"github.com/devigned/veil/cgo"
 	"github.com/devigned/veil/core"
 	"github.com/marstr/collection"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-const defaultTarget = "py3"
+const defaultTarget = python.TARGET_NAME
 
 var (
 	generateCmd = &cobra.Command{
@@ -19,6 +25,9 @@ var (
 		Long: `Give a set of target language / platforms generate bindings
 for a Golang package in each of the targets`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfig(cmd, "pkg", "outdir", "name", "targets", "tags"); err != nil {
+				return err
+			}
 			done := make(chan struct{})
 			allGood := collection.AsEnumerable(targets, pkgPath, outDir).Enumerate(done).
 				All(func(a interface{}) bool {
@@ -34,45 +43,175 @@ for a Golang package in each of the targets`,
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return NewGenerator(pkgPath, outDir, libName, targets).Execute()
+			if err := cgo.SetConstructorPattern(constructorPattern); err != nil {
+				return err
+			}
+			options, err := targetOptions(cmd, targets, targetOpts)
+			if err != nil {
+				return err
+			}
+			config, err := symbolConfig(cmd)
+			if err != nil {
+				return err
+			}
+			generator := NewGenerator(pkgPath, outDir, libName, targets, buildTags)
+			generator.Overlay = overlayPath
+			generator.Options = options
+			generator.Strict = strict
+			generator.Config = config
+			return generator.Execute()
 		},
 	}
-	supportedTargets = []string{defaultTarget, "java"}
 
 	targets []string
 	pkgPath string
 	outDir  string
 	libName string
+
+	buildTags   []string
+	overlayPath string
+
+	constructorPattern string
+
+	strict bool
+
+	targetOpts []string
+
+	pyAccessorMethods bool
+	pyStructTags      bool
+	pyNaming          string
 )
 
 func init() {
-	cwd, _ := os.Getwd()
 	rootCmd.AddCommand(generateCmd)
 	generateCmd.Flags().StringSliceVarP(
 		&targets,
 		"targets",
 		"t",
 		[]string{defaultTarget},
-		fmt.Sprintf("Targets for binding generation %s", supportedTargets))
+		fmt.Sprintf("Targets for binding generation %s, or the names of %s<target> plugins on the PATH",
+			bind.Targets(), bind.PLUGIN_PREFIX))
+
+	addBindFlags(generateCmd)
+	addSymbolFlags(generateCmd)
+}
 
-	generateCmd.Flags().StringVarP(
+// addBindFlags adds the flags loading, binding and building a package to cmd
+func addBindFlags(cmd *cobra.Command) {
+	cwd, _ := os.Getwd()
+	cmd.Flags().StringVarP(
 		&pkgPath,
 		"pkg",
 		"p",
 		"",
 		"Path to Golang package to generate bindings (example github.com/devigned/veil/_examples/helloworld)")
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&outDir,
 		"outdir",
 		"o",
 		path.Join(cwd, "output"),
 		"Output directory to drop generated binding")
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&libName,
 		"name",
 		"n",
 		"libgen",
 		"Name of the CGo library to be generated in the output directory")
+
+	cmd.Flags().StringSliceVar(
+		&buildTags,
+		"tags",
+		[]string{},
+		"Build tags to consider satisfied while loading the package, in addition to those set by GOFLAGS")
+
+	cmd.Flags().StringVar(
+		&overlayPath,
+		"overlay",
+		"",
+		"JSON file replacing files of the package like the -overlay flag of the go command, such as "+
+			"{\"Replace\": {\"/src/pkg/file.go\": \"/tmp/unsaved.go\"}}, to preview bindings of unsaved edits")
+
+	cmd.Flags().StringSliceVar(
+		&targetOpts,
+		"opt",
+		[]string{},
+		"Options of targets as target:name=value, such as --opt py3:struct-tags=true or --opt ruby:package=mylib. "+
+			"Options are also read from the options.<target> maps of the config file")
+
+	cmd.Flags().StringVar(
+		&constructorPattern,
+		"constructor-pattern",
+		cgo.DEFAULT_CONSTRUCTOR_PATTERN,
+		"Regular expression matching constructor functions, the first group must match the struct name "+
+			"optionally followed by the name of an alternative constructor")
+
+	cmd.Flags().BoolVar(
+		&strict,
+		"strict",
+		false,
+		"Fail if an exported symbol can't be fully bound, rather than skipping it with a warning")
+
+	cmd.Flags().BoolVar(
+		&pyAccessorMethods,
+		"py-accessor-methods",
+		true,
+		"Keep the method forms of Go getters and setters which are mapped to Python properties, "+
+			"short for --opt py3:accessor-methods")
+
+	cmd.Flags().BoolVar(
+		&pyStructTags,
+		"py-struct-tags",
+		false,
+		"Name Python properties after the json and veil tags of struct fields, and honor the readonly and skip "+
+			"options of veil tags, short for --opt py3:struct-tags")
+
+	cmd.Flags().StringVar(
+		&pyNaming,
+		"py-naming",
+		python.NAMING_SNAKE_CASE,
+		fmt.Sprintf("Name Python functions, params and properties after the %s of their Go names, or keep the "+
+			"names with %s, short for --opt py3:naming", python.NAMING_SNAKE_CASE, python.NAMING_GO))
+}
+
+// targetOptions builds the options of each target from the options.<target> maps of the config file, then the
+// --opt flags, then the flags dedicated to an option, so the most specific setting wins
+func targetOptions(cmd *cobra.Command, targets, opts []string) (map[string]*core.Options, error) {
+	options := map[string]*core.Options{}
+	for _, target := range targets {
+		options[target] = core.NewOptions(target)
+		for name, value := range viper.GetStringMapString("options." + target) {
+			options[target].Set(name, value)
+		}
+	}
+
+	for _, opt := range opts {
+		parts := strings.SplitN(opt, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, core.NewUserErrorF("Option %q must be formatted as target:name=value", opt)
+		}
+		nameValue := strings.SplitN(parts[1], "=", 2)
+		if len(nameValue) != 2 || nameValue[0] == "" {
+			return nil, core.NewUserErrorF("Option %q must be formatted as target:name=value", opt)
+		}
+		o, ok := options[parts[0]]
+		if !ok {
+			return nil, core.NewUserErrorF("Option %q is for target %s, which is not in --targets", opt, parts[0])
+		}
+		o.Set(nameValue[0], nameValue[1])
+	}
+
+	if pyOptions, ok := options[python.TARGET_NAME]; ok {
+		if cmd.Flags().Changed("py-accessor-methods") {
+			pyOptions.Set(python.OPTION_ACCESSOR_METHODS, strconv.FormatBool(pyAccessorMethods))
+		}
+		if cmd.Flags().Changed("py-struct-tags") {
+			pyOptions.Set(python.OPTION_STRUCT_TAGS, strconv.FormatBool(pyStructTags))
+		}
+		if cmd.Flags().Changed("py-naming") {
+			pyOptions.Set(python.OPTION_NAMING, pyNaming)
+		}
+	}
+	return options, nil
 }
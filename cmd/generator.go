@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/devigned/veil/bind"
 	"github.com/devigned/veil/cgo"
@@ -12,19 +15,31 @@ import (
 // Generator generates libraries in other languages by creating bindings in those languages
 // to a Golang project
 type Generator struct {
-	PkgPath string
-	OutDir  string
-	Targets []string
-	LibName string
+	PkgPath   string
+	OutDir    string
+	Targets   []string
+	LibName   string
+	BuildTags []string
+	// Overlay is the path of a JSON file in the format of the -overlay flag of the go command, which replaces
+	// files of the package with the contents of other files
+	Overlay string
+	// Options are the options of each target, such as struct-tags=true for py3
+	Options map[string]*core.Options
+	// Strict fails on symbols which can't be fully bound, rather than warning about them
+	Strict bool
+	// Config selects, renames and shapes the symbols which are bound, taking precedence over the directives in
+	// the source of the package
+	Config *cgo.Config
 }
 
 // NewGenerator constructs a new Generator instance
-func NewGenerator(pkgPath string, outDir string, libName string, targets []string) *Generator {
+func NewGenerator(pkgPath string, outDir string, libName string, targets []string, buildTags []string) *Generator {
 	return &Generator{
-		PkgPath: pkgPath,
-		OutDir:  outDir,
-		Targets: targets,
-		LibName: libName,
+		PkgPath:   pkgPath,
+		OutDir:    outDir,
+		Targets:   targets,
+		LibName:   libName,
+		BuildTags: buildTags,
 	}
 }
 
@@ -49,18 +64,101 @@ func (g Generator) Execute() error {
 		return err
 	}
 
-	pkg, err := cgo.NewPackage(g.PkgPath, outDir)
+	pkg, err := g.LoadPackage()
 	if err != nil {
 		return err
 	}
+	if err := g.CheckSymbols(pkg); err != nil {
+		return err
+	}
 
-	for _, target := range g.Targets {
-		binder, err := bind.NewBinder(pkg, target)
-		if err != nil {
-			return err
+	return bind.Bind(pkg, g.Targets, g.Options, outDir, g.LibName)
+}
+
+// LoadPackage loads the package from the working directory, so it is found in the module being worked on, with
+// the build tags and overlay of the generator
+func (g Generator) LoadPackage() (*cgo.Package, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, core.NewSystemErrorF("Could not determine the working directory: %v", err)
+	}
+
+	var buildFlags []string
+	if len(g.BuildTags) > 0 {
+		buildFlags = append(buildFlags, "-tags="+strings.Join(g.BuildTags, ","))
+	}
+
+	overlay, err := readOverlay(g.Overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := cgo.NewPackageWithOverlay(g.PkgPath, workDir, overlay, buildFlags...)
+	if err != nil {
+		return nil, err
+	}
+	if g.Config != nil {
+		if err := pkg.Configure(g.Config); err != nil {
+			return nil, err
 		}
-		binder.Bind(outDir, g.LibName)
 	}
+	return pkg, nil
+}
 
+// CheckSymbols warns about each exported symbol of pkg which is skipped or only partially bound. In strict mode
+// they fail the generation instead.
+func (g Generator) CheckSymbols(pkg *cgo.Package) error {
+	unbound := []string{}
+	for _, symbol := range pkg.Report() {
+		if symbol.IsUnsupported() {
+			unbound = append(unbound, fmt.Sprintf("%s %s %s: %s", symbol.Status, symbol.Kind, symbol.Name,
+				strings.Join(symbol.Reasons, "; ")))
+		}
+	}
+	if len(unbound) == 0 {
+		return nil
+	}
+
+	if g.Strict {
+		return core.NewUserErrorF("%d symbols of [%s] can't be fully bound:\n%s", len(unbound), g.PkgPath,
+			strings.Join(unbound, "\n"))
+	}
+	for _, msg := range unbound {
+		fmt.Fprintln(os.Stderr, "warning:", msg)
+	}
 	return nil
 }
+
+// readOverlay reads the contents of the replacement files listed by an overlay file such as
+// {"Replace": {"/src/pkg/file.go": "/tmp/unsaved.go"}}, keyed by the absolute paths of the replaced files
+func readOverlay(overlayPath string) (map[string][]byte, error) {
+	if overlayPath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(overlayPath)
+	if err != nil {
+		return nil, core.NewUserErrorF("Could not read overlay [%s]: %v", overlayPath, err)
+	}
+
+	var spec struct {
+		Replace map[string]string
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, core.NewUserErrorF("Could not parse overlay [%s]: %v", overlayPath, err)
+	}
+
+	overlay := map[string][]byte{}
+	for path, replacement := range spec.Replace {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, core.NewSystemErrorF("Could not infer absolute path to [%s]: %v", path, err)
+		}
+		contents, err := os.ReadFile(replacement)
+		if err != nil {
+			return nil, core.NewUserErrorF("Could not read overlay replacement [%s]: %v", replacement, err)
+		}
+		overlay[absPath] = contents
+	}
+	return overlay, nil
+}
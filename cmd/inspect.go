@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/devigned/veil/cgo"
+	"github.com/devigned/veil/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectCmd = &cobra.Command{
+		Use:   "inspect",
+		Short: "List the exported symbols of a Golang package and how they are bound",
+		Long: `List every exported function and type of a Golang package with its status,
+bound, partial or skipped, and the reasons the parts which can't be bound are
+left out of the binding`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfig(cmd, "pkg", "tags"); err != nil {
+				return err
+			}
+			if pkgPath == "" {
+				return core.NewUserError("Please provide --pkg")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cgo.SetConstructorPattern(constructorPattern); err != nil {
+				return err
+			}
+			config, err := symbolConfig(cmd)
+			if err != nil {
+				return err
+			}
+			generator := NewGenerator(pkgPath, "", "", nil, buildTags)
+			generator.Overlay = overlayPath
+			generator.Config = config
+			pkg, err := generator.LoadPackage()
+			if err != nil {
+				return err
+			}
+
+			symbols := pkg.Report()
+			if inspectJSON {
+				data, err := json.MarshalIndent(symbols, "", "  ")
+				if err != nil {
+					return core.NewSystemErrorF("Could not encode the symbols of [%s]: %v", pkgPath, err)
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "STATUS\tKIND\tSYMBOL\tREASON")
+			for _, symbol := range symbols {
+				if len(symbol.Reasons) == 0 {
+					fmt.Fprintf(w, "%s\t%s\t%s\t\n", symbol.Status, symbol.Kind, symbol.Name)
+				}
+				for i, reason := range symbol.Reasons {
+					if i == 0 {
+						fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", symbol.Status, symbol.Kind, symbol.Name, reason)
+					} else {
+						// further reasons are listed under the first one
+						fmt.Fprintf(w, "\t\t\t%s\n", reason)
+					}
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	inspectJSON bool
+)
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().StringVarP(
+		&pkgPath,
+		"pkg",
+		"p",
+		"",
+		"Path to Golang package to inspect (example github.com/devigned/veil/_examples/helloworld)")
+
+	inspectCmd.Flags().BoolVar(
+		&inspectJSON,
+		"json",
+		false,
+		"Print the symbols as JSON rather than a table")
+
+	inspectCmd.Flags().StringSliceVar(
+		&buildTags,
+		"tags",
+		[]string{},
+		"Build tags to consider satisfied while loading the package, in addition to those set by GOFLAGS")
+
+	inspectCmd.Flags().StringVar(
+		&overlayPath,
+		"overlay",
+		"",
+		"JSON file replacing files of the package like the -overlay flag of the go command")
+
+	inspectCmd.Flags().StringVar(
+		&constructorPattern,
+		"constructor-pattern",
+		cgo.DEFAULT_CONSTRUCTOR_PATTERN,
+		"Regular expression matching constructor functions, the first group must match the struct name "+
+			"optionally followed by the name of an alternative constructor")
+
+	addSymbolFlags(inspectCmd)
+}
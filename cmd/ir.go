@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/devigned/veil/cgo"
+	"github.com/devigned/veil/core"
+	"github.com/devigned/veil/ir"
+	"github.com/spf13/cobra"
+)
+
+var (
+	irCmd = &cobra.Command{
+		Use:   "ir",
+		Short: "Print the IR of the binding for a Golang package as JSON",
+		Long: `Describe the functions, structs, fields, interfaces, slice wrappers, C symbols
+and marshaling rules of the CGo wrapper of a Golang package as versioned JSON,
+which bindings for other languages can be generated from`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfig(cmd, "pkg", "tags"); err != nil {
+				return err
+			}
+			if pkgPath == "" {
+				return core.NewUserError("Please provide --pkg")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cgo.SetConstructorPattern(constructorPattern); err != nil {
+				return err
+			}
+			config, err := symbolConfig(cmd)
+			if err != nil {
+				return err
+			}
+			generator := NewGenerator(pkgPath, "", "", nil, buildTags)
+			generator.Overlay = overlayPath
+			generator.Config = config
+			generator.Strict = strict
+			pkg, err := generator.LoadPackage()
+			if err != nil {
+				return err
+			}
+			if err := generator.CheckSymbols(pkg); err != nil {
+				return err
+			}
+
+			data, err := ir.Marshal(ir.NewPackage(pkg))
+			if err != nil {
+				return err
+			}
+			if irOutput == "" {
+				fmt.Println(string(data))
+				return nil
+			}
+			if err := os.WriteFile(irOutput, append(data, '\n'), 0644); err != nil {
+				return core.NewUserErrorF("Could not write the IR to %s: %v", irOutput, err)
+			}
+			return nil
+		},
+	}
+
+	irOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(irCmd)
+	irCmd.Flags().StringVarP(
+		&pkgPath,
+		"pkg",
+		"p",
+		"",
+		"Path to Golang package to describe (example github.com/devigned/veil/_examples/helloworld)")
+
+	irCmd.Flags().StringVarP(
+		&irOutput,
+		"output",
+		"o",
+		"",
+		"File to write the IR to rather than standard output")
+
+	irCmd.Flags().StringSliceVar(
+		&buildTags,
+		"tags",
+		[]string{},
+		"Build tags to consider satisfied while loading the package, in addition to those set by GOFLAGS")
+
+	irCmd.Flags().StringVar(
+		&overlayPath,
+		"overlay",
+		"",
+		"JSON file replacing files of the package like the -overlay flag of the go command")
+
+	irCmd.Flags().BoolVar(
+		&strict,
+		"strict",
+		false,
+		"Fail if an exported symbol can't be fully bound, rather than skipping it with a warning")
+
+	irCmd.Flags().StringVar(
+		&constructorPattern,
+		"constructor-pattern",
+		cgo.DEFAULT_CONSTRUCTOR_PATTERN,
+		"Regular expression matching constructor functions, the first group must match the struct name "+
+			"optionally followed by the name of an alternative constructor")
+
+	addSymbolFlags(irCmd)
+}
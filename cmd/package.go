@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/devigned/veil/bind"
+	"github.com/devigned/veil/bind/python"
+	"github.com/devigned/veil/cgo"
+	"github.com/devigned/veil/core"
+	"github.com/spf13/cobra"
+)
+
+const (
+	PACKAGE_BUILD_DIR  = "build"
+	PACKAGE_DIST_DIR   = "dist"
+	DEFAULT_PY_VERSION = "0.0.0"
+)
+
+var (
+	packageCmd = &cobra.Command{
+		Use:   "package",
+		Short: "Build an installable Python wheel of the binding for a Golang package",
+		Long: `Generate the Python binding for a Golang package, lay it out as a Python
+project with a pyproject.toml and the shared library as package data, and
+build a wheel of the project for the platform of the build machine`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfig(cmd, "pkg", "outdir", "name", "tags"); err != nil {
+				return err
+			}
+			if pkgPath == "" || outDir == "" {
+				return core.NewUserError("Please provide --outdir and --pkg")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cgo.SetConstructorPattern(constructorPattern); err != nil {
+				return err
+			}
+			targets := []string{python.TARGET_NAME}
+			options, err := targetOptions(cmd, targets, targetOpts)
+			if err != nil {
+				return err
+			}
+			config, err := symbolConfig(cmd)
+			if err != nil {
+				return err
+			}
+			generator := NewGenerator(pkgPath, outDir, libName, targets, buildTags)
+			generator.Overlay = overlayPath
+			generator.Options = options
+			generator.Strict = strict
+			generator.Config = config
+			wheelPath, err := generator.Package(pyPackage, pyVersion)
+			if err != nil {
+				return err
+			}
+			fmt.Println(wheelPath)
+			return nil
+		},
+	}
+
+	pyPackage string
+	pyVersion string
+
+	// gitDescribePattern matches the versions git describes commits after the latest tag as, such as
+	// v1.2.0-3-gabc1234
+	gitDescribePattern = regexp.MustCompile(`^(.+)-(\d+)-g([0-9a-f]+)$`)
+)
+
+func init() {
+	rootCmd.AddCommand(packageCmd)
+	addBindFlags(packageCmd)
+	addSymbolFlags(packageCmd)
+
+	packageCmd.Flags().StringVar(
+		&pyPackage,
+		"py-package",
+		"",
+		"Name of the Python project and the package it is imported as (default is the name of the Go package)")
+
+	packageCmd.Flags().StringVar(
+		&pyVersion,
+		"py-version",
+		"",
+		"PEP 440 version of the Python project (default is the version of the Go module, or the latest v* tag of "+
+			"its repository for the main module)")
+}
+
+// Package generates the Python binding into the build directory of the output directory, lays it out as the
+// Python project name and builds the wheel of the project into the dist directory, returning the path of the
+// wheel. The project is named after the Go package and versioned after its module, unless name or version are
+// given.
+func (g Generator) Package(name, version string) (string, error) {
+	outDir, err := createOutputDir(g.OutDir)
+	if err != nil {
+		return "", err
+	}
+
+	pkg, err := g.LoadPackage()
+	if err != nil {
+		return "", err
+	}
+	if err := g.CheckSymbols(pkg); err != nil {
+		return "", err
+	}
+
+	if name == "" {
+		name = pkg.Name()
+	}
+	if version == "" {
+		if version, err = moduleVersion(pkg); err != nil {
+			return "", err
+		}
+	}
+
+	// the shared library is built for the GOOS and GOARCH of the environment, like the go command would
+	goos, goarch := os.Getenv("GOOS"), os.Getenv("GOARCH")
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	summary := fmt.Sprintf("Python bindings of %s generated by Veil", pkg.Path())
+	project, err := python.NewProject(name, version, summary, g.LibName, goos, goarch)
+	if err != nil {
+		return "", err
+	}
+
+	buildDir, err := createOutputDir(filepath.Join(outDir, PACKAGE_BUILD_DIR))
+	if err != nil {
+		return "", err
+	}
+	if err := bind.Bind(pkg, g.Targets, g.Options, buildDir, g.LibName); err != nil {
+		return "", err
+	}
+
+	projectDir := filepath.Join(outDir, name)
+	if err := project.Layout(buildDir, projectDir); err != nil {
+		return "", err
+	}
+	return project.BuildWheel(projectDir, filepath.Join(outDir, PACKAGE_DIST_DIR))
+}
+
+// moduleVersion returns the Python version of the module of pkg. The main module has no version, so it is
+// versioned after the latest v* tag of its repository, where the commits since the tag make a post release.
+// Packages without a version are versioned DEFAULT_PY_VERSION with a warning.
+func moduleVersion(pkg *cgo.Package) (string, error) {
+	module := pkg.Module()
+	if module == nil {
+		fmt.Fprintf(os.Stderr, "warning: [%s] isn't in a module, so it is versioned %s\n", pkg.Path(),
+			DEFAULT_PY_VERSION)
+		return DEFAULT_PY_VERSION, nil
+	}
+	if module.Version != "" {
+		return python.PythonVersion(module.Version)
+	}
+
+	out, err := exec.Command("git", "-C", module.Dir, "describe", "--tags", "--match=v*").Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: the repository of module %s has no v* tag, so it is versioned %s\n",
+			module.Path, DEFAULT_PY_VERSION)
+		return DEFAULT_PY_VERSION, nil
+	}
+
+	described := strings.TrimSpace(string(out))
+	if match := gitDescribePattern.FindStringSubmatch(described); match != nil {
+		version, err := python.PythonVersion(match[1])
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(version, "+") {
+			// a tag with build metadata already has a local version
+			return version, nil
+		}
+		return fmt.Sprintf("%s.post%s+g%s", version, match[2], match[3]), nil
+	}
+	return python.PythonVersion(described)
+}
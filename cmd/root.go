@@ -22,7 +22,8 @@ Created with love by devigned and friends in Go.`,
 
 func init() {
 	cobra.OnInitialize(initConfig)
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.veil.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "",
+		"config file (default is .veil.yaml in the working directory, then $HOME/.veil.yaml)")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -38,16 +39,20 @@ func initConfig() {
 			os.Exit(1)
 		}
 
-		// Search config in home directory with name ".veil" (without extension).
+		// Search config in the project being bound, then in the home directory with name ".veil" (without
+		// extension).
+		viper.AddConfigPath(".")
 		viper.AddConfigPath(home)
 		viper.SetConfigName(".veil")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	viper.SetEnvPrefix("veil")
+	viper.AutomaticEnv() // read in environment variables that match, such as VEIL_NAME
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// stdout is kept for commands printing JSON
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
 
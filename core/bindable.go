@@ -1,6 +1,30 @@
 package core
 
-// Bindable is the interface for any object that will create a binding for a golang.Package
+// BuildStep is a step of the build which a Binder needs to have run before it binds a package
+type BuildStep string
+
+const (
+	// BUILD_STEP_CGO writes the CGo wrapper of the package to main.go in the output directory
+	BUILD_STEP_CGO BuildStep = "cgo"
+	// BUILD_STEP_SHARED_LIB builds the CGo wrapper into the shared library named libName and its header
+	// libName.h in the output directory, which implies BUILD_STEP_CGO
+	BUILD_STEP_SHARED_LIB BuildStep = "shared-lib"
+)
+
+// BUILD_STEPS are the build steps in the order they run
+var BUILD_STEPS = []BuildStep{BUILD_STEP_CGO, BUILD_STEP_SHARED_LIB}
+
+// Binder generates the binding of a Go package for a target language. A Binder is created by the factory its
+// target is registered with, from the package and the Options of the target. Then veil:
+//  1. runs the BuildSteps of every target being generated, each step once and in the order of BUILD_STEPS
+//  2. calls Bind with the output directory and the name of the shared library
+//  3. checks that Bind wrote every file returned by Files
 type Binder interface {
+	// Files returns the paths relative to the output directory of the files Bind writes, such as generated.py.
+	// Binders which only know their files once they run return nil.
+	Files(libName string) []string
+	// BuildSteps returns the build steps which must run before Bind
+	BuildSteps() []BuildStep
+	// Bind writes the binding to outDir, where the shared library is named libName
 	Bind(outDir, libName string) error
 }
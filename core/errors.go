@@ -23,6 +23,11 @@ func NewUserError(a ...interface{}) CommandError {
 	return CommandError{s: fmt.Sprintln(a...), userError: true}
 }
 
+// NewUserErrorF creates a new user input related error with formatting
+func NewUserErrorF(format string, a ...interface{}) CommandError {
+	return CommandError{s: fmt.Sprintf(format, a...), userError: true}
+}
+
 // NewSystemError creates a new system related error
 func NewSystemError(a ...interface{}) CommandError {
 	return CommandError{s: fmt.Sprintln(a...), userError: false}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Options are the settings of a target keyed by name, such as struct-tags=true for py3. Binders read them with
+// the typed getters, which mark them used, so settings no binder reads can be reported as unknown.
+type Options struct {
+	Target string
+	values map[string]string
+	used   map[string]bool
+}
+
+// NewOptions creates the empty Options of target
+func NewOptions(target string) *Options {
+	return &Options{
+		Target: target,
+		values: map[string]string{},
+		used:   map[string]bool{},
+	}
+}
+
+// Set sets the option name, replacing any previous value
+func (o *Options) Set(name, value string) {
+	o.values[name] = value
+}
+
+// String returns the value of the option name, or def if it isn't set
+func (o *Options) String(name, def string) string {
+	o.used[name] = true
+	if value, ok := o.values[name]; ok {
+		return value
+	}
+	return def
+}
+
+// Bool returns the value of the option name, or def if it isn't set
+func (o *Options) Bool(name string, def bool) (bool, error) {
+	o.used[name] = true
+	value, ok := o.values[name]
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, NewUserErrorF("Option %s of target %s must be true or false, not %q", name, o.Target, value)
+	}
+	return b, nil
+}
+
+// Values returns a copy of every option, which are all marked used
+func (o *Options) Values() map[string]string {
+	values := map[string]string{}
+	for name, value := range o.values {
+		o.used[name] = true
+		values[name] = value
+	}
+	return values
+}
+
+// Unused returns the sorted names of the options which are set but were never read
+func (o *Options) Unused() []string {
+	names := []string{}
+	for name := range o.values {
+		if !o.used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,279 @@
+package ir
+
+import (
+	"go/types"
+
+	"github.com/devigned/veil/cgo"
+)
+
+// NewPackage describes the wrapper veil generates for pkg
+func NewPackage(pkg *cgo.Package) *Package {
+	ir := &Package{
+		Version:    VERSION,
+		Name:       pkg.Name(),
+		Path:       pkg.Path(),
+		Funcs:      []*Func{},
+		Structs:    []*Struct{},
+		Interfaces: []*Interface{},
+		Slices:     []*Slice{},
+		Seqs:       []*Seq{},
+	}
+
+	for _, f := range pkg.Funcs() {
+		if !pkg.IsConstructor(f) {
+			ir.Funcs = append(ir.Funcs, NewFunc(f))
+		}
+	}
+	for _, s := range pkg.Structs() {
+		ir.Structs = append(ir.Structs, NewStruct(pkg, s))
+	}
+	for _, iface := range pkg.Interfaces() {
+		ir.Interfaces = append(ir.Interfaces, NewInterface(iface))
+	}
+	for _, slice := range pkg.Slices() {
+		ir.Slices = append(ir.Slices, &Slice{
+			CName:   slice.CGoName(),
+			Elem:    NewType(slice.Elem()),
+			Symbols: slice.Symbols(),
+		})
+	}
+	for _, seq := range pkg.Seqs() {
+		ir.Seqs = append(ir.Seqs, &Seq{
+			CName: seq.CName(),
+			Elems: newTypes(seq.Elems()),
+			Next:  seq.NextMethodName(),
+		})
+	}
+	applyDirectives(pkg, ir)
+	applyDocs(pkg, ir)
+	return ir
+}
+
+// applyDocs sets the doc comments and deprecation notices of the funcs, structs, fields, methods and interfaces of
+// ir
+func applyDocs(pkg *cgo.Package, ir *Package) {
+	funcs := append([]*Func{}, ir.Funcs...)
+	for _, s := range ir.Structs {
+		s.Doc = pkg.Doc(s.Name)
+		s.Deprecated = cgo.Deprecation(s.Doc)
+		for _, field := range s.Fields {
+			field.Doc = pkg.Doc(s.Name + "." + field.Name)
+			field.Deprecated = cgo.Deprecation(field.Doc)
+		}
+		funcs = append(funcs, s.Methods...)
+		for _, constructor := range s.Constructors {
+			funcs = append(funcs, constructor.Func)
+		}
+	}
+	for _, iface := range ir.Interfaces {
+		iface.Doc = pkg.Doc(iface.Name)
+		iface.Deprecated = cgo.Deprecation(iface.Doc)
+		funcs = append(funcs, iface.Methods...)
+	}
+	for _, f := range funcs {
+		f.Doc = pkg.Doc(f.symbol())
+		f.Deprecated = cgo.Deprecation(f.Doc)
+	}
+}
+
+// NewStruct describes the wrapper of s and of the constructors of s among the funcs of pkg
+func NewStruct(pkg *cgo.Package, s *cgo.Struct) *Struct {
+	init := map[*types.Var]bool{}
+	for _, field := range s.InitFields() {
+		init[field] = true
+	}
+
+	fields := []*Field{}
+	for i := 0; i < s.Struct().NumFields(); i++ {
+		field := s.Struct().Field(i)
+		if !cgo.ShouldGenerateField(field) {
+			continue
+		}
+		irField := &Field{
+			Var:    *NewVar(field),
+			Tag:    s.Struct().Tag(i),
+			Getter: s.GetterName(field),
+			Setter: s.SetterName(field),
+			Init:   init[field],
+		}
+		if cgo.HasValueSemantics(field.Type()) {
+			irField.Ref = s.RefGetterName(field)
+		}
+		fields = append(fields, irField)
+	}
+
+	constructors := []*Constructor{}
+	for _, f := range pkg.Funcs() {
+		if s.IsConstructor(f) {
+			constructors = append(constructors, &Constructor{Name: s.ConstructorName(f), Func: NewFunc(f)})
+		}
+	}
+
+	return &Struct{
+		Name:         s.Obj().Name(),
+		CName:        s.CName(),
+		GoType:       goType(s.Named.Named),
+		Fields:       fields,
+		Methods:      newFuncs(s.ExportedMethods()),
+		Constructors: constructors,
+		Comparable:   s.IsComparable(),
+		Undecodable:  s.UndecodableReason(),
+		Symbols:      s.Symbols(),
+	}
+}
+
+// NewInterface describes the wrapper of iface
+func NewInterface(iface *cgo.Interface) *Interface {
+	return &Interface{
+		Name:    iface.Name(),
+		CName:   iface.CName(),
+		GoType:  goType(iface.Named()),
+		Methods: newFuncs(iface.ExportedMethods()),
+		Stream:  iface.StreamMethods(),
+		Symbols: iface.Symbols(),
+	}
+}
+
+// NewFunc describes the wrapper of f
+func NewFunc(f *cgo.Func) *Func {
+	sig := f.Signature()
+	options := f.Options()
+	numParams := sig.Params().Len()
+	if options != nil {
+		// the variadic options are described by Options
+		numParams--
+	}
+
+	params := make([]*Var, numParams)
+	for i := range params {
+		params[i] = NewVar(sig.Params().At(i))
+		params[i].Check = f.OpaqueParamKey(i)
+	}
+
+	fun := &Func{
+		Name:    f.Name(),
+		CName:   f.CName(),
+		Params:  params,
+		Results: newVars(sig.Results()),
+	}
+	if f.BoundRecv != nil {
+		fun.Receiver = f.BoundRecv.Obj().Name()
+	}
+	if options != nil {
+		fun.Options = newOptions(options)
+	}
+	return fun
+}
+
+func newFuncs(funcs []*cgo.Func) []*Func {
+	irFuncs := make([]*Func, len(funcs))
+	for i, f := range funcs {
+		irFuncs[i] = NewFunc(f)
+	}
+	return irFuncs
+}
+
+func newOptions(options *cgo.Options) *Options {
+	helpers := make([]*OptionHelper, len(options.Helpers))
+	for i, helper := range options.Helpers {
+		params := []*Var{}
+		for _, param := range cgo.HelperParams(helper) {
+			params = append(params, NewVar(param))
+		}
+		helpers[i] = &OptionHelper{Name: cgo.OptionName(helper), Func: helper.Name(), Params: params}
+	}
+	return &Options{Type: NewType(options.Named), Helpers: helpers}
+}
+
+// NewVar describes a param, result or field
+func NewVar(v *types.Var) *Var {
+	return &Var{Name: v.Name(), Type: NewType(v.Type())}
+}
+
+func newVars(tuple *types.Tuple) []*Var {
+	vars := make([]*Var, tuple.Len())
+	for i := range vars {
+		vars[i] = NewVar(tuple.At(i))
+	}
+	return vars
+}
+
+// NewType describes t and how its values are marshaled
+func NewType(t types.Type) *Type {
+	return newType(t, map[*types.Named]bool{})
+}
+
+// newType tracks the named types whose underlying type is being described, so types such as type List []List
+// terminate
+func newType(t types.Type, visiting map[*types.Named]bool) *Type {
+	typ := &Type{
+		GoType:         goType(t),
+		Error:          cgo.ImplementsError(t),
+		ValueSemantics: cgo.HasValueSemantics(t),
+	}
+	if named, ok := t.(*types.Named); ok {
+		typ.Name = named.Obj().Name()
+	}
+
+	// sequences are opaque unless their values can be pulled, so they are checked first
+	if cgo.IsPullable(t) {
+		seq := cgo.NewSeq(t.(*types.Named))
+		typ.Kind, typ.Marshal = KIND_SEQ, MARSHAL_SEQ
+		typ.Elems = newTypes(seq.Elems())
+		typ.Next = seq.NextMethodName()
+		return typ
+	}
+	if cgo.IsOpaque(t) {
+		typ.Kind, typ.Marshal = KIND_OPAQUE, MARSHAL_OPAQUE
+		return typ
+	}
+
+	switch t := t.(type) {
+	case *types.Basic:
+		typ.Kind, typ.Basic = KIND_BASIC, types.Typ[t.Kind()].Name()
+		typ.Marshal = MARSHAL_VALUE
+		if t.Kind() == types.String {
+			typ.Marshal = MARSHAL_STRING
+		}
+	case *types.Named:
+		switch t.Underlying().(type) {
+		case *types.Struct:
+			typ.Kind, typ.Marshal = KIND_STRUCT, MARSHAL_HANDLE
+		case *types.Interface:
+			typ.Kind, typ.Marshal = KIND_INTERFACE, MARSHAL_INTERFACE
+		default:
+			typ.Kind, typ.Marshal = KIND_NAMED, MARSHAL_HANDLE
+			if !visiting[t] {
+				visiting[t] = true
+				typ.Underlying = newType(t.Underlying(), visiting)
+			}
+		}
+	case *types.Interface:
+		typ.Kind, typ.Marshal = KIND_INTERFACE, MARSHAL_INTERFACE
+	case *types.Slice:
+		typ.Kind, typ.Marshal = KIND_SLICE, MARSHAL_HANDLE
+		typ.Elem = newType(t.Elem(), visiting)
+	case *types.Pointer:
+		typ.Kind, typ.Marshal = KIND_POINTER, MARSHAL_HANDLE
+		typ.Elem = newType(t.Elem(), visiting)
+		if _, ok := t.Elem().(*types.Basic); ok {
+			// pointers to basic types are passed as the values they point to
+			typ.Marshal = typ.Elem.Marshal
+		}
+	default:
+		typ.Kind, typ.Marshal = KIND_UNKNOWN, MARSHAL_UNSUPPORTED
+	}
+	return typ
+}
+
+func newTypes(ts []types.Type) []*Type {
+	irTypes := make([]*Type, len(ts))
+	for i, t := range ts {
+		irTypes[i] = NewType(t)
+	}
+	return irTypes
+}
+
+func goType(t types.Type) string {
+	return cgo.TypeExpressionToString(cgo.TypeExpression(t))
+}
@@ -0,0 +1,219 @@
+package ir
+
+import (
+	"github.com/devigned/veil/cgo"
+)
+
+// applyDirectives leaves the symbols excluded by the directives of pkg out of ir, along with everything which
+// refers to them, and sets the bind names and out-params the directives give
+func applyDirectives(pkg *cgo.Package, ir *Package) {
+	// excluded holds the GoType of the structs and interfaces left out
+	excluded := map[string]bool{}
+	structs := []*Struct{}
+	for _, s := range ir.Structs {
+		if pkg.IsExcluded(s.Name) {
+			excluded[s.GoType] = true
+			continue
+		}
+		structs = append(structs, s)
+	}
+	ir.Structs = structs
+
+	// interfaces whose methods refer to excluded types can't be implemented either, which may in turn leave out
+	// other interfaces
+	for changed := true; changed; {
+		changed = false
+		interfaces := []*Interface{}
+		for _, iface := range ir.Interfaces {
+			if excluded[iface.GoType] || pkg.IsExcluded(iface.Name) || funcsRefer(iface.Methods, excluded) {
+				changed = changed || !excluded[iface.GoType]
+				excluded[iface.GoType] = true
+				continue
+			}
+			interfaces = append(interfaces, iface)
+		}
+		ir.Interfaces = interfaces
+	}
+
+	ir.Funcs = keepFuncs(pkg, ir.Funcs, excluded)
+	for _, s := range ir.Structs {
+		fields := []*Field{}
+		for _, field := range s.Fields {
+			if pkg.IsExcluded(s.Name+"."+field.Name) || field.Type.refers(excluded) {
+				if !field.Init {
+					continue
+				}
+				field.Hidden = true
+			}
+			fields = append(fields, field)
+		}
+		s.Fields = fields
+		s.Methods = keepFuncs(pkg, s.Methods, excluded)
+
+		constructors := []*Constructor{}
+		for _, constructor := range s.Constructors {
+			if len(keepFuncs(pkg, []*Func{constructor.Func}, excluded)) > 0 {
+				constructors = append(constructors, constructor)
+			}
+		}
+		s.Constructors = constructors
+	}
+	slices := []*Slice{}
+	for _, slice := range ir.Slices {
+		if !slice.Elem.refers(excluded) {
+			slices = append(slices, slice)
+		}
+	}
+	ir.Slices = slices
+	seqs := []*Seq{}
+	for _, seq := range ir.Seqs {
+		if !typesRefer(seq.Elems, excluded) {
+			seqs = append(seqs, seq)
+		}
+	}
+	ir.Seqs = seqs
+
+	// renamed maps the GoType of renamed structs and interfaces to their names, which every type referring to
+	// them is given
+	renamed := map[string]string{}
+	for _, s := range ir.Structs {
+		s.BindName = pkg.Directive(s.Name).Name
+		renamed[s.GoType] = s.BindName
+		for _, field := range s.Fields {
+			field.BindName = pkg.Directive(s.Name + "." + field.Name).Name
+		}
+		for _, meth := range s.Methods {
+			applyFuncDirective(pkg, meth)
+		}
+		for _, constructor := range s.Constructors {
+			applyFuncDirective(pkg, constructor.Func)
+		}
+	}
+	for _, iface := range ir.Interfaces {
+		iface.BindName = pkg.Directive(iface.Name).Name
+		renamed[iface.GoType] = iface.BindName
+		for _, meth := range iface.Methods {
+			applyFuncDirective(pkg, meth)
+		}
+	}
+	for _, f := range ir.Funcs {
+		applyFuncDirective(pkg, f)
+	}
+	ir.visitTypes(func(t *Type) {
+		t.BindName = renamed[t.GoType]
+	})
+}
+
+// symbol returns the name directives refer to f by, such as Hello or Shape.Area
+func (f *Func) symbol() string {
+	if f.Receiver != "" {
+		return f.Receiver + "." + f.Name
+	}
+	return f.Name
+}
+
+func applyFuncDirective(pkg *cgo.Package, f *Func) {
+	directive := pkg.Directive(f.symbol())
+	f.BindName = directive.Name
+	for _, param := range f.Params {
+		for _, name := range directive.Out {
+			if param.Name == name {
+				param.Out = true
+			}
+		}
+	}
+}
+
+// keepFuncs returns the funcs which aren't excluded and don't refer to excluded types
+func keepFuncs(pkg *cgo.Package, funcs []*Func, excluded map[string]bool) []*Func {
+	kept := []*Func{}
+	for _, f := range funcs {
+		if !pkg.IsExcluded(f.symbol()) && !funcsRefer([]*Func{f}, excluded) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func funcsRefer(funcs []*Func, goTypes map[string]bool) bool {
+	refers := false
+	for _, f := range funcs {
+		f.visitTypes(func(t *Type) {
+			refers = refers || goTypes[t.GoType]
+		})
+	}
+	return refers
+}
+
+// refers returns true if t or any type it is made of is one of goTypes
+func (t *Type) refers(goTypes map[string]bool) bool {
+	refers := false
+	t.visit(func(t *Type) {
+		refers = refers || goTypes[t.GoType]
+	})
+	return refers
+}
+
+func typesRefer(ts []*Type, goTypes map[string]bool) bool {
+	for _, t := range ts {
+		if t.refers(goTypes) {
+			return true
+		}
+	}
+	return false
+}
+
+// visit calls visitor with t and every type it is made of
+func (t *Type) visit(visitor func(*Type)) {
+	if t == nil {
+		return
+	}
+	visitor(t)
+	t.Elem.visit(visitor)
+	for _, elem := range t.Elems {
+		elem.visit(visitor)
+	}
+	t.Underlying.visit(visitor)
+}
+
+func (f *Func) visitTypes(visitor func(*Type)) {
+	for _, v := range append(append([]*Var{}, f.Params...), f.Results...) {
+		v.Type.visit(visitor)
+	}
+	if f.Options != nil {
+		f.Options.Type.visit(visitor)
+		for _, helper := range f.Options.Helpers {
+			for _, param := range helper.Params {
+				param.Type.visit(visitor)
+			}
+		}
+	}
+}
+
+// visitTypes calls visitor with every type of the package
+func (p *Package) visitTypes(visitor func(*Type)) {
+	funcs := append([]*Func{}, p.Funcs...)
+	for _, s := range p.Structs {
+		for _, field := range s.Fields {
+			field.Type.visit(visitor)
+		}
+		funcs = append(funcs, s.Methods...)
+		for _, constructor := range s.Constructors {
+			funcs = append(funcs, constructor.Func)
+		}
+	}
+	for _, iface := range p.Interfaces {
+		funcs = append(funcs, iface.Methods...)
+	}
+	for _, f := range funcs {
+		f.visitTypes(visitor)
+	}
+	for _, slice := range p.Slices {
+		slice.Elem.visit(visitor)
+	}
+	for _, seq := range p.Seqs {
+		for _, elem := range seq.Elems {
+			elem.visit(visitor)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package ir describes the API a Go package exposes through its CGo wrapper: the exported functions, structs,
+// fields, interfaces, slice wrappers and sequences, the C symbols wrapping each of them and how every value is
+// marshaled across the bridge. Binders generate host language code from the IR rather than from go/types, and the
+// IR serialises to JSON so tools outside of veil can do the same.
+package ir
+
+import (
+	"encoding/json"
+
+	"github.com/devigned/veil/core"
+)
+
+// VERSION is the version of the IR format, which changes whenever a change to the IR could break its consumers
+const VERSION = "1"
+
+// Kinds of types
+const (
+	// KIND_BASIC is a bool, numeric or string type, named by Basic
+	KIND_BASIC = "basic"
+	// KIND_STRUCT is a named struct
+	KIND_STRUCT = "struct"
+	// KIND_INTERFACE is an interface, which is unnamed when Name is empty
+	KIND_INTERFACE = "interface"
+	// KIND_NAMED is a named type of any other underlying type, such as type Color int
+	KIND_NAMED = "named"
+	// KIND_SLICE is a slice of Elem
+	KIND_SLICE = "slice"
+	// KIND_POINTER is a pointer to Elem
+	KIND_POINTER = "pointer"
+	// KIND_OPAQUE is a type the wrapper can't represent, such as a map, a channel or an unexported type
+	KIND_OPAQUE = "opaque"
+	// KIND_SEQ is an iter.Seq or iter.Seq2 of Elems
+	KIND_SEQ = "seq"
+	// KIND_UNKNOWN is any other type, such as an array or an unnamed struct
+	KIND_UNKNOWN = "unknown"
+)
+
+// Marshaling rules, which say how values of a type cross the bridge
+const (
+	// MARSHAL_VALUE values are passed as the C type of their basic type
+	MARSHAL_VALUE = "value"
+	// MARSHAL_STRING values are passed as NUL terminated C strings
+	MARSHAL_STRING = "string"
+	// MARSHAL_HANDLE values are passed as handles to Go values
+	MARSHAL_HANDLE = "handle"
+	// MARSHAL_INTERFACE values are passed as handles to Go values or to host language implementations
+	MARSHAL_INTERFACE = "interface"
+	// MARSHAL_OPAQUE values are passed as handles to boxed interface{} values, which can't be inspected
+	MARSHAL_OPAQUE = "opaque"
+	// MARSHAL_SEQ values are returned as handles to sequences, whose values are pulled with the Next function
+	MARSHAL_SEQ = "seq"
+	// MARSHAL_UNSUPPORTED values can't cross the bridge
+	MARSHAL_UNSUPPORTED = "unsupported"
+)
+
+// Package is the IR of a Go package
+type Package struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	// Funcs are the exported functions other than constructors, which belong to their struct
+	Funcs      []*Func      `json:"funcs"`
+	Structs    []*Struct    `json:"structs"`
+	Interfaces []*Interface `json:"interfaces"`
+	Slices     []*Slice     `json:"slices"`
+	Seqs       []*Seq       `json:"seqs"`
+}
+
+// Type describes a Go type and how its values are marshaled
+type Type struct {
+	Kind string `json:"kind"`
+	// GoType is the type as written in the wrapper, with packages replaced by their aliases such as
+	// veil_github_com_foo_bar.Baz
+	GoType  string `json:"go_type"`
+	Marshal string `json:"marshal"`
+	// Basic is the name of the basic type of KIND_BASIC types, where aliases such as byte are resolved
+	Basic string `json:"basic,omitempty"`
+	// Name is the name of named types
+	Name string `json:"name,omitempty"`
+	// BindName is the name bindings give the named types of the package which are renamed
+	BindName string `json:"bind_name,omitempty"`
+	// Error is true if the type implements error
+	Error bool `json:"error,omitempty"`
+	// ValueSemantics is true if host language views of the value change it in place, as for structs and slices
+	ValueSemantics bool    `json:"value_semantics,omitempty"`
+	Elem           *Type   `json:"elem,omitempty"`
+	Elems          []*Type `json:"elems,omitempty"`
+	Underlying     *Type   `json:"underlying,omitempty"`
+	// Next is the C function pulling the next values of a KIND_SEQ type
+	Next string `json:"next,omitempty"`
+}
+
+// Var is a named param or result
+type Var struct {
+	// Name is empty for unnamed params and results
+	Name string `json:"name"`
+	Type *Type  `json:"type"`
+	// Out is true for out-params, which bindings create and return along with the results
+	Out bool `json:"out,omitempty"`
+	// Check is the key of an opaque param in the registry of the wrapper, which bindings pass to cgo_opaque_check
+	// to reject values of another Go type
+	Check string `json:"check,omitempty"`
+}
+
+// Func is an exported function or method
+type Func struct {
+	Name  string `json:"name"`
+	CName string `json:"c_name"`
+	// BindName is the name bindings give the func rather than one derived from Name, if it is renamed
+	BindName string `json:"bind_name,omitempty"`
+	// Doc is the Go doc comment, and Deprecated is the notice of its "Deprecated: " paragraph if it has one
+	Doc        string `json:"doc,omitempty"`
+	Deprecated string `json:"deprecated,omitempty"`
+	// Receiver is the name of the type a method is bound to, or empty for functions
+	Receiver string `json:"receiver,omitempty"`
+	// Params don't include the variadic functional options of funcs with Options
+	Params  []*Var   `json:"params"`
+	Results []*Var   `json:"results"`
+	Options *Options `json:"options,omitempty"`
+}
+
+// Options are the Go functional options accepted by the variadic last param of a func. The wrapper takes a mask
+// of the options being set followed by the params of every helper, in order.
+type Options struct {
+	Type    *Type           `json:"type"`
+	Helpers []*OptionHelper `json:"helpers"`
+}
+
+// OptionHelper is a With* function setting an option, such as WithTimeout
+type OptionHelper struct {
+	// Name is the name of the option, such as Timeout for WithTimeout
+	Name   string `json:"name"`
+	Func   string `json:"func"`
+	Params []*Var `json:"params"`
+}
+
+// Struct is an exported struct
+type Struct struct {
+	Name string `json:"name"`
+	// BindName is the name bindings give the struct if it is renamed
+	BindName string `json:"bind_name,omitempty"`
+	// Doc is the Go doc comment, and Deprecated is the notice of its "Deprecated: " paragraph if it has one
+	Doc          string         `json:"doc,omitempty"`
+	Deprecated   string         `json:"deprecated,omitempty"`
+	CName        string         `json:"c_name"`
+	GoType       string         `json:"go_type"`
+	Fields       []*Field       `json:"fields"`
+	Methods      []*Func        `json:"methods"`
+	Constructors []*Constructor `json:"constructors"`
+	// Comparable structs are compared and hashed with Go ==
+	Comparable bool `json:"comparable"`
+	// Undecodable is why the JSON encoding of the struct can't be decoded back into the value it encodes, such as
+	// an interface field, or empty if it can
+	Undecodable string `json:"undecodable,omitempty"`
+	// Symbols are the C functions wrapping the struct keyed by what they do, such as new_with or to_json
+	Symbols map[string]string `json:"symbols"`
+}
+
+// Field is a field of a struct with accessors
+type Field struct {
+	Var
+	// BindName is the name bindings give the field if it is renamed
+	BindName string `json:"bind_name,omitempty"`
+	// Doc is the Go doc comment, and Deprecated is the notice of its "Deprecated: " paragraph if it has one
+	Doc        string `json:"doc,omitempty"`
+	Deprecated string `json:"deprecated,omitempty"`
+	// Tag is the raw struct tag of the field
+	Tag    string `json:"tag,omitempty"`
+	Getter string `json:"getter"`
+	Setter string `json:"setter"`
+	// Ref returns a live handle to fields with value semantics
+	Ref string `json:"ref,omitempty"`
+	// Init is true if the field is set by the new_with symbol, which takes a mask of the fields being set
+	// followed by every init field, in order
+	Init bool `json:"init"`
+	// Hidden init fields are excluded from the bindings, which still pass their zero values to new_with
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// Constructor is a function constructing a struct
+type Constructor struct {
+	// Name is the name of the constructor on the struct, such as New, or NewWithName for NewFooWithName
+	Name string `json:"name"`
+	Func *Func  `json:"func"`
+}
+
+// Interface is an exported interface, which can be implemented in the host language
+type Interface struct {
+	Name string `json:"name"`
+	// BindName is the name bindings give the interface if it is renamed
+	BindName string `json:"bind_name,omitempty"`
+	// Doc is the Go doc comment, and Deprecated is the notice of its "Deprecated: " paragraph if it has one
+	Doc        string  `json:"doc,omitempty"`
+	Deprecated string  `json:"deprecated,omitempty"`
+	CName      string  `json:"c_name"`
+	GoType     string  `json:"go_type"`
+	Methods    []*Func `json:"methods"`
+	// Stream lists the io.Reader, io.Writer and io.Closer methods of interfaces which only have those methods
+	Stream  []string          `json:"stream,omitempty"`
+	Symbols map[string]string `json:"symbols"`
+}
+
+// Slice is the wrapper of a slice type
+type Slice struct {
+	CName   string            `json:"c_name"`
+	Elem    *Type             `json:"elem"`
+	Symbols map[string]string `json:"symbols"`
+}
+
+// Seq is the wrapper of an iter.Seq or iter.Seq2 returned by the package
+type Seq struct {
+	CName string  `json:"c_name"`
+	Elems []*Type `json:"elems"`
+	Next  string  `json:"next"`
+}
+
+// Marshal encodes the IR as indented JSON
+func Marshal(pkg *Package) ([]byte, error) {
+	data, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		return nil, core.NewSystemErrorF("Could not encode the IR of %s: %v", pkg.Path, err)
+	}
+	return data, nil
+}
+
+// Unmarshal decodes the IR from JSON, which must be of the current VERSION
+func Unmarshal(data []byte) (*Package, error) {
+	pkg := &Package{}
+	if err := json.Unmarshal(data, pkg); err != nil {
+		return nil, core.NewUserErrorF("Could not decode IR: %v", err)
+	}
+	if pkg.Version != VERSION {
+		return nil, core.NewUserErrorF("IR version %q isn't supported, expected version %q", pkg.Version, VERSION)
+	}
+	return pkg, nil
+}
@@ -0,0 +1,197 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/devigned/veil/cgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildTestPackage(t *testing.T, src string) *Package {
+	pkg, err := cgo.NewPackageFromSources("github.com/foo/shapes", map[string]string{"src.go": src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPackage(pkg)
+}
+
+func TestNewPackage(t *testing.T) {
+	pkg := buildTestPackage(t, `package shapes
+import "iter"
+
+type Option func(*Shape)
+
+type Shape struct {
+	Name   string `+"`json:\"name\"`"+`
+	Points []Point
+	Meta   map[string]string
+}
+
+type Point struct {
+	X, Y int
+}
+
+type Drawer interface {
+	Draw(s *Shape) error
+}
+
+func NewShape(name string) *Shape { return nil }
+func NewShapeFromPoints(points []Point) (*Shape, error) { return nil, nil }
+func WithName(name string) Option { return nil }
+func Build(sides uint8, opts ...Option) *Shape { return nil }
+func Walk(s Shape) iter.Seq2[Point, error] { return nil }
+func (s *Shape) Area() float64 { return 0 }
+`)
+	assert.Equal(t, VERSION, pkg.Version)
+	assert.Equal(t, "github.com/foo/shapes", pkg.Path)
+
+	funcs := map[string]*Func{}
+	for _, f := range pkg.Funcs {
+		funcs[f.Name] = f
+	}
+	assert.NotContains(t, funcs, "NewShape", "constructors belong to their struct")
+
+	build := funcs["Build"]
+	if assert.NotNil(t, build) {
+		assert.Equal(t, "veil_github_com_foo_shapes_Build", build.CName)
+		assert.Len(t, build.Params, 1)
+		assert.Equal(t, &Type{Kind: KIND_BASIC, GoType: "uint8", Basic: "uint8", Marshal: MARSHAL_VALUE},
+			build.Params[0].Type)
+		if assert.NotNil(t, build.Options) && assert.Len(t, build.Options.Helpers, 1) {
+			assert.Equal(t, "Name", build.Options.Helpers[0].Name)
+			assert.Equal(t, "WithName", build.Options.Helpers[0].Func)
+		}
+	}
+
+	walk := funcs["Walk"]
+	if assert.NotNil(t, walk) {
+		seq := walk.Results[0].Type
+		assert.Equal(t, KIND_SEQ, seq.Kind)
+		assert.Equal(t, "seq2_of_veil_github_com_foo_shapes_Point_and_error_next", seq.Next)
+		if assert.Len(t, seq.Elems, 2) {
+			assert.Equal(t, KIND_STRUCT, seq.Elems[0].Kind)
+			assert.True(t, seq.Elems[1].Error)
+		}
+	}
+
+	structs := map[string]*Struct{}
+	for _, s := range pkg.Structs {
+		structs[s.Name] = s
+	}
+	shape := structs["Shape"]
+	if assert.NotNil(t, shape) {
+		assert.False(t, shape.Comparable)
+		assert.Equal(t, "veil_github_com_foo_shapes_Shape_to_json", shape.Symbols["to_json"])
+		if assert.Len(t, shape.Fields, 2, "opaque fields have no accessors") {
+			assert.Equal(t, `json:"name"`, shape.Fields[0].Tag)
+			assert.Equal(t, "veil_github_com_foo_shapes_Shape_Name_get", shape.Fields[0].Getter)
+			assert.Empty(t, shape.Fields[0].Ref)
+			assert.Equal(t, KIND_SLICE, shape.Fields[1].Type.Kind)
+			assert.Equal(t, "veil_github_com_foo_shapes_Shape_Points_ref", shape.Fields[1].Ref)
+			assert.True(t, shape.Fields[1].Init)
+		}
+		if assert.Len(t, shape.Constructors, 2) {
+			assert.Equal(t, "New", shape.Constructors[0].Name)
+			assert.Equal(t, "NewFromPoints", shape.Constructors[1].Name)
+		}
+		if assert.Len(t, shape.Methods, 1) {
+			assert.Equal(t, "Shape", shape.Methods[0].Receiver)
+		}
+	}
+	assert.True(t, structs["Point"].Comparable)
+
+	if assert.Len(t, pkg.Interfaces, 1) {
+		draw := pkg.Interfaces[0].Methods[0]
+		assert.Equal(t, KIND_POINTER, draw.Params[0].Type.Kind)
+		assert.Equal(t, MARSHAL_HANDLE, draw.Params[0].Type.Marshal)
+		assert.Equal(t, MARSHAL_INTERFACE, draw.Results[0].Type.Marshal)
+	}
+
+	cNames := []string{}
+	for _, slice := range pkg.Slices {
+		cNames = append(cNames, slice.CName)
+	}
+	assert.Contains(t, cNames, "slice_of_veil_github_com_foo_shapes_Point")
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	pkg := buildTestPackage(t, `package shapes
+type Point struct {
+	X, Y int
+}
+
+func Origin() Point { return Point{} }
+`)
+	data, err := Marshal(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(data), `"version": "1"`)
+
+	decoded, err := Unmarshal(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, pkg, decoded)
+	}
+
+	_, err = Unmarshal([]byte(`{"version": "0"}`))
+	assert.Error(t, err)
+	_, err = Unmarshal([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestDirectives(t *testing.T) {
+	pkg := buildTestPackage(t, `package shapes
+type Point struct {
+	X, Y   int
+	Secret string //veil:exclude
+}
+
+//veil:name Vertex
+type Node struct {
+	P    Point
+	Next *Internal
+}
+
+//veil:exclude
+type Internal struct{ A int }
+
+type Visitor interface {
+	Visit(i *Internal)
+}
+
+//veil:out p
+func Parse(s string, p *Point) error { return nil }
+func Nodes() []Node { return nil }
+func Use(i Internal) int { return 0 }
+func (n *Node) Skip() *Internal { return nil }
+`)
+	assert.Empty(t, pkg.Interfaces, "Visitor refers to Internal")
+
+	funcs := map[string]*Func{}
+	for _, f := range pkg.Funcs {
+		funcs[f.Name] = f
+	}
+	assert.NotContains(t, funcs, "Use")
+	if parse := funcs["Parse"]; assert.NotNil(t, parse) {
+		assert.True(t, parse.Params[1].Out)
+		assert.False(t, parse.Params[0].Out)
+	}
+	if nodes := funcs["Nodes"]; assert.NotNil(t, nodes) {
+		assert.Equal(t, "Vertex", nodes.Results[0].Type.Elem.BindName)
+	}
+
+	structs := map[string]*Struct{}
+	for _, s := range pkg.Structs {
+		structs[s.Name] = s
+	}
+	assert.NotContains(t, structs, "Internal")
+	if node := structs["Node"]; assert.NotNil(t, node) {
+		assert.Equal(t, "Vertex", node.BindName)
+		assert.Empty(t, node.Methods)
+		assert.True(t, node.Fields[1].Hidden, "new_with still takes the excluded field")
+	}
+	if point := structs["Point"]; assert.NotNil(t, point) {
+		assert.Len(t, point.Fields, 3)
+		assert.True(t, point.Fields[2].Hidden)
+	}
+}